@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/evals"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/usage"
+)
+
+// parseModels splits a comma-separated list of models. Each model may be
+// prefixed with its provider, as in "anthropic:claude-3-5-haiku-latest".
+func parseModels(list string) ([]string, error) {
+	var models []string
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(list, ",") {
+		model := strings.TrimSpace(entry)
+		if provider, name, ok := strings.Cut(model, ":"); ok {
+			if provider != llm.Provider {
+				return nil, fmt.Errorf("unsupported provider %q for %s (only %s is supported)", provider, name, llm.Provider)
+			}
+			model = name
+		}
+		if model == "" || seen[model] {
+			continue
+		}
+		seen[model] = true
+		models = append(models, model)
+	}
+	if len(models) < 2 {
+		return nil, fmt.Errorf("-models needs at least two different models, got %q", list)
+	}
+	return models, nil
+}
+
+// compareModels runs every case against each model in turn. A run that
+// stops early keeps the results it has, with its error.
+func compareModels(
+	ctx context.Context,
+	models []string,
+	timeout time.Duration,
+	opts []evals.Option,
+	cases []evals.EvalCase,
+) (*evals.Comparison, error) {
+	runs := make([]evals.ModelRun, 0, len(models))
+	for _, model := range models {
+		evaluator := evals.NewEvaluator(model, timeout, opts...)
+		if err := evaluator.LoadTestCases(cases); err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(os.Stderr, "Evaluating %s...\n", model)
+		results, err := evaluator.RunAll(ctx)
+		run := evals.ModelRun{
+			Model:       model,
+			Results:     results,
+			Tokens:      evaluator.Usage(),
+			JudgeTokens: evaluator.JudgeUsage(),
+		}
+		if err != nil {
+			run.Error = err.Error()
+		}
+		runs = append(runs, run)
+	}
+	return evals.CompareModels(runs, loadPrices()), nil
+}
+
+// loadPrices reads the price table, overriding the defaults with
+// ~/.ncurl/prices.json when it exists
+func loadPrices() usage.Prices {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return usage.DefaultPrices
+	}
+
+	prices, err := usage.LoadPrices(filepath.Join(home, ".ncurl", "prices.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; using default prices\n", err)
+		return usage.DefaultPrices
+	}
+	return prices
+}
@@ -11,7 +11,9 @@ import (
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/stephenbyrne99/ncurl/internal/cassette"
 	"github.com/stephenbyrne99/ncurl/internal/evals"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
 )
 
 func main() {
@@ -19,6 +21,7 @@ func main() {
 	testCasesFile := flag.String("tests", "", "Path to JSON file with test cases (default: use built-in test cases)")
 	outputFile := flag.String("output", "", "Path to save evaluation results (default: print to stdout)")
 	modelFlag := flag.String("model", anthropic.ModelClaude3_7SonnetLatest, "Anthropic model to use")
+	modelsFlag := flag.String("models", "", "Comma-separated models to compare, instead of evaluating -model")
 	timeoutFlag := flag.Int("timeout", 30, "Timeout in seconds for each test case")
 	verboseFlag := flag.Bool("v", false, "Verbose output")
 	runIDFlag := flag.String("id", "", "Run only test cases with this ID")
@@ -26,6 +29,21 @@ func main() {
 	jsonFlag := flag.Bool("json", false, "Output results in JSON format")
 	genTestsFlag := flag.Bool("gen-tests", false, "Generate a template test cases file")
 	genTestsOutputFlag := flag.String("gen-tests-output", "testcases.json", "Path to save generated test cases")
+	recordFlag := flag.String("record", "", "Save every model exchange to this cassette file")
+	replayFlag := flag.String("replay", "", "Answer model calls from this cassette file instead of the model")
+	parallelFlag := flag.Int("parallel", 1, "Number of test cases to run at once")
+	rpmFlag := flag.Int("rpm", 0, "Maximum model requests per minute across all test cases (0 = no limit)")
+	retriesFlag := flag.Int("retries", 3, "Times to retry a test case when the model provider is rate limiting")
+	failFastFlag := flag.Bool("fail-fast", false, "Stop at the first test case that errors")
+	e2eFlag := flag.Bool("e2e", false, "Send each request to a local mock server and check what it received")
+	validateResponseFlag := flag.Bool("validate-response", false, "With -e2e, have the model judge each mock response")
+	baselineFlag := flag.String("baseline", "", "Compare the results with a run saved with -json, such as one from main")
+	maxRegressionsFlag := flag.Int("max-regressions", 0,
+		"With -baseline, exit 1 if more than this many cases that passed now fail or weren't run")
+	judgeFlag := flag.Bool("judge", false, "Also score each test case with a judge model and analyze failures")
+	judgeModelFlag := flag.String("judge-model", "", "Model to judge with (default: the -model value)")
+	judgeModeFlag := flag.String("judge-mode", string(evals.JudgeAdvisory),
+		"How the judge's score counts: advisory, required or decisive")
 
 	// Custom usage message
 	flag.Usage = func() {
@@ -42,6 +60,15 @@ func main() {
 		fmt.Println("  ncurl-eval -output results.md           # Save results to file")
 		fmt.Println("  ncurl-eval -json -output results.json   # Save results as JSON")
 		fmt.Println("  ncurl-eval -gen-tests                   # Generate template test cases file")
+		fmt.Println("  ncurl-eval -record evals.cassette.json  # Run and save the model's answers")
+		fmt.Println("  ncurl-eval -replay evals.cassette.json  # Rerun offline with the saved answers")
+		fmt.Println("  ncurl-eval -parallel 8 -rpm 50          # Run 8 cases at once, within 50 requests a minute")
+		fmt.Println("  ncurl-eval -fail-fast                   # Stop at the first case that errors")
+		fmt.Println("  ncurl-eval -e2e -validate-response      # Send requests to a mock server and judge the responses")
+		fmt.Println("  ncurl-eval -judge -judge-mode required  # Pass only cases both the rules and a judge model accept")
+		fmt.Println("  ncurl-eval -baseline main.json          # Fail if any case that passed on main now fails")
+		fmt.Println("  ncurl-eval -models claude-3-5-haiku-latest,claude-3-7-sonnet-latest")
+		fmt.Println("                                          # Compare models on every case")
 	}
 
 	flag.Parse()
@@ -52,8 +79,30 @@ func main() {
 		os.Exit(exitCode)
 	}()
 
-	// Check if API key is set
-	if os.Getenv("ANTHROPIC_API_KEY") == "" {
+	if *recordFlag != "" && *replayFlag != "" {
+		fmt.Fprintf(os.Stderr, "-record and -replay cannot be combined\n")
+		exitCode = 1
+		return
+	}
+
+	if *validateResponseFlag && !*e2eFlag {
+		fmt.Fprintf(os.Stderr, "-validate-response requires -e2e\n")
+		exitCode = 1
+		return
+	}
+
+	if *judgeFlag {
+		if err := evals.ValidateJudgeMode(evals.JudgeMode(*judgeModeFlag)); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -judge-mode: %v\n", err)
+			exitCode = 1
+			return
+		}
+	}
+
+	// Check if API key is set; replays never call the model, though the
+	// response validator always does
+	needsModel := *replayFlag == "" || *validateResponseFlag
+	if needsModel && os.Getenv("ANTHROPIC_API_KEY") == "" {
 		fmt.Fprintf(os.Stderr, "ANTHROPIC_API_KEY environment variable is required\n")
 		exitCode = 1
 		return
@@ -70,8 +119,85 @@ func main() {
 		return
 	}
 
-	// Create an evaluator
-	evaluator := evals.NewEvaluator(*modelFlag, time.Duration(*timeoutFlag)*time.Second)
+	var models []string
+	if *modelsFlag != "" {
+		var parseErr error
+		if models, parseErr = parseModels(*modelsFlag); parseErr != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -models: %v\n", parseErr)
+			exitCode = 1
+			return
+		}
+	}
+
+	// Load the baseline before spending time on the run
+	var baseline []evals.EvalResult
+	if *baselineFlag != "" {
+		if len(models) > 0 || *maxRegressionsFlag < 0 {
+			fmt.Fprintf(os.Stderr, "-baseline cannot be combined with -models, and -max-regressions must not be negative\n")
+			exitCode = 1
+			return
+		}
+		var loadErr error
+		if baseline, loadErr = evals.LoadResults(*baselineFlag); loadErr != nil {
+			fmt.Fprintf(os.Stderr, "Error loading baseline: %v\n", loadErr)
+			exitCode = 1
+			return
+		}
+	}
+
+	if *parallelFlag < 1 || *rpmFlag < 0 || *retriesFlag < 0 {
+		fmt.Fprintf(os.Stderr, "-parallel must be at least 1, and -rpm and -retries must not be negative\n")
+		exitCode = 1
+		return
+	}
+	const initialBackoff = time.Second
+	evalOpts := []evals.Option{
+		evals.WithParallel(*parallelFlag),
+		evals.WithRateLimit(*rpmFlag),
+		evals.WithRetries(*retriesFlag, initialBackoff),
+		evals.WithFailFast(*failFastFlag),
+		evals.WithEndToEnd(*e2eFlag),
+	}
+	if *validateResponseFlag {
+		evalOpts = append(evalOpts, evals.WithResponseValidator(evals.NewResponseValidator(*modelFlag)))
+	}
+	if *judgeFlag {
+		judgeModel := *judgeModelFlag
+		if judgeModel == "" {
+			judgeModel = *modelFlag
+		}
+		evalOpts = append(evalOpts, evals.WithJudge(judgeModel, evals.JudgeMode(*judgeModeFlag)))
+	}
+
+	// Record model exchanges to a cassette, or replay them from one
+	switch {
+	case *recordFlag != "":
+		recording, openErr := cassette.Open(*recordFlag)
+		if openErr != nil {
+			fmt.Fprintf(os.Stderr, "Error opening cassette: %v\n", openErr)
+			exitCode = 1
+			return
+		}
+		evalOpts = append(evalOpts, evals.WithClientOptions(llm.WithRecorder(recording)))
+
+		// Save whatever was recorded, even if the run fails part way
+		defer func() {
+			if saveErr := recording.Save(); saveErr != nil {
+				fmt.Fprintf(os.Stderr, "Error saving cassette: %v\n", saveErr)
+				exitCode = 1
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Recorded %d model exchanges to %s\n", recording.Len(), *recordFlag)
+		}()
+	case *replayFlag != "":
+		recording, loadErr := cassette.Load(*replayFlag)
+		if loadErr != nil {
+			fmt.Fprintf(os.Stderr, "Error loading cassette: %v\n", loadErr)
+			exitCode = 1
+			return
+		}
+		evalOpts = append(evalOpts, evals.WithClientOptions(llm.WithReplayer(recording)))
+	}
 
 	// Load test cases
 	var testCases []evals.EvalCase
@@ -113,7 +239,43 @@ func main() {
 		testCases = testCases[:*countFlag]
 	}
 
-	// Load test cases into evaluator
+	timeout := time.Duration(*timeoutFlag) * time.Second
+	ctx := context.Background()
+
+	// Compare several models instead of evaluating one
+	if len(models) > 0 {
+		comparison, compareErr := compareModels(ctx, models, timeout, evalOpts, testCases)
+		if compareErr != nil {
+			fmt.Fprintf(os.Stderr, "Error comparing models: %v\n", compareErr)
+			exitCode = 1
+			return
+		}
+		for _, m := range comparison.Models {
+			if m.Error != "" {
+				fmt.Fprintf(os.Stderr, "Error evaluating %s: %s\n", m.Model, m.Error)
+				exitCode = 1
+			}
+		}
+
+		output := evals.GenerateComparisonReport(comparison)
+		if *jsonFlag {
+			jsonData, jsonErr := json.MarshalIndent(comparison, "", "  ")
+			if jsonErr != nil {
+				fmt.Fprintf(os.Stderr, "Error generating JSON output: %v\n", jsonErr)
+				exitCode = 1
+				return
+			}
+			output = string(jsonData)
+		}
+		if writeErr := writeOutput(*outputFile, output); writeErr != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", writeErr)
+			exitCode = 1
+		}
+		return
+	}
+
+	// Create an evaluator and load the test cases into it
+	evaluator := evals.NewEvaluator(*modelFlag, timeout, evalOpts...)
 	if loadErr := evaluator.LoadTestCases(testCases); loadErr != nil {
 		fmt.Fprintf(os.Stderr, "Error loading test cases: %v\n", loadErr)
 		exitCode = 1
@@ -121,12 +283,14 @@ func main() {
 	}
 
 	// Run the evaluations
-	ctx := context.Background()
 	results, err := evaluator.RunAll(ctx)
 	if err != nil {
+		// Still report the cases that finished before the run stopped
 		fmt.Fprintf(os.Stderr, "Error running evaluations: %v\n", err)
 		exitCode = 1
-		return
+		if len(results) == 0 {
+			return
+		}
 	}
 
 	// Generate output
@@ -145,38 +309,39 @@ func main() {
 		output = evals.GenerateReport(results)
 	}
 
-	// Write output to file or stdout
-	if *outputFile != "" {
-		// Ensure directory exists
-		dir := filepath.Dir(*outputFile)
-		if mkdirErr := os.MkdirAll(dir, 0750); mkdirErr != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", mkdirErr)
-			exitCode = 1
-			return
-		}
-
-		if writeErr := os.WriteFile(*outputFile, []byte(output), 0600); writeErr != nil {
-			fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", writeErr)
-			exitCode = 1
-			return
+	// Compare with the baseline. JSON output stays a plain list of results,
+	// so it can be the next baseline, and the comparison goes to stderr.
+	var diff *evals.BaselineDiff
+	if *baselineFlag != "" {
+		diff = evals.CompareToBaseline(baseline, results)
+		if *jsonFlag {
+			fmt.Fprint(os.Stderr, evals.GenerateBaselineReport(diff))
+		} else {
+			output += "\n" + evals.GenerateBaselineReport(diff)
 		}
+	}
 
-		fmt.Printf("Evaluation results saved to %s\n", *outputFile)
-	} else {
-		// Print to stdout
-		fmt.Println(output)
+	// Write output to file or stdout
+	if writeErr := writeOutput(*outputFile, output); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", writeErr)
+		exitCode = 1
+		return
 	}
 
 	// Print summary to stderr if verbose
 	if *verboseFlag {
 		totalTests := len(results)
 		successCount := 0
+		errorCount := 0
 		var totalScore float64
 
 		for _, r := range results {
 			if r.Success {
 				successCount++
 			}
+			if r.ErrorCategory != "" {
+				errorCount++
+			}
 			totalScore += r.Score
 		}
 
@@ -187,6 +352,34 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Evaluation Summary:\n")
 		fmt.Fprintf(os.Stderr, "- Total Tests: %d\n", totalTests)
 		fmt.Fprintf(os.Stderr, "- Successful Tests: %d (%.1f%%)\n", successCount, successRate)
+		fmt.Fprintf(os.Stderr, "- Errored Tests: %d\n", errorCount)
 		fmt.Fprintf(os.Stderr, "- Average Score: %.2f\n", avgScore)
 	}
+
+	if diff != nil && diff.Regressions() > *maxRegressionsFlag {
+		fmt.Fprintf(os.Stderr, "%d cases that passed in the baseline now fail or weren't run (at most %d allowed)\n",
+			diff.Regressions(), *maxRegressionsFlag)
+		exitCode = 1
+	}
+}
+
+// writeOutput saves output to path, creating its directory, or prints it to
+// stdout when path is empty
+func writeOutput(path, output string) error {
+	if path == "" {
+		fmt.Println(output)
+		return nil
+	}
+
+	// Ensure directory exists
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(output), 0600); err != nil {
+		return fmt.Errorf("error writing output file: %w", err)
+	}
+
+	fmt.Printf("Evaluation results saved to %s\n", path)
+	return nil
 }
@@ -0,0 +1,92 @@
+// Command ncurl-mockllm serves scripted model responses over the Anthropic
+// Messages API, so ncurl and ncurl-eval can run offline
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/mockllm"
+)
+
+func main() {
+	// Define command line flags
+	fixturesFile := flag.String("fixtures", "", "Path to a JSON file with scripted responses (required)")
+	addrFlag := flag.String("addr", "127.0.0.1:8765", "Address to listen on; use port 0 for any free port")
+	verboseFlag := flag.Bool("v", false, "Log every request and the fixture that answered it")
+
+	// Custom usage message
+	flag.Usage = func() {
+		fmt.Println("ncurl-mockllm - Mock model server for ncurl")
+		fmt.Println("\nAnswers Anthropic Messages API requests with scripted responses")
+		fmt.Println("matched on the prompt, for testing without network access or an API key.")
+		fmt.Println("\nUsage: ncurl-mockllm -fixtures <file> [options]")
+		fmt.Println("\nOptions:")
+		flag.PrintDefaults()
+		fmt.Println("\nFixtures are a JSON array, tried in order:")
+		fmt.Println(`  [{"match": "list users", "response": "{\"method\": \"GET\", \"url\": \"http://localhost:8080/users\"}"},`)
+		fmt.Println(`   {"regex": "(?i)^delete", "status": 429, "response": "rate limited", "times": 1},`)
+		fmt.Println(`   {"response": "{\"url\": \"http://localhost:8080/\"}"}]`)
+		fmt.Println("\nExamples:")
+		fmt.Println("  ncurl-mockllm -fixtures fixtures.json &")
+		fmt.Println("  ANTHROPIC_BASE_URL=http://127.0.0.1:8765 ANTHROPIC_API_KEY=test ncurl \"list users\"")
+		fmt.Println("  ANTHROPIC_BASE_URL=http://127.0.0.1:8765 ANTHROPIC_API_KEY=test ncurl-eval -tests cases.json")
+	}
+
+	flag.Parse()
+
+	// Set up exit code handling
+	var exitCode int
+	defer func() {
+		os.Exit(exitCode)
+	}()
+
+	if *fixturesFile == "" {
+		fmt.Fprintf(os.Stderr, "-fixtures is required\n")
+		exitCode = 2
+		return
+	}
+
+	fixtures, err := mockllm.LoadFixtures(*fixturesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading fixtures: %v\n", err)
+		exitCode = 1
+		return
+	}
+
+	var opts []mockllm.Option
+	if *verboseFlag {
+		opts = append(opts, mockllm.WithLogger(log.New(os.Stderr, "", log.LstdFlags)))
+	}
+	server, err := mockllm.New(fixtures, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading fixtures: %v\n", err)
+		exitCode = 1
+		return
+	}
+
+	// Listen first so the actual address can be shown, even for port 0
+	listener, err := net.Listen("tcp", *addrFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listening on %s: %v\n", *addrFlag, err)
+		exitCode = 1
+		return
+	}
+
+	baseURL := "http://" + listener.Addr().String()
+	fmt.Printf("Serving %d fixtures at %s\n", len(fixtures), baseURL)
+	fmt.Printf("export %s=%s\n", llm.BaseURLEnv, baseURL)
+
+	const readHeaderTimeout = 10 * time.Second
+	httpServer := &http.Server{Handler: server, ReadHeaderTimeout: readHeaderTimeout}
+	if serveErr := httpServer.Serve(listener); serveErr != nil {
+		fmt.Fprintf(os.Stderr, "Error serving: %v\n", serveErr)
+		exitCode = 1
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/batch"
+	"github.com/stephenbyrne99/ncurl/internal/cache"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/payload"
+	"github.com/stephenbyrne99/ncurl/internal/rules"
+)
+
+// Where translatePrompt found a request spec
+const (
+	fromModel = "model"
+	fromCache = "cache"
+	fromRules = "rules"
+)
+
+// translatePrompt generates a request spec for a prompt and its payloads,
+// and reports where it came from. Unless -translator is model, simple
+// prompts like "get https://example.com" are translated locally. When a
+// cache is given, a previous translation of the same prompt is reused
+// instead of calling the model, as long as the model, system prompt and
+// session match. The returned spec still holds payload references; resolve
+// them with payload.Resolve.
+func translatePrompt(
+	ctx context.Context,
+	client *llm.Client,
+	translations *cache.Cache,
+	prompt string,
+	payloads []*payload.Payload,
+) (*httpx.RequestSpec, string, error) {
+	if *translator != rules.ModeModel {
+		spec, err := rules.Parse(prompt)
+		switch {
+		case err == nil && len(payloads) == 0:
+			return spec, fromRules, nil
+		case *translator == rules.ModeLocal && err == nil:
+			return nil, "", fmt.Errorf("%w: piped request bodies need the model", rules.ErrNoMatch)
+		case *translator == rules.ModeLocal:
+			return nil, "", err
+		}
+	}
+
+	fullPrompt := payload.AppendToPrompt(prompt, payloads)
+	key := cache.Key(llm.Provider, client.Model, llm.PromptVersion(), *sessionName, cache.NormalizePrompt(fullPrompt))
+
+	if translations != nil {
+		if entry, ok := translations.Get(key); ok {
+			return &entry.Spec, fromCache, nil
+		}
+	}
+
+	spec, err := client.GenerateRequestSpec(ctx, fullPrompt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if translations != nil {
+		entry := cache.Entry{
+			Prompt:      fullPrompt,
+			Model:       client.Model,
+			Provider:    llm.Provider,
+			Environment: *sessionName,
+			Spec:        *spec,
+		}
+		if putErr := translations.Put(key, entry); putErr != nil {
+			errorLogger.Printf("Warning: Could not cache translation: %v\n", putErr)
+		}
+	}
+
+	return spec, fromModel, nil
+}
+
+// runBatch translates and executes every prompt in a batch file and
+// returns the exit code. The exit code is that of the first failed prompt
+// in file order, or exitOK if none failed.
+func runBatch(path string) int {
+	if formatErr := batch.ValidateFormat(*batchOutput); formatErr != nil {
+		errorLogger.Printf("%v\n", formatErr)
+		return exitUsage
+	}
+
+	items, err := batch.Load(path)
+	if err != nil {
+		errorLogger.Printf("Failed to load batch: %v\n", err)
+		return exitError
+	}
+
+	// Batches of simple prompts run without an API key. A prompt that sends
+	// a file needs the model, as it does when the prompt is translated.
+	for _, item := range items {
+		payloads, payloadErr := collectPayloads(item.Prompt, false)
+		if payloadErr != nil {
+			continue // The item fails on its own when it runs
+		}
+		closePayloads(payloads)
+		if needsModel(item.Prompt, payloads) {
+			if !checkAPIKey() {
+				return exitError
+			}
+			break
+		}
+	}
+
+	translations := openCache()
+
+	// With -session, all prompts share the session's cookie jar. Prompts run
+	// concurrently, so a login line only takes effect for the lines after it
+	// when there is one worker.
+	var execOpts []httpx.ExecuteOption
+	if *sessionName != "" {
+		sessionManager, jar, sessionErr := loadSession(*sessionName)
+		if sessionErr != nil {
+			errorLogger.Printf("Failed to load session: %v\n", sessionErr)
+			return exitError
+		}
+		execOpts = append(execOpts, httpx.WithCookieJar(jar))
+		defer func() {
+			if saveErr := sessionManager.Save(*sessionName, jar); saveErr != nil {
+				errorLogger.Printf("Warning: Could not save session: %v\n", saveErr)
+			}
+		}()
+	}
+
+	client := llm.NewClient(*model)
+	defer recordUsage(client)
+	run := func(ctx context.Context, item batch.Item) batch.Result {
+		return runBatchItem(ctx, client, translations, item, execOpts)
+	}
+
+	var onResult func(batch.Result)
+	if *batchOutput == batch.FormatJSONL {
+		encoder := json.NewEncoder(os.Stdout)
+		onResult = func(r batch.Result) {
+			_ = encoder.Encode(r)
+		}
+	}
+
+	opts := batch.Options{Workers: *workers, FailFast: *failFast}
+	results := batch.Run(context.Background(), items, opts, run, onResult)
+
+	if *batchOutput == batch.FormatTable {
+		_ = batch.WriteTable(os.Stdout, results)
+	}
+
+	passed, failed, skipped := batch.Summary(results)
+	fmt.Fprintf(os.Stderr, "%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+
+	for _, r := range results {
+		if r.Failed {
+			return r.ExitCode
+		}
+	}
+	return exitOK
+}
+
+// runBatchItem translates and executes a single batch prompt
+func runBatchItem(
+	ctx context.Context,
+	client *llm.Client,
+	translations *cache.Cache,
+	item batch.Item,
+	execOpts []httpx.ExecuteOption,
+) batch.Result {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(*timeout)*time.Second)
+	defer cancel()
+
+	fail := func(err error, code int) batch.Result {
+		return batch.Result{Err: err, Failed: true, ExitCode: code}
+	}
+
+	payloads, err := collectPayloads(item.Prompt, false)
+	if err != nil {
+		return fail(err, exitError)
+	}
+	defer closePayloads(payloads)
+
+	spec, source, err := translatePrompt(ctx, client, translations, item.Prompt, payloads)
+	if err != nil {
+		return fail(err, llmExitCode(ctx, err))
+	}
+	cached, local := source == fromCache, source == fromRules
+
+	if resolveErr := payload.Resolve(spec, item.Prompt, payloads); resolveErr != nil {
+		result := fail(resolveErr, exitInvalidSpec)
+		result.Spec, result.Cached, result.Local = spec, cached, local
+		return result
+	}
+
+	response, err := httpx.ExecuteWithContext(ctx, spec, execOpts...)
+	if err != nil {
+		result := fail(err, requestExitCode(ctx, err))
+		result.Spec, result.Cached, result.Local = spec, cached, local
+		return result
+	}
+
+	result := batch.Result{
+		Spec:    spec,
+		Status:  response.StatusCode,
+		Latency: response.Duration,
+		Cached:  cached,
+		Local:   local,
+	}
+	if *failOnHTTPError {
+		if code := statusExitCode(response.StatusCode); code != exitOK {
+			result.Failed, result.ExitCode = true, code
+		}
+	}
+
+	return result
+}
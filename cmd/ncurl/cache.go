@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/cache"
+)
+
+// maxListedPromptLength limits how much of a prompt `ncurl cache ls` shows
+const maxListedPromptLength = 60
+
+// openCache opens the translation cache, or returns nil when caching is
+// turned off or the cache can't be opened
+func openCache() *cache.Cache {
+	if *noCache {
+		return nil
+	}
+
+	translations, err := cache.New(cache.WithTTL(*cacheTTL), cache.WithMaxEntries(*cacheSize))
+	if err != nil {
+		errorLogger.Printf("Warning: Could not open translation cache: %v\n", err)
+		return nil
+	}
+	return translations
+}
+
+// runCacheCommand lists or clears the translation cache
+func runCacheCommand(subcommand string) int {
+	translations, err := cache.New(cache.WithTTL(*cacheTTL), cache.WithMaxEntries(*cacheSize))
+	if err != nil {
+		errorLogger.Printf("Failed to open translation cache: %v\n", err)
+		return exitError
+	}
+
+	if subcommand == "clear" {
+		removed, clearErr := translations.Clear()
+		if clearErr != nil {
+			errorLogger.Printf("Failed to clear translation cache: %v\n", clearErr)
+			return exitError
+		}
+		fmt.Printf("Removed %d cached %s\n", removed, translationNoun(removed))
+		return exitOK
+	}
+
+	entries, err := translations.List()
+	if err != nil {
+		errorLogger.Printf("Failed to list translation cache: %v\n", err)
+		return exitError
+	}
+	if len(entries) == 0 {
+		fmt.Println("No cached translations")
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AGE\tMODEL\tSESSION\tREQUEST\tPROMPT")
+	for _, entry := range entries {
+		session := entry.Environment
+		if session == "" {
+			session = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s %s\t%s\n",
+			time.Since(entry.Created).Round(time.Second), entry.Model, session,
+			entry.Spec.Method, entry.Spec.URL, shortenPrompt(entry.Prompt))
+	}
+	if flushErr := w.Flush(); flushErr != nil {
+		errorLogger.Printf("Failed to list translation cache: %v\n", flushErr)
+		return exitError
+	}
+	fmt.Printf("%d cached %s\n", len(entries), translationNoun(len(entries)))
+	return exitOK
+}
+
+// translationNoun returns "translation" or "translations" to go with n
+func translationNoun(n int) string {
+	if n == 1 {
+		return "translation"
+	}
+	return "translations"
+}
+
+// shortenPrompt shows the first line of a prompt, truncated
+func shortenPrompt(prompt string) string {
+	prompt, _, _ = strings.Cut(prompt, "\n")
+	if len(prompt) > maxListedPromptLength {
+		return prompt[:maxListedPromptLength] + "..."
+	}
+	return prompt
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/chain"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/payload"
+	"github.com/stephenbyrne99/ncurl/internal/pretty"
+	"github.com/stephenbyrne99/ncurl/internal/session"
+)
+
+// maxPlanValueLength limits how much of a body or captured value is shown
+const maxPlanValueLength = 80
+
+// runChain plans a multi-step workflow, asks for approval and runs it.
+// Planning and each step get their own -t timeout, so neither the time spent
+// approving the plan nor earlier steps count against later ones. It returns
+// the exit code and the status of the last response, if any.
+func runChain(client *llm.Client, prompt string, printer *pretty.Printer) (int, int) {
+	timeout := time.Duration(*timeout) * time.Second
+	planCtx, cancelPlan := context.WithTimeout(context.Background(), timeout)
+	plan, err := client.GeneratePlan(planCtx, prompt)
+	if err != nil {
+		cancelPlan()
+		errorLogger.Printf("Failed to plan requests: %v\n", err)
+		return llmExitCode(planCtx, err), 0
+	}
+	cancelPlan()
+
+	printPlan(os.Stderr, plan)
+	if !*assumeYes && !confirm(os.Stderr, fmt.Sprintf("Run %d requests?", len(plan.Steps))) {
+		fmt.Fprintln(os.Stderr, "Cancelled")
+		return exitError, 0
+	}
+
+	// Every step shares one cookie jar: the named session's, or a fresh one
+	jar, saveJar, err := openJar(*sessionName)
+	if err != nil {
+		errorLogger.Printf("Failed to load session: %v\n", err)
+		return exitError, 0
+	}
+	defer saveJar()
+
+	// Steps may only read files the prompt names, whatever earlier responses say
+	execute := func(ctx context.Context, spec *httpx.RequestSpec) (*httpx.Response, error) {
+		if pathErr := payload.CheckPaths(spec, prompt); pathErr != nil {
+			return nil, pathErr
+		}
+		stepCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return httpx.ExecuteWithContext(stepCtx, spec, httpx.WithCookieJar(jar))
+	}
+
+	step := 0
+	onStep := func(r chain.StepResult) {
+		step++
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s: %s %s -> %s (%s)\n", step, len(plan.Steps), r.Step.Name,
+			r.Spec.Method, r.Spec.URL, printer.Status(r.Response.Status, r.Response.StatusCode), r.Response.Duration)
+		for _, c := range r.Step.Captures {
+			if value, ok := r.Captured[c.Name]; ok {
+				fmt.Fprintf(os.Stderr, "      %s = %s\n", c.Name, shorten(value))
+			}
+		}
+	}
+
+	ctx := context.Background()
+	results, err := chain.Run(ctx, plan, execute, onStep)
+
+	// Show the last response that came back, even if the chain stopped early
+	status := 0
+	if len(results) > 0 {
+		last := results[len(results)-1].Response
+		status = last.StatusCode
+		fmt.Fprintln(os.Stderr)
+		if *jsonOnly {
+			outputJSONOnlyMode(last.Body, isContentBinary(last.Header.Get("Content-Type")))
+		} else {
+			outputStandardMode(last, *verbose, isContentBinary(last.Header.Get("Content-Type")), printer, *rawOutput)
+		}
+	}
+
+	if err != nil {
+		errorLogger.Printf("Chain failed: %v\n", err)
+		switch {
+		case errors.Is(err, chain.ErrUnsuccessfulRequest) && *failOnHTTPError:
+			return statusExitCode(status), status
+		case errors.Is(err, chain.ErrUnresolvedVariable), errors.Is(err, chain.ErrInvalidPlan),
+			errors.Is(err, payload.ErrUnknownReference):
+			return exitInvalidSpec, status
+		case errors.Is(err, chain.ErrUnsuccessfulRequest), errors.Is(err, chain.ErrCaptureFailed):
+			return exitError, status
+		default:
+			return requestExitCode(ctx, err), status
+		}
+	}
+
+	return exitOK, status
+}
+
+// printPlan shows each step of a plan with its dependencies and captures
+func printPlan(w io.Writer, plan *chain.Plan) {
+	order, err := plan.Order()
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintln(w, "Plan:")
+	for i, step := range order {
+		fmt.Fprintf(w, "  %d. %s: %s %s\n", i+1, step.Name, step.Request.Method, step.Request.URL)
+		if len(step.DependsOn) > 0 {
+			fmt.Fprintf(w, "       after %s\n", strings.Join(step.DependsOn, ", "))
+		}
+		for k, v := range step.Request.Headers {
+			fmt.Fprintf(w, "       %s: %s\n", k, v)
+		}
+		if step.Request.Body != "" {
+			fmt.Fprintf(w, "       body: %s\n", shorten(step.Request.Body))
+		}
+		for _, c := range step.Captures {
+			fmt.Fprintf(w, "       captures {{%s}} from %s %s\n", c.Name, c.From, c.Path)
+		}
+	}
+}
+
+// confirm asks a yes/no question on w and reads the answer from stdin.
+// Piped stdin can't answer, so the question is refused.
+func confirm(w io.Writer, question string) bool {
+	if stdinIsPiped() {
+		fmt.Fprintln(w, "Refusing to run without confirmation; use -yes when stdin is not a terminal")
+		return false
+	}
+
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// openJar returns the named session's cookie jar and a function that saves
+// it, or a fresh jar that is not saved when name is empty
+func openJar(name string) (*session.Jar, func(), error) {
+	if name == "" {
+		return session.NewJar(), func() {}, nil
+	}
+
+	sessionManager, jar, err := loadSession(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	save := func() {
+		if saveErr := sessionManager.Save(name, jar); saveErr != nil {
+			errorLogger.Printf("Warning: Could not save session: %v\n", saveErr)
+		}
+	}
+	return jar, save, nil
+}
+
+// shorten collapses whitespace and truncates s for display
+func shorten(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxPlanValueLength {
+		return s[:maxPlanValueLength] + "..."
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/rules"
+)
+
+// Exit codes reported to the shell. Scripts can rely on these values; add
+// new codes at the end rather than renumbering.
+const (
+	exitOK          = 0
+	exitError       = 1 // General errors: configuration, local files, history, sessions
+	exitUsage       = 2 // Invalid flags or missing prompt (also used by the flag package)
+	exitLLMFailure  = 3 // The model could not be reached or gave an unusable answer
+	exitInvalidSpec = 4 // The generated request is invalid or cannot be built
+	exitNetwork     = 5 // The request could not be sent or the response not read
+	exitTimeout     = 6 // The -t deadline or the HTTP client timeout expired
+	exitHTTP4xx     = 7 // The server returned 4xx (only with -fail)
+	exitHTTP5xx     = 8 // The server returned 5xx (only with -fail)
+	exitAssertion   = 9 // One or more -expect assertions failed
+)
+
+// llmExitCode classifies an error from the model
+func llmExitCode(ctx context.Context, err error) int {
+	switch {
+	case isTimeout(ctx, err):
+		return exitTimeout
+	case errors.Is(err, httpx.ErrInvalidRequest):
+		// The model answered, but with a spec that fails validation
+		return exitInvalidSpec
+	case errors.Is(err, rules.ErrNoMatch):
+		// -translator local was given a prompt that needs the model
+		return exitUsage
+	default:
+		return exitLLMFailure
+	}
+}
+
+// requestExitCode classifies an error from executing a request
+func requestExitCode(ctx context.Context, err error) int {
+	switch {
+	case isTimeout(ctx, err):
+		return exitTimeout
+	case errors.Is(err, httpx.ErrRequestFailed), errors.Is(err, httpx.ErrReadResponse):
+		return exitNetwork
+	default:
+		// Validation failures, unreadable body files and malformed URLs
+		return exitInvalidSpec
+	}
+}
+
+// statusExitCode returns the exit code for an HTTP status when -fail is set
+func statusExitCode(statusCode int) int {
+	switch {
+	case statusCode >= 500:
+		return exitHTTP5xx
+	case statusCode >= 400:
+		return exitHTTP4xx
+	default:
+		return exitOK
+	}
+}
+
+// isTimeout reports whether err was caused by a deadline
+func isTimeout(ctx context.Context, err error) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
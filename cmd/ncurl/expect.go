@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/stephenbyrne99/ncurl/internal/assert"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/payload"
+)
+
+// stringList is a flag that collects every value it is given
+type stringList []string
+
+// String implements flag.Value
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+// Set implements flag.Value
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// newStringListFlag registers a repeatable string flag
+func newStringListFlag(name, usage string) *stringList {
+	l := &stringList{}
+	flag.Var(l, name, usage)
+	return l
+}
+
+// expectations holds assertions that are either already parsed or still
+// written in natural language
+type expectations struct {
+	parsed  []*assert.Assertion
+	natural []string
+}
+
+// parseExpectations parses -expect values. Values that are not written as
+// assertions are treated as natural language and compiled later; ones that
+// are but don't parse, like status=abc, are an error.
+func parseExpectations(values []string) (expectations, error) {
+	var e expectations
+	for _, v := range values {
+		a, err := assert.Parse(v)
+		switch {
+		case err == nil:
+			e.parsed = append(e.parsed, a)
+		case assert.HasSubject(v):
+			return expectations{}, err
+		default:
+			e.natural = append(e.natural, v)
+		}
+	}
+	return e, nil
+}
+
+// compile turns natural language expectations into assertions using the
+// model and shows the result on w so it can be reused. The model only sees
+// the schema of a JSON response, never its data.
+func (e expectations) compile(
+	ctx context.Context,
+	client *llm.Client,
+	response *httpx.Response,
+	w io.Writer,
+) ([]*assert.Assertion, error) {
+	assertions := e.parsed
+	if len(e.natural) == 0 {
+		return assertions, nil
+	}
+
+	schema := "not JSON"
+	var data interface{}
+	if err := json.Unmarshal(response.Body, &data); err == nil {
+		schema = payload.Schema(data)
+	}
+
+	for _, text := range e.natural {
+		compiled, err := client.GenerateAssertions(ctx, text, schema)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(w, "Expectation %q compiled to:\n", text)
+		for _, a := range compiled {
+			fmt.Fprintf(w, "  -expect '%s'\n", a.Source)
+		}
+		assertions = append(assertions, compiled...)
+	}
+
+	return assertions, nil
+}
@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -12,9 +13,19 @@ import (
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/stephenbyrne99/ncurl/internal/assert"
+	"github.com/stephenbyrne99/ncurl/internal/batch"
+	"github.com/stephenbyrne99/ncurl/internal/cache"
+	"github.com/stephenbyrne99/ncurl/internal/editor"
 	"github.com/stephenbyrne99/ncurl/internal/history"
 	"github.com/stephenbyrne99/ncurl/internal/httpx"
 	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/payload"
+	"github.com/stephenbyrne99/ncurl/internal/pretty"
+	"github.com/stephenbyrne99/ncurl/internal/query"
+	"github.com/stephenbyrne99/ncurl/internal/rules"
+	"github.com/stephenbyrne99/ncurl/internal/session"
+	"github.com/stephenbyrne99/ncurl/internal/usage"
 )
 
 // Version information set by goreleaser
@@ -46,6 +57,30 @@ var (
 	historyRerun       = flag.Int("rerun", 0, "Rerun a command from history by index")
 	historySearch      = flag.String("search", "", "Search command history for a term")
 	interactiveHistory = flag.Bool("i", false, "Interactive history selection mode")
+	sessionName        = flag.String("session", "", "Named session whose cookies persist across invocations")
+	sessionImport      = flag.String("session-import", "", "Import cookies from a Netscape cookie file into the session")
+	sessionExport      = flag.String("session-export", "", "Export the session's cookies to a Netscape cookie file")
+	readStdin          = flag.Bool("stdin", false, "Read a body from piped stdin even if the prompt doesn't mention it")
+	noStdin            = flag.Bool("no-stdin", false, "Never read a request body from piped stdin")
+	colorMode          = flag.String("color", pretty.ColorAuto, "Colorize output: auto, always or never")
+	rawOutput          = flag.Bool("raw", false, "Print the response body as received, without pretty-printing")
+	editRequest        = flag.Bool("edit", false, "Open the generated request in $EDITOR before sending it")
+	editFormat         = flag.String("edit-format", editor.FormatHTTP, "Format for -edit and :edit: http or json")
+	replMode           = flag.Bool("repl", false, "Start an interactive session where prompts build on each other")
+	chainMode          = flag.Bool("chain", false, "Plan the prompt as several requests that feed into each other")
+	assumeYes          = flag.Bool("yes", false, "Run a -chain plan without asking for confirmation")
+	batchFile          = flag.String("batch", "", "Run every prompt in a file (one per line, or a YAML list)")
+	batchOutput        = flag.String("batch-output", batch.FormatTable, "Batch output: table or jsonl")
+	workers            = flag.Int("workers", 4, "Number of batch prompts to run at once")
+	failFast           = flag.Bool("fail-fast", false, "Stop a batch after the first failed prompt")
+	noCache            = flag.Bool("no-cache", false, "Always ask the model instead of reusing cached translations")
+	cacheTTL           = flag.Duration("cache-ttl", cache.DefaultTTL, "How long cached translations are reused")
+	cacheSize          = flag.Int("cache-size", cache.DefaultMaxEntries, "Maximum number of cached translations")
+	translator         = flag.String("translator", rules.ModeAuto, "How prompts become requests: auto, model or local")
+	expect             = newStringListFlag("expect", "Assert on the response, e.g. status=200 (repeatable)")
+	failOnHTTPError    = flag.Bool("fail", false, "Exit with a non-zero code when the server returns an HTTP error")
+	extract            = flag.String("extract", "", "Describe the data to keep from a JSON response, or give a query")
+	extractFormat      = flag.String("extract-format", query.FormatAuto, "Extraction output: auto, json, table or lines")
 )
 
 // printHelp displays detailed usage information and examples
@@ -58,13 +93,29 @@ DESCRIPTION
 
 USAGE
   ncurl [options] "<natural language request>"
+  ncurl cache ls    List cached translations
+  ncurl cache clear Remove all cached translations
+  ncurl stats [day|week]
+                    Show model token usage and estimated cost by day or week
   ncurl help        Show this help message
 
 OPTIONS
   -t <seconds>       Set timeout in seconds (default: 30)
   -m <model>         Specify Anthropic model to use (default: claude-3-7-sonnet)
   -j                 Output response body as JSON only
-  -v                 Verbose output (include request details)
+  -v                 Verbose output (include request details and model usage)
+  -stdin             Read a body from piped stdin even if the prompt doesn't mention it
+  -no-stdin          Never read a request body from piped stdin
+  -color <mode>      Colorize output: auto, always or never (default: auto)
+  -raw               Print the response body as received, without pretty-printing
+  -fail              Exit with code 7 for HTTP 4xx and 8 for HTTP 5xx responses
+  -expect <check>    Assert on the response; repeatable. Checks are status=200,
+                     json:.path == "value", header:Name~regex, time<500ms and
+                     body~regex, or plain English compiled by the model
+  -extract <text>    Describe the data to keep from a JSON response, or give a query like '.items[].name'
+  -extract-format <f> Extraction output: auto, json, table or lines (default: auto)
+  -edit              Open the generated request in $EDITOR before sending it
+  -edit-format <f>   Edit the request as an http message or as json (default: http)
   -version           Show version information
   -help              Show this detailed help message
 
@@ -75,16 +126,78 @@ HISTORY OPTIONS
   -search <term>     Search command history for a term
   -i                 Interactive history selection mode
 
+INTERACTIVE MODE
+  -repl              Start a session that remembers earlier prompts, the last response,
+                     variables and cookies. Type :help in the session for commands such
+                     as :spec, :edit, :rerun, :save, :export and :set
+
+CHAIN OPTIONS
+  -chain             Plan the prompt as several requests, where later requests use
+                     values captured from earlier responses. The plan is shown for
+                     approval before anything is sent
+  -yes               Run the plan without asking for confirmation
+
+BATCH OPTIONS
+  -batch <file>          Run every prompt in a file: one per line, or a YAML list
+                         of prompts or {name, prompt} items. Use - for stdin
+  -batch-output <format> Print results as a table or as JSON lines (default: table)
+  -workers <n>           Number of prompts to run at once (default: 4)
+  -fail-fast             Stop starting new prompts after the first failure
+
+CACHE OPTIONS
+  Translations are cached in ~/.ncurl/cache, keyed on the prompt, model, system
+  prompt version and session, so repeated prompts skip the model.
+  -no-cache          Always ask the model instead of reusing cached translations
+  -cache-ttl <d>     How long cached translations are reused, e.g. 1h or 30m; 0 keeps
+                     them until evicted (default: 24h)
+  -cache-size <n>    Maximum number of cached translations; the oldest are evicted first
+                     (default: 1000)
+
+TRANSLATION OPTIONS
+  Prompts made only of a method, a URL, Name: value headers and a JSON or quoted
+  body, like "post {\"id\": 1} to localhost:8080/items with header X-Key: abc",
+  are translated locally without calling the model or needing an API key.
+  -translator <mode> auto translates simple prompts locally and asks the model for
+                     the rest, model always asks the model, and local never does
+                     (default: auto)
+
+SESSION OPTIONS
+  -session <name>          Keep cookies in a named session across invocations
+  -session-import <file>   Import cookies from a Netscape cookie file into the session
+  -session-export <file>   Export the session's cookies to a Netscape cookie file
+
 EXAMPLES
   # Simple GET request
   ncurl "get the latest weather for London"
 
+  # Fully specified requests are translated without the model
+  ncurl "get https://httpbin.org/get"
+
   # POST with JSON data
   ncurl "post a new user with name 'John' and email 'john@example.com' to jsonplaceholder"
 
+  # Upload a local file as multipart/form-data
+  ncurl "upload ./report.pdf to the documents endpoint on localhost:8080"
+
+  # Send a file or piped stdin as the body (the model only sees a summary)
+  ncurl "post @orders.json to the orders API on localhost:8080"
+  cat data.json | ncurl "post this to the orders API on localhost:8080"
+
   # Specify headers and authentication
   ncurl "get my GitHub repos with authorization token ghp_abc123"
 
+  # Extract just the fields you need; the generated query is printed for reuse
+  ncurl "list open PRs on github.com/golang/go" -extract "just titles and authors as a table"
+  ncurl "list open PRs on github.com/golang/go" -extract '.[] | {title, author: .user.login}'
+
+  # Smoke-test a deployment
+  ncurl "get health of the billing service" -expect status=200 \
+    -expect 'json:.status == "ok"' -expect 'header:Content-Type~json' -expect 'time<500ms'
+  ncurl "get health of the billing service" -expect "healthy and responds quickly"
+
+  # Fix up the generated request by hand before it is sent
+  ncurl -edit "create a user on localhost:8080 with an admin role"
+
   # Use -j flag for JSON-only output (useful for piping to jq)
   ncurl -j "get COVID data for New York" | jq '.cases'
 
@@ -92,8 +205,44 @@ EXAMPLES
   ncurl -history
   ncurl -rerun 3
 
+  # Explore an API interactively, refining each request with follow-ups
+  ncurl -repl -session admin
+
+  # Chain requests, passing values from one response to the next
+  ncurl -chain "log in to localhost:8080 as admin/secret, then create an order \
+    using the returned token, then fetch that order"
+
+  # Run many prompts at once
+  ncurl -batch smoke-tests.yaml -workers 8 -fail
+  ncurl -batch prompts.txt -batch-output jsonl > results.jsonl
+
+  # Log in once, then reuse the session cookie
+  ncurl -session admin "log in to localhost:8080 as admin with password secret"
+  ncurl -session admin "list users on localhost:8080"
+
+EXIT CODES
+  0  Success (any HTTP status unless -fail is set)
+  1  General error: configuration, local files, history, sessions or a failed chain step
+  2  Invalid options or missing prompt
+  3  The model failed or returned an unusable answer
+  4  The generated request is invalid
+  5  Network error: the request could not be sent or the response not read
+  6  Timeout
+  7  HTTP 4xx response (with -fail)
+  8  HTTP 5xx response (with -fail)
+  9  One or more -expect assertions failed
+
+FILES
+  ~/.ncurl/prices.json  Model prices in US dollars per million tokens, overriding the
+                        built-in list prices, e.g. {"claude-3-7-sonnet": {"input": 3, "output": 15}}
+  ~/.ncurl/usage.jsonl  Tokens and estimated cost of every model call, read by ncurl stats
+
 ENVIRONMENT
-  ANTHROPIC_API_KEY  Required API key for the Anthropic Claude API, add this to your .zshrc or .bashrc
+  ANTHROPIC_API_KEY  API key for the Anthropic Claude API, required unless a prompt is translated locally;
+                     add this to your .zshrc or .bashrc
+  ANTHROPIC_BASE_URL Send model requests to another Messages API server, such as
+                     ncurl-mockllm for offline testing
+  NO_COLOR           Disable colored output when -color is auto and it is not empty
 
 For more information on a specific command, run 'ncurl <command> -help'
 `
@@ -123,8 +272,89 @@ func outputJSONOnlyMode(body []byte, isBinary bool) {
 	}
 }
 
+// checkAPIKey reports whether the Anthropic API key is set, explaining how
+// to set it if not
+func checkAPIKey() bool {
+	if os.Getenv("ANTHROPIC_API_KEY") != "" {
+		return true
+	}
+
+	errorLogger.Println("ANTHROPIC_API_KEY environment variable is required")
+	fmt.Println("Error: ANTHROPIC_API_KEY environment variable is required")
+	fmt.Println("Please set it with: export ANTHROPIC_API_KEY=\"your-key-here\"")
+	fmt.Println(
+		"Or for a single command: ANTHROPIC_API_KEY=\"your-key-here\" ncurl \"your query\"",
+	)
+	return false
+}
+
+// needsModel reports whether running a prompt with these payloads calls the
+// model, either to translate it or for a plain English -extract or -expect.
+// With -translator local the model is never needed for translation; prompts
+// it can't handle fail with a clearer error than a missing API key.
+func needsModel(prompt string, payloads []*payload.Payload) bool {
+	if *chainMode || *translator == rules.ModeModel {
+		return true
+	}
+	if *extract != "" {
+		if _, err := query.Parse(*extract); err != nil {
+			return true
+		}
+	}
+	if e, err := parseExpectations(*expect); err == nil && len(e.natural) > 0 {
+		return true
+	}
+	if *translator == rules.ModeLocal {
+		return false
+	}
+	_, err := rules.Parse(prompt)
+	return err != nil || len(payloads) > 0
+}
+
+// parseArgs parses command line flags and returns the positional arguments.
+// Unlike flag.Parse, flags may also follow the prompt, as in
+// ncurl "list users" -extract "names only". Arguments after -- are never
+// treated as flags.
+func parseArgs() []string {
+	var args []string
+	rest := os.Args[1:]
+	for len(rest) > 0 {
+		_ = flag.CommandLine.Parse(rest) // Exits on error
+		remaining := flag.Args()
+
+		consumed := len(rest) - len(remaining)
+		if consumed > 0 && rest[consumed-1] == "--" {
+			args = append(args, remaining...)
+			break
+		}
+		if len(remaining) == 0 {
+			break
+		}
+
+		args = append(args, remaining[0])
+		rest = remaining[1:]
+	}
+	return args
+}
+
+// runSubcommand runs `ncurl cache ls|clear` and `ncurl stats [day|week]`.
+// It reports false when the arguments are a prompt instead.
+func runSubcommand(args []string) (int, bool) {
+	switch {
+	case len(args) == 2 && args[0] == "cache" && (args[1] == "ls" || args[1] == "clear"):
+		return runCacheCommand(args[1]), true
+	case len(args) == 1 && args[0] == "stats":
+		return runStatsCommand(usage.PeriodDay), true
+	case len(args) == 2 && args[0] == "stats" && (args[1] == usage.PeriodDay || args[1] == usage.PeriodWeek):
+		return runStatsCommand(args[1]), true
+	default:
+		return 0, false
+	}
+}
+
 // getPromptString gets the prompt string from history or command line args
 func getPromptString(
+	args []string,
 	historyManager *history.Manager,
 	interactiveHistory bool,
 	historyRerun int,
@@ -137,7 +367,7 @@ func getPromptString(
 		cmd, promptErr := historyManager.PromptForHistorySelection()
 		if promptErr != nil {
 			logger.Printf("Failed to select from history: %v\n", promptErr)
-			*exitCode = 1
+			*exitCode = exitError
 			return "", true
 		}
 		return cmd, false
@@ -147,14 +377,13 @@ func getPromptString(
 		entry, historyErr := historyManager.GetEntryByIndex(historyRerun)
 		if historyErr != nil {
 			logger.Printf("Failed to retrieve history entry: %v\n", historyErr)
-			*exitCode = 1
+			*exitCode = exitError
 			return "", true
 		}
 		return entry.Command, false
 
 	default:
 		// Get command from command line args
-		args := flag.Args()
 		if len(args) < 1 {
 			fmt.Println("usage: ncurl [options] \"<natural language request>\"")
 			fmt.Println("\nExamples:")
@@ -163,37 +392,75 @@ func getPromptString(
 			fmt.Println("\nUse -help for detailed usage information and more examples")
 			fmt.Println("\nOptions:")
 			flag.PrintDefaults()
-			*exitCode = 1
+			*exitCode = exitUsage
 			return "", true
 		}
 		return strings.Join(args, " "), false
 	}
 }
 
-// outputStandardMode outputs the response in standard mode with metadata
-func outputStandardMode(response *httpx.Response, verbose bool, isBinary bool) {
+// outputStandardMode outputs the response in standard mode with metadata.
+// JSON, XML and HTML bodies are pretty-printed unless raw is set.
+func outputStandardMode(response *httpx.Response, verbose bool, isBinary bool, printer *pretty.Printer, raw bool) {
 	// Print metadata and headers
-	fmt.Printf("Status: %s\n", response.Status)
+	fmt.Printf("Status: %s\n", printer.Status(response.Status, response.StatusCode))
 	fmt.Printf("Content-Type: %s\n", response.Header.Get("Content-Type"))
 
 	if verbose {
 		fmt.Println("Headers:")
 		for k, v := range response.Header {
-			fmt.Printf("  %s: %s\n", k, v)
+			fmt.Printf("  %s: %s\n", printer.HeaderName(k), v)
 		}
 	}
 
 	fmt.Println()
 
 	// Print the response body
-	if isBinary {
+	switch {
+	case isBinary:
 		// For binary data, write raw bytes
 		_, _ = os.Stdout.Write(response.Body)
 		fmt.Println() // Add a newline after binary data
-	} else {
+	case raw:
 		// For text data, convert to string
 		fmt.Println(string(response.Body))
+	default:
+		fmt.Println(printer.Body(response.Body, response.Header.Get("Content-Type")))
+	}
+}
+
+// outputExtraction applies an extraction to a JSON response body and prints
+// the results. Text that parses as a query is run directly; anything else is
+// translated into a query by the model, which only sees the response schema.
+// The query used is printed to stderr so it can be reused without the model.
+func outputExtraction(ctx context.Context, client *llm.Client, body []byte, extraction, format string) error {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return fmt.Errorf("response is not JSON: %w", err)
+	}
+
+	q, err := query.Parse(extraction)
+	if err != nil {
+		generated, genErr := client.GenerateExtraction(ctx, extraction, payload.Schema(data))
+		if genErr != nil {
+			return genErr
+		}
+		if q, err = query.Parse(generated.Expression); err != nil {
+			return err
+		}
+		if format == query.FormatAuto {
+			format = generated.Format
+		}
 	}
+
+	fmt.Fprintf(os.Stderr, "Query: %s\n", q)
+
+	results, err := q.Run(data)
+	if err != nil {
+		return err
+	}
+
+	return query.Format(os.Stdout, results, format)
 }
 
 // handleHistoryOperations handles showing and searching command history
@@ -208,7 +475,7 @@ func handleHistoryOperations(
 	if showHistory {
 		if err := historyManager.PrintHistory(); err != nil {
 			logger.Printf("Failed to print history: %v\n", err)
-			*exitCode = 1
+			*exitCode = exitError
 		}
 		return true
 	}
@@ -216,7 +483,7 @@ func handleHistoryOperations(
 	if searchTerm != "" {
 		if err := historyManager.PrintSearchResults(searchTerm); err != nil {
 			logger.Printf("Failed to search history: %v\n", err)
-			*exitCode = 1
+			*exitCode = exitError
 		}
 		return true
 	}
@@ -224,6 +491,115 @@ func handleHistoryOperations(
 	return false
 }
 
+// stdinIsPiped reports whether stdin is a pipe or file rather than a terminal
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// closePayloads removes any temporary files backing the payloads
+func closePayloads(payloads []*payload.Payload) {
+	for _, p := range payloads {
+		_ = p.Close()
+	}
+}
+
+// collectPayloads gathers the @file payloads referenced in the prompt and,
+// if requested, a body piped on stdin
+func collectPayloads(prompt string, readStdin bool) ([]*payload.Payload, error) {
+	var payloads []*payload.Payload
+	for _, ref := range payload.FindReferences(prompt) {
+		p, err := payload.FromFile(ref, ref[1:])
+		if err != nil {
+			closePayloads(payloads)
+			return nil, err
+		}
+		payloads = append(payloads, p)
+	}
+
+	if readStdin && stdinIsPiped() {
+		p, err := payload.FromReader(payload.StdinRef, os.Stdin)
+		switch {
+		case errors.Is(err, payload.ErrEmptyPayload):
+			// Nothing was piped in
+		case err != nil:
+			closePayloads(payloads)
+			return nil, err
+		default:
+			payloads = append(payloads, p)
+		}
+	}
+
+	return payloads, nil
+}
+
+// handleSessionOperations handles importing and exporting session cookie files
+// Returns true if a session operation was executed (indicating the caller should return)
+func handleSessionOperations(
+	logger *log.Logger,
+	name string,
+	importFile string,
+	exportFile string,
+	exitCode *int,
+) bool {
+	if importFile == "" && exportFile == "" {
+		return false
+	}
+
+	if name == "" {
+		logger.Println("-session-import and -session-export require -session <name>")
+		*exitCode = exitError
+		return true
+	}
+
+	sessionManager, err := session.NewManager()
+	if err != nil {
+		logger.Printf("Failed to initialize sessions: %v\n", err)
+		*exitCode = exitError
+		return true
+	}
+
+	if importFile != "" {
+		count, importErr := sessionManager.Import(name, importFile)
+		if importErr != nil {
+			logger.Printf("Failed to import cookies: %v\n", importErr)
+			*exitCode = exitError
+			return true
+		}
+		fmt.Printf("Imported %d cookies into session %s\n", count, name)
+	}
+
+	if exportFile != "" {
+		count, exportErr := sessionManager.Export(name, exportFile)
+		if exportErr != nil {
+			logger.Printf("Failed to export cookies: %v\n", exportErr)
+			*exitCode = exitError
+			return true
+		}
+		fmt.Printf("Exported %d cookies from session %s to %s\n", count, name, exportFile)
+	}
+
+	return true
+}
+
+// loadSession loads the cookie jar for a named session
+func loadSession(name string) (*session.Manager, *session.Jar, error) {
+	sessionManager, err := session.NewManager()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jar, err := sessionManager.Load(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sessionManager, jar, nil
+}
+
 func main() {
 	// Set up exit code handling more cleanly
 	var exitCode int
@@ -237,7 +613,13 @@ func main() {
 		return
 	}
 
-	flag.Parse()
+	args := parseArgs()
+
+	// Manage the translation cache or show usage statistics
+	if code, ok := runSubcommand(args); ok {
+		exitCode = code
+		return
+	}
 
 	// Initialize history manager
 	historyManager, err := history.NewManager(*historyCount)
@@ -271,8 +653,50 @@ func main() {
 		return
 	}
 
+	// Handle session cookie import/export
+	if handleSessionOperations(errorLogger, *sessionName, *sessionImport, *sessionExport, &exitCode) {
+		return
+	}
+
+	if modeErr := rules.ValidateMode(*translator); modeErr != nil {
+		errorLogger.Printf("%v\n", modeErr)
+		exitCode = exitUsage
+		return
+	}
+
+	if _, expectErr := parseExpectations(*expect); expectErr != nil {
+		errorLogger.Printf("%v\n", expectErr)
+		exitCode = exitUsage
+		return
+	}
+
+	// Run every prompt in a batch file
+	if *batchFile != "" {
+		exitCode = runBatch(*batchFile)
+		return
+	}
+
+	if formatErr := editor.ValidateFormat(*editFormat); formatErr != nil {
+		errorLogger.Printf("%v\n", formatErr)
+		exitCode = exitUsage
+		return
+	}
+
+	// Start an interactive session
+	if *replMode {
+		useColor, colorErr := pretty.ColorEnabled(*colorMode, os.Stdout)
+		if colorErr != nil {
+			errorLogger.Printf("%v\n", colorErr)
+			exitCode = exitUsage
+			return
+		}
+		exitCode = runREPL(pretty.NewPrinter(useColor))
+		return
+	}
+
 	// Get the command to execute - either from history, interactive selection, or command line args
 	prompt, shouldReturn := getPromptString(
+		args,
 		historyManager,
 		*interactiveHistory,
 		*historyRerun,
@@ -283,40 +707,117 @@ func main() {
 		return
 	}
 
-	// Ensure API key is set
-	if os.Getenv("ANTHROPIC_API_KEY") == "" {
-		errorLogger.Println("ANTHROPIC_API_KEY environment variable is required")
-		fmt.Println("Error: ANTHROPIC_API_KEY environment variable is required")
-		fmt.Println("Please set it with: export ANTHROPIC_API_KEY=\"your-key-here\"")
-		fmt.Println(
-			"Or for a single command: ANTHROPIC_API_KEY=\"your-key-here\" ncurl \"your query\"",
-		)
-		exitCode = 1
+	// Decide on colored output before doing any work
+	useColor, err := pretty.ColorEnabled(*colorMode, os.Stdout)
+	if err != nil {
+		errorLogger.Printf("%v\n", err)
+		exitCode = exitUsage
+		return
+	}
+
+	if formatErr := query.ValidateFormat(*extractFormat); formatErr != nil {
+		errorLogger.Printf("%v\n", formatErr)
+		exitCode = exitUsage
 		return
 	}
 
-	// Record command in history when exiting
+	// A -chain plan always needs the model
+	if *chainMode && !checkAPIKey() {
+		exitCode = exitError
+		return
+	}
+
+	// Record command in history when exiting. A request only counts as a
+	// success if it completed with a non-error HTTP status.
+	var statusCode int
+	var calls []usage.Record
 	defer func() {
 		if historyManager != nil {
-			_ = historyManager.AddEntry(prompt, exitCode == 0)
+			_ = historyManager.Add(withUsage(history.Entry{
+				Command:    prompt,
+				Success:    exitCode == exitOK && statusCode > 0 && statusCode < 400,
+				StatusCode: statusCode,
+			}, calls))
 		}
 	}()
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*timeout)*time.Second)
-	defer cancel()
+	// Create context with timeout for translating the prompt. The request
+	// gets a fresh one, so time spent in -edit doesn't count against it.
+	requestTimeout := time.Duration(*timeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer func() { cancel() }()
 
-	// Generate request spec from natural language
+	// Log what the model calls cost once everything is done
 	client := llm.NewClient(*model)
-	spec, err := client.GenerateRequestSpec(ctx, prompt)
+	defer func() {
+		calls = recordUsage(client)
+		if *verbose && len(calls) > 1 {
+			fmt.Fprintf(os.Stderr, "Model usage: %d calls, %s\n", len(calls), describeUsage(calls))
+		}
+	}()
+
+	// Plan and run a multi-step workflow
+	if *chainMode {
+		exitCode, statusCode = runChain(client, prompt, pretty.NewPrinter(useColor))
+		return
+	}
+
+	// Collect request bodies from @file references, and from piped stdin
+	// when the prompt refers to it or -stdin is set
+	useStdin := (*readStdin || payload.ReferencesStdin(prompt)) && !*noStdin && !*interactiveHistory
+	payloads, err := collectPayloads(prompt, useStdin)
+	if err != nil {
+		errorLogger.Printf("Failed to read payload: %v\n", err)
+		exitCode = exitError
+		return
+	}
+	defer closePayloads(payloads)
+
+	// Ensure API key is set, unless the prompt is simple enough to run without the model
+	if needsModel(prompt, payloads) && !checkAPIKey() {
+		exitCode = exitError
+		return
+	}
+
+	// Generate request spec from natural language, reuse a cached one, or
+	// translate a simple prompt locally
+	spec, source, err := translatePrompt(ctx, client, openCache(), prompt, payloads)
 	if err != nil {
 		errorLogger.Printf("Failed to generate request: %v\n", err)
-		exitCode = 1
+		exitCode = llmExitCode(ctx, err)
 		return
 	}
 
+	// Point payload references at the files that hold their bytes
+	if resolveErr := payload.Resolve(spec, prompt, payloads); resolveErr != nil {
+		errorLogger.Printf("Invalid request: %v\n", resolveErr)
+		exitCode = exitInvalidSpec
+		return
+	}
+
+	// Let the user fix up the request before it is sent
+	if *editRequest {
+		edited, editErr := editor.New(editor.WithFormat(*editFormat)).Edit(spec)
+		if editErr != nil {
+			errorLogger.Printf("Failed to edit request: %v\n", editErr)
+			exitCode = exitError
+			return
+		}
+		spec = edited
+	}
+
 	if *verbose {
-		fmt.Printf("Request: %s %s\n", spec.Method, spec.URL)
+		note := ""
+		switch source {
+		case fromCache:
+			note = " (cached translation)"
+		case fromRules:
+			note = " (translated locally)"
+		}
+		fmt.Printf("Request: %s %s%s\n", spec.Method, spec.URL, note)
+		if source == fromModel {
+			fmt.Printf("Model usage: %s\n", describeUsage(priced(client.Usage(), loadPrices())))
+		}
 		if len(spec.Headers) > 0 {
 			fmt.Println("Headers:")
 			for k, v := range spec.Headers {
@@ -326,37 +827,103 @@ func main() {
 		if spec.Body != "" {
 			fmt.Println("Body:", spec.Body)
 		}
+		if spec.BodyFile != "" {
+			fmt.Println("Body file:", spec.BodyFile)
+		}
+		if len(spec.Form) > 0 {
+			fmt.Println("Form:")
+			for k, v := range spec.Form {
+				fmt.Printf("  %s: %s\n", k, v)
+			}
+		}
+		if len(spec.Files) > 0 {
+			fmt.Println("Files:")
+			for _, f := range spec.Files {
+				fmt.Printf("  %s: %s\n", f.Field, f.Path)
+			}
+		}
 		fmt.Println()
 	}
 
+	// Attach the session cookie jar if one was requested
+	var execOpts []httpx.ExecuteOption
+	if *sessionName != "" {
+		sessionManager, jar, sessionErr := loadSession(*sessionName)
+		if sessionErr != nil {
+			errorLogger.Printf("Failed to load session: %v\n", sessionErr)
+			exitCode = exitError
+			return
+		}
+		execOpts = append(execOpts, httpx.WithCookieJar(jar))
+
+		// Persist cookies set by the server, even for failed requests
+		defer func() {
+			if saveErr := sessionManager.Save(*sessionName, jar); saveErr != nil {
+				errorLogger.Printf("Warning: Could not save session: %v\n", saveErr)
+			}
+		}()
+	}
+
 	// Execute the request with context for cancellation/timeout
-	response, err := httpx.ExecuteWithContext(ctx, spec)
+	cancel()
+	ctx, cancel = context.WithTimeout(context.Background(), requestTimeout)
+	response, err := httpx.ExecuteWithContext(ctx, spec, execOpts...)
 
 	if err != nil {
 		var reqErr *httpx.RequestError
 
 		switch {
+		case isTimeout(ctx, err):
+			errorLogger.Printf("Request timed out: %v\n", err)
 		case errors.As(err, &reqErr):
 			errorLogger.Printf("Request error: %v\n", reqErr)
 		case errors.Is(err, httpx.ErrInvalidRequest):
 			errorLogger.Printf("Invalid request: %v\n", err)
-		case errors.Is(ctx.Err(), context.DeadlineExceeded):
-			errorLogger.Printf("Request timed out after %d seconds\n", *timeout)
 		default:
 			errorLogger.Printf("Request failed: %v\n", err)
 		}
 
-		exitCode = 1
+		exitCode = requestExitCode(ctx, err)
 		return
 	}
+	statusCode = response.StatusCode
 
 	// Determine content type and handle output appropriately
 	contentType := response.Header.Get("Content-Type")
 	isBinary := isContentBinary(contentType)
 
-	if *jsonOnly {
+	switch {
+	case *extract != "":
+		if extractErr := outputExtraction(ctx, client, response.Body, *extract, *extractFormat); extractErr != nil {
+			errorLogger.Printf("Failed to extract data: %v\n", extractErr)
+			exitCode = exitError
+			var modelErr *llm.ModelError
+			if errors.As(extractErr, &modelErr) {
+				exitCode = llmExitCode(ctx, extractErr)
+			}
+		}
+	case *jsonOnly:
 		outputJSONOnlyMode(response.Body, isBinary)
-	} else {
-		outputStandardMode(response, *verbose, isBinary)
+	default:
+		outputStandardMode(response, *verbose, isBinary, pretty.NewPrinter(useColor), *rawOutput)
+	}
+
+	// With -fail, HTTP errors are reported through the exit code
+	if *failOnHTTPError && exitCode == exitOK {
+		exitCode = statusExitCode(response.StatusCode)
+	}
+
+	// Check assertions last so the report follows the response
+	if len(*expect) > 0 {
+		expectations, _ := parseExpectations(*expect) // Checked before the request
+		assertions, compileErr := expectations.compile(ctx, client, response, os.Stderr)
+		if compileErr != nil {
+			errorLogger.Printf("Failed to compile expectation: %v\n", compileErr)
+			exitCode = llmExitCode(ctx, compileErr)
+			return
+		}
+		if !assert.Report(os.Stderr, assert.CheckAll(assertions, response)) && exitCode == exitOK {
+			exitCode = exitAssertion
+		}
 	}
 }
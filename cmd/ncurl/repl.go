@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/editor"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/payload"
+	"github.com/stephenbyrne99/ncurl/internal/pretty"
+	"github.com/stephenbyrne99/ncurl/internal/repl"
+)
+
+// runREPL starts an interactive session and returns the exit code
+func runREPL(printer *pretty.Printer) int {
+	if !checkAPIKey() {
+		return exitError
+	}
+
+	// Cookies persist for the whole session, and across sessions with -session
+	jar, saveJar, err := openJar(*sessionName)
+	if err != nil {
+		errorLogger.Printf("Failed to load session: %v\n", err)
+		return exitError
+	}
+	defer saveJar()
+
+	client := llm.NewClient(*model)
+	defer recordUsage(client)
+	conversation := client.NewConversation()
+
+	// Requests may only read files the user named, in a prompt or an edit
+	var named []string
+	edit := editor.New(editor.WithFormat(*editFormat)).Edit
+	r := repl.New(os.Stdout, repl.Options{
+		Translate: conversation.GenerateRequestSpec,
+		Execute: func(ctx context.Context, spec *httpx.RequestSpec) (*httpx.Response, error) {
+			if err := payload.CheckPaths(spec, named...); err != nil {
+				return nil, err
+			}
+			return httpx.ExecuteWithContext(ctx, spec, httpx.WithCookieJar(jar))
+		},
+		Print: func(resp *httpx.Response) {
+			isBinary := isContentBinary(resp.Header.Get("Content-Type"))
+			if *jsonOnly {
+				outputJSONOnlyMode(resp.Body, isBinary)
+				return
+			}
+			outputStandardMode(resp, *verbose, isBinary, printer, *rawOutput)
+		},
+		Edit: func(spec *httpx.RequestSpec) (*httpx.RequestSpec, error) {
+			edited, err := edit(spec)
+			if err == nil {
+				named = append(named, filePaths(edited)...)
+			}
+			return edited, err
+		},
+		Reset: conversation.Reset,
+		Prompted: func(prompt string) {
+			named = append(named, prompt)
+		},
+		Timeout: time.Duration(*timeout) * time.Second,
+	})
+
+	fmt.Fprintln(os.Stderr, "ncurl interactive mode. Type :help for commands, :quit to leave.")
+	if runErr := r.Run(context.Background(), os.Stdin); runErr != nil {
+		errorLogger.Printf("Failed to read input: %v\n", runErr)
+		return exitError
+	}
+	return exitOK
+}
+
+// filePaths returns the local files a request reads, quoted so each one
+// counts as named by the user
+func filePaths(spec *httpx.RequestSpec) []string {
+	var paths []string
+	if spec.BodyFile != "" {
+		paths = append(paths, `"`+spec.BodyFile+`"`)
+	}
+	for _, f := range spec.Files {
+		paths = append(paths, `"`+f.Path+`"`)
+	}
+	return paths
+}
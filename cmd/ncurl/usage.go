@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/history"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/usage"
+)
+
+// How far back `ncurl stats` looks for each period
+const (
+	statsDays  = 14
+	statsWeeks = 12
+)
+
+// loadPrices reads the price table, overriding the defaults with
+// ~/.ncurl/prices.json when it exists
+func loadPrices() usage.Prices {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return usage.DefaultPrices
+	}
+
+	prices, err := usage.LoadPrices(filepath.Join(home, ".ncurl", "prices.json"))
+	if err != nil {
+		errorLogger.Printf("Warning: %v; using default prices\n", err)
+		return usage.DefaultPrices
+	}
+	return prices
+}
+
+// priced returns records with their estimated cost filled in
+func priced(records []usage.Record, prices usage.Prices) []usage.Record {
+	out := make([]usage.Record, len(records))
+	for i, r := range records {
+		if cost, ok := prices.Cost(r.Tokens); ok {
+			r.Cost = &cost
+		}
+		out[i] = r
+	}
+	return out
+}
+
+// recordUsage prices the model calls a client made and appends them to the
+// usage log. It returns the priced records.
+func recordUsage(client *llm.Client) []usage.Record {
+	records := client.Usage()
+	if len(records) == 0 {
+		return nil
+	}
+	records = priced(records, loadPrices())
+
+	usageLog, err := usage.NewLog()
+	if err == nil {
+		err = usageLog.Append(records...)
+	}
+	if err != nil {
+		errorLogger.Printf("Warning: Could not record model usage: %v\n", err)
+	}
+	return records
+}
+
+// describeUsage summarizes the tokens and estimated cost of model calls
+func describeUsage(records []usage.Record) string {
+	var input, output int64
+	var cost float64
+	unpriced := 0
+	for _, r := range records {
+		input += r.Input
+		output += r.Output
+		if r.Cost == nil {
+			unpriced++
+		} else {
+			cost += *r.Cost
+		}
+	}
+
+	text := fmt.Sprintf("%d input + %d output tokens", input, output)
+	switch {
+	case unpriced == 0:
+		return text + fmt.Sprintf(", ~$%.4f", cost)
+	case unpriced == len(records):
+		return text + ", cost unknown (no price for the model)"
+	default:
+		return text + fmt.Sprintf(", ~$%.4f (%d of %d calls unpriced)", cost, unpriced, len(records))
+	}
+}
+
+// withUsage adds the model and token totals of an invocation to a history entry
+func withUsage(entry history.Entry, records []usage.Record) history.Entry {
+	for _, r := range records {
+		entry.Model = r.Model
+		entry.InputTokens += r.Input
+		entry.OutputTokens += r.Output
+		if r.Cost != nil {
+			entry.Cost += *r.Cost
+		}
+	}
+	return entry
+}
+
+// runStatsCommand prints model usage totals by day or week and model
+func runStatsCommand(period string) int {
+	usageLog, err := usage.NewLog()
+	if err != nil {
+		errorLogger.Printf("Failed to open usage log: %v\n", err)
+		return exitError
+	}
+	records, err := usageLog.Records()
+	if err != nil {
+		errorLogger.Printf("Failed to read usage log: %v\n", err)
+		return exitError
+	}
+
+	// Only summarize recent periods
+	since := time.Now().AddDate(0, 0, -statsDays)
+	if period == usage.PeriodWeek {
+		since = time.Now().AddDate(0, 0, -7*statsWeeks)
+	}
+	recent := records[:0]
+	for _, r := range records {
+		if r.Time.After(since) {
+			recent = append(recent, r)
+		}
+	}
+
+	totals, err := usage.Summarize(recent, period)
+	if err != nil {
+		errorLogger.Printf("%v\n", err)
+		return exitUsage
+	}
+	if len(totals) == 0 {
+		fmt.Println("No model usage recorded")
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tMODEL\tCALLS\tINPUT\tOUTPUT\tCOST\n", strings.ToUpper(period))
+	for _, t := range totals {
+		cost := fmt.Sprintf("$%.4f", t.Cost)
+		switch {
+		case t.Unknown == t.Calls:
+			cost = "unknown"
+		case t.Unknown > 0:
+			cost += fmt.Sprintf(" (%d unpriced)", t.Unknown)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", t.Period, t.Model, t.Calls, t.Input, t.Output, cost)
+	}
+	if flushErr := w.Flush(); flushErr != nil {
+		errorLogger.Printf("Failed to print usage: %v\n", flushErr)
+		return exitError
+	}
+
+	fmt.Printf("\nTotal: %d calls, %s\n", len(recent), describeUsage(recent))
+	return exitOK
+}
@@ -2,7 +2,10 @@ module github.com/stephenbyrne99/ncurl
 
 go 1.22
 
-require github.com/anthropics/anthropic-sdk-go v0.2.0-beta.3
+require (
+	github.com/anthropics/anthropic-sdk-go v0.2.0-beta.3
+	golang.org/x/net v0.27.0
+)
 
 require (
 	github.com/tidwall/gjson v1.14.4 // indirect
@@ -0,0 +1,439 @@
+// Package assert checks HTTP responses against simple assertions such as
+// status=200, json:.status == "ok", header:Content-Type~json or time<500ms.
+package assert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/query"
+)
+
+// Subjects an assertion can check
+const (
+	SubjectStatus = "status"
+	SubjectJSON   = "json"
+	SubjectHeader = "header"
+	SubjectTime   = "time"
+	SubjectBody   = "body"
+)
+
+// ErrInvalidAssertion is returned for assertions that cannot be parsed
+var ErrInvalidAssertion = errors.New("invalid assertion")
+
+// Operators, longest first so that "<=" is matched before "<"
+var operators = []string{"==", "!=", "<=", ">=", "!~", "=", "<", ">", "~"}
+
+// maxActualLength limits how much of an actual value is shown in a report
+const maxActualLength = 60
+
+// Assertion is a single parsed check
+type Assertion struct {
+	Source  string // The assertion as written
+	Subject string // One of the Subject constants
+	Target  string // Header name or query expression
+	Op      string // Comparison operator; empty means "present" or "truthy"
+	Value   string // Expected value as written
+
+	query    *query.Query
+	pattern  *regexp.Regexp
+	expected interface{}
+	status   int
+	class    int // Status class for values like 2xx
+	duration time.Duration
+}
+
+// Result is the outcome of checking one assertion
+type Result struct {
+	Assertion *Assertion
+	Passed    bool
+	Actual    string // What the response contained, for the report
+}
+
+// Parse parses an assertion. Supported forms:
+//
+//	status=200  status!=500  status<400  status=2xx
+//	json:.path == "value"  json:.items | length > 0  json:.id
+//	header:Content-Type~json  header:X-Request-Id  header:Cache-Control=no-store
+//	time<500ms  time<=2s
+//	body~healthy  body!~error
+func Parse(source string) (*Assertion, error) {
+	text := strings.TrimSpace(source)
+	a := &Assertion{Source: text}
+
+	var err error
+	switch {
+	case strings.HasPrefix(text, "json:"):
+		a.Subject = SubjectJSON
+		err = a.parseJSON(strings.TrimPrefix(text, "json:"))
+	case strings.HasPrefix(text, "header:"):
+		a.Subject = SubjectHeader
+		err = a.parseHeader(strings.TrimPrefix(text, "header:"))
+	case strings.HasPrefix(text, SubjectStatus):
+		a.Subject = SubjectStatus
+		err = a.parseStatus(strings.TrimPrefix(text, SubjectStatus))
+	case strings.HasPrefix(text, SubjectTime):
+		a.Subject = SubjectTime
+		err = a.parseTime(strings.TrimPrefix(text, SubjectTime))
+	case strings.HasPrefix(text, SubjectBody):
+		a.Subject = SubjectBody
+		err = a.parseBody(strings.TrimPrefix(text, SubjectBody))
+	default:
+		err = errors.New("expected status, json:, header:, time or body")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidAssertion, text, err)
+	}
+	return a, nil
+}
+
+// HasSubject reports whether source is written as an assertion: it starts
+// with json: or header:, or with status, time or body and then an operator.
+// Text that doesn't, like "status is ok", is natural language.
+func HasSubject(source string) bool {
+	text := strings.TrimSpace(source)
+	if strings.HasPrefix(text, "json:") || strings.HasPrefix(text, "header:") {
+		return true
+	}
+	for _, subject := range []string{SubjectStatus, SubjectTime, SubjectBody} {
+		if rest, ok := strings.CutPrefix(text, subject); ok {
+			rest = strings.TrimSpace(rest)
+			return rest == "" || strings.ContainsAny(rest[:1], "=!<>~")
+		}
+	}
+	return false
+}
+
+// splitOperator splits "op value" at the start of s
+func splitOperator(s string) (string, string, bool) {
+	s = strings.TrimSpace(s)
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op, strings.TrimSpace(s[len(op):]), true
+		}
+	}
+	return "", "", false
+}
+
+// allowOperator checks op against the operators a subject supports
+func allowOperator(op string, allowed ...string) error {
+	for _, a := range allowed {
+		if op == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("operator %q is not supported here (use %s)", op, strings.Join(allowed, " "))
+}
+
+func (a *Assertion) parseStatus(rest string) error {
+	op, value, ok := splitOperator(rest)
+	if !ok || value == "" {
+		return errors.New("expected an operator and a status code, e.g. status=200")
+	}
+	if op == "=" {
+		op = "=="
+	}
+	a.Op, a.Value = op, value
+
+	if len(value) == 3 && strings.HasSuffix(strings.ToLower(value), "xx") {
+		class, err := strconv.Atoi(value[:1])
+		if err != nil {
+			return fmt.Errorf("invalid status class %q", value)
+		}
+		a.class = class
+		return allowOperator(op, "==", "!=")
+	}
+
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid status code %q", value)
+	}
+	a.status = code
+	return allowOperator(op, "==", "!=", "<", "<=", ">", ">=")
+}
+
+func (a *Assertion) parseTime(rest string) error {
+	op, value, ok := splitOperator(rest)
+	if !ok || value == "" {
+		return errors.New("expected an operator and a duration, e.g. time<500ms")
+	}
+	if err := allowOperator(op, "<", "<=", ">", ">="); err != nil {
+		return err
+	}
+	a.Op, a.Value = op, value
+
+	// A bare number is taken as milliseconds
+	if ms, err := strconv.Atoi(value); err == nil {
+		a.duration = time.Duration(ms) * time.Millisecond
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", value)
+	}
+	a.duration = d
+	return nil
+}
+
+func (a *Assertion) parseBody(rest string) error {
+	op, value, ok := splitOperator(rest)
+	if !ok || value == "" {
+		return errors.New("expected ~ or !~ and a pattern, e.g. body~healthy")
+	}
+	if err := allowOperator(op, "~", "!~"); err != nil {
+		return err
+	}
+	a.Op, a.Value = op, value
+	return a.compilePattern(value)
+}
+
+func (a *Assertion) parseHeader(rest string) error {
+	end := strings.IndexAny(rest, "=!<>~")
+	if end < 0 {
+		end = len(rest)
+	}
+	a.Target = strings.TrimSpace(rest[:end])
+	if a.Target == "" {
+		return errors.New("missing header name")
+	}
+	if end == len(rest) {
+		return nil // Presence check
+	}
+
+	op, value, ok := splitOperator(rest[end:])
+	if !ok {
+		return fmt.Errorf("invalid operator in %q", rest)
+	}
+	if op == "=" {
+		op = "=="
+	}
+	if err := allowOperator(op, "==", "!=", "~", "!~"); err != nil {
+		return err
+	}
+	a.Op, a.Value = op, value
+	if op == "~" || op == "!~" {
+		return a.compilePattern(value)
+	}
+	return nil
+}
+
+func (a *Assertion) parseJSON(rest string) error {
+	expression, op, value := rest, "", ""
+	if idx, found := firstOperator(rest); idx >= 0 {
+		expression, op, value = rest[:idx], found, strings.TrimSpace(rest[idx+len(found):])
+	}
+
+	q, err := query.Parse(strings.TrimSpace(expression))
+	if err != nil {
+		return err
+	}
+	a.query = q
+	a.Target = q.String()
+
+	if op == "" {
+		return nil // Truthiness check
+	}
+	if op == "=" {
+		op = "=="
+	}
+	a.Op, a.Value = op, value
+
+	if op == "~" || op == "!~" {
+		return a.compilePattern(value)
+	}
+
+	// Values are JSON literals; anything else is taken as a bare string
+	if unmarshalErr := json.Unmarshal([]byte(value), &a.expected); unmarshalErr != nil {
+		a.expected = value
+	}
+	return nil
+}
+
+// firstOperator finds the first comparison operator outside brackets and
+// strings, so that operators inside select(...) stay part of the query
+func firstOperator(s string) (int, string) {
+	depth, inString := 0, false
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case strings.IndexByte("([{", c) >= 0:
+			depth++
+		case strings.IndexByte(")]}", c) >= 0:
+			depth--
+		case depth == 0 && strings.IndexByte("=!<>~", c) >= 0:
+			for _, op := range operators {
+				if strings.HasPrefix(s[i:], op) {
+					return i, op
+				}
+			}
+		}
+	}
+
+	return -1, ""
+}
+
+func (a *Assertion) compilePattern(value string) error {
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", value, err)
+	}
+	a.pattern = pattern
+	return nil
+}
+
+// Check evaluates the assertion against a response
+func (a *Assertion) Check(resp *httpx.Response) Result {
+	switch a.Subject {
+	case SubjectStatus:
+		return a.checkStatus(resp.StatusCode)
+	case SubjectTime:
+		return a.checkTime(resp.Duration)
+	case SubjectBody:
+		return a.result(a.match(string(resp.Body)), truncate(string(resp.Body)))
+	case SubjectHeader:
+		return a.checkHeader(resp)
+	default:
+		return a.checkJSON(resp.Body)
+	}
+}
+
+// CheckAll evaluates every assertion against a response
+func CheckAll(assertions []*Assertion, resp *httpx.Response) []Result {
+	results := make([]Result, 0, len(assertions))
+	for _, a := range assertions {
+		results = append(results, a.Check(resp))
+	}
+	return results
+}
+
+func (a *Assertion) result(passed bool, actual string) Result {
+	return Result{Assertion: a, Passed: passed, Actual: actual}
+}
+
+func (a *Assertion) checkStatus(code int) Result {
+	actual := strconv.Itoa(code)
+	if a.class > 0 {
+		inClass := code/100 == a.class
+		return a.result(inClass == (a.Op == "=="), actual)
+	}
+	return a.result(compareInts(code, a.Op, a.status), actual)
+}
+
+func (a *Assertion) checkTime(d time.Duration) Result {
+	return a.result(compareInts(int(d), a.Op, int(a.duration)), d.Round(time.Millisecond).String())
+}
+
+func (a *Assertion) checkHeader(resp *httpx.Response) Result {
+	values, present := resp.Header[textproto.CanonicalMIMEHeaderKey(a.Target)]
+	actual := strings.Join(values, ", ")
+
+	switch a.Op {
+	case "":
+		return a.result(present, "missing")
+	case "==":
+		return a.result(present && actual == a.Value, actual)
+	case "!=":
+		return a.result(actual != a.Value, actual)
+	default:
+		return a.result(a.match(actual), actual)
+	}
+}
+
+func (a *Assertion) checkJSON(body []byte) Result {
+	results, err := a.query.RunJSON(body)
+	if err != nil {
+		return a.result(false, err.Error())
+	}
+
+	var value interface{}
+	if len(results) > 0 {
+		value = results[0]
+	}
+	encoded, _ := json.Marshal(value)
+	actual := truncate(string(encoded))
+
+	switch a.Op {
+	case "":
+		return a.result(value != nil && value != false, actual)
+	case "~", "!~":
+		text, isString := value.(string)
+		if !isString {
+			text = string(encoded)
+		}
+		return a.result(a.match(text), actual)
+	default:
+		passed, compareErr := query.Compare(value, a.Op, a.expected)
+		if compareErr != nil {
+			return a.result(false, compareErr.Error())
+		}
+		return a.result(passed, actual)
+	}
+}
+
+// match applies a ~ or !~ pattern
+func (a *Assertion) match(s string) bool {
+	return a.pattern.MatchString(s) == (a.Op == "~")
+}
+
+func compareInts(actual int, op string, expected int) bool {
+	switch op {
+	case "==":
+		return actual == expected
+	case "!=":
+		return actual != expected
+	case "<":
+		return actual < expected
+	case "<=":
+		return actual <= expected
+	case ">":
+		return actual > expected
+	case ">=":
+		return actual >= expected
+	default:
+		return false
+	}
+}
+
+// truncate shortens an actual value for the report
+func truncate(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= maxActualLength {
+		return s
+	}
+	return s[:maxActualLength] + "..."
+}
+
+// Report writes a pass/fail line per result and a summary. It reports
+// whether every assertion passed.
+func Report(w io.Writer, results []Result) bool {
+	passed := 0
+	fmt.Fprintln(w, "Assertions:")
+	for _, r := range results {
+		if r.Passed {
+			passed++
+			fmt.Fprintf(w, "  ✓ %s\n", r.Assertion.Source)
+			continue
+		}
+		fmt.Fprintf(w, "  ✗ %s (got %s)\n", r.Assertion.Source, r.Actual)
+	}
+	fmt.Fprintf(w, "%d of %d assertions passed\n", passed, len(results))
+	return passed == len(results)
+}
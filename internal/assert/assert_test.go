@@ -0,0 +1,143 @@
+package assert_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/assert"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+func testResponse() *httpx.Response {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("X-Request-Id", "abc123")
+
+	return &httpx.Response{
+		Response: &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: header},
+		Body:     []byte(`{"status": "ok", "version": "1.4.2", "checks": [{"name": "db", "up": true}], "uptime": 3600}`),
+		Duration: 120 * time.Millisecond,
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		assertion string
+		passed    bool
+	}{
+		{assertion: "status=200", passed: true},
+		{assertion: "status==201", passed: false},
+		{assertion: "status!=500", passed: true},
+		{assertion: "status<400", passed: true},
+		{assertion: "status=2xx", passed: true},
+		{assertion: "status=5xx", passed: false},
+		{assertion: `json:.status == "ok"`, passed: true},
+		{assertion: `json:.status == "degraded"`, passed: false},
+		{assertion: "json:.status == ok", passed: true},
+		{assertion: "json:.uptime > 60", passed: true},
+		{assertion: "json:.checks | length >= 1", passed: true},
+		{assertion: `json:.checks[] | select(.name == "db") | .up == true`, passed: true},
+		{assertion: `json:.version ~ ^1\.4`, passed: true},
+		{assertion: "json:.checks[0].up", passed: true},
+		{assertion: "json:.missing", passed: false},
+		{assertion: "header:Content-Type~json", passed: true},
+		{assertion: "header:content-type~xml", passed: false},
+		{assertion: "header:X-Request-Id", passed: true},
+		{assertion: "header:X-Request-Id=abc123", passed: true},
+		{assertion: "header:Retry-After", passed: false},
+		{assertion: "time<500ms", passed: true},
+		{assertion: "time<100", passed: false},
+		{assertion: "time>=1s", passed: false},
+		{assertion: "body~\"status\"", passed: true},
+		{assertion: "body!~error", passed: true},
+	}
+
+	resp := testResponse()
+	for _, tc := range testCases {
+		t.Run(tc.assertion, func(t *testing.T) {
+			a, err := assert.Parse(tc.assertion)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			result := a.Check(resp)
+			if result.Passed != tc.passed {
+				t.Errorf("Expected passed=%v, got %v (actual %s)", tc.passed, result.Passed, result.Actual)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	invalid := []string{
+		"the service is healthy",
+		"status",
+		"status=abc",
+		"status<2xx",
+		"time<soon",
+		"time=5ms",
+		"body=ok",
+		"header:",
+		"json:.items[",
+		"body~[",
+	}
+
+	for _, text := range invalid {
+		if _, err := assert.Parse(text); !errors.Is(err, assert.ErrInvalidAssertion) {
+			t.Errorf("Expected ErrInvalidAssertion for %q, got %v", text, err)
+		}
+	}
+}
+
+func TestHasSubject(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"status=abc", true},
+		{"time<", true},
+		{"json:.items[", true},
+		{"header:", true},
+		{"body ~ ok", true},
+		{"status", true},
+		{"status is ok", false},
+		{"timeout under 2s", false},
+		{"the service is healthy", false},
+	}
+
+	for _, tt := range tests {
+		if got := assert.HasSubject(tt.text); got != tt.want {
+			t.Errorf("HasSubject(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	var assertions []*assert.Assertion
+	for _, text := range []string{"status=200", `json:.status == "down"`} {
+		a, err := assert.Parse(text)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		assertions = append(assertions, a)
+	}
+
+	var buf bytes.Buffer
+	if assert.Report(&buf, assert.CheckAll(assertions, testResponse())) {
+		t.Error("Expected the report to fail")
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "✓ status=200") {
+		t.Errorf("Expected passing assertion in report, got:\n%s", out)
+	}
+	if !strings.Contains(out, `✗ json:.status == "down" (got "ok")`) {
+		t.Errorf("Expected failing assertion with actual value in report, got:\n%s", out)
+	}
+	if !strings.Contains(out, "1 of 2 assertions passed") {
+		t.Errorf("Expected summary in report, got:\n%s", out)
+	}
+}
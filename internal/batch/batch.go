@@ -0,0 +1,375 @@
+// Package batch loads prompt files and runs their prompts with a bounded
+// worker pool
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+// Output formats accepted by ValidateFormat
+const (
+	FormatTable = "table"
+	FormatJSONL = "jsonl"
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrNoPrompts     = errors.New("no prompts in batch file")
+	ErrInvalidFile   = errors.New("invalid batch file")
+	ErrInvalidFormat = errors.New("invalid batch output format")
+)
+
+// Item is a single prompt from a batch file
+type Item struct {
+	Line   int    // Line of the file the prompt starts on
+	Name   string // Optional name from a YAML file
+	Prompt string
+}
+
+// Result is the outcome of running one item
+type Result struct {
+	Item     Item
+	Spec     *httpx.RequestSpec
+	Status   int           // HTTP status code, if the request completed
+	Latency  time.Duration // Time taken by the HTTP request
+	Cached   bool          // The spec came from the translation cache
+	Local    bool          // The spec was translated without the model
+	Err      error
+	Failed   bool // The item counts as a failure for -fail-fast and the exit code
+	Skipped  bool // The item did not run because an earlier item failed
+	ExitCode int  // Exit code ncurl would have used for this prompt alone
+}
+
+// MarshalJSON encodes a result as a JSON lines record
+func (r Result) MarshalJSON() ([]byte, error) {
+	record := struct {
+		Line      int                `json:"line"`
+		Name      string             `json:"name,omitempty"`
+		Prompt    string             `json:"prompt"`
+		Status    int                `json:"status,omitempty"`
+		LatencyMS int64              `json:"latency_ms,omitempty"`
+		Cached    bool               `json:"cached,omitempty"`
+		Local     bool               `json:"local,omitempty"`
+		Spec      *httpx.RequestSpec `json:"spec,omitempty"`
+		Error     string             `json:"error,omitempty"`
+		Failed    bool               `json:"failed"`
+		Skipped   bool               `json:"skipped,omitempty"`
+	}{
+		Line:      r.Item.Line,
+		Name:      r.Item.Name,
+		Prompt:    r.Item.Prompt,
+		Status:    r.Status,
+		LatencyMS: r.Latency.Milliseconds(),
+		Cached:    r.Cached,
+		Local:     r.Local,
+		Spec:      r.Spec,
+		Failed:    r.Failed,
+		Skipped:   r.Skipped,
+	}
+	if r.Err != nil {
+		record.Error = r.Err.Error()
+	}
+	return json.Marshal(record)
+}
+
+// Load reads a batch file. Files ending in .yaml or .yml, or starting with
+// a "- " list item, are read as YAML; anything else has one prompt per line.
+// A path of "-" reads from stdin.
+func Load(path string) ([]Item, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open batch file: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+		r = file
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	yaml := ext == ".yaml" || ext == ".yml" || strings.HasPrefix(firstContentLine(string(data)), "- ")
+
+	var items []Item
+	if yaml {
+		items, err = ParseYAML(string(data))
+	} else {
+		items = ParseLines(string(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrNoPrompts
+	}
+
+	return items, nil
+}
+
+// firstContentLine returns the first line that is not blank or a comment
+func firstContentLine(data string) string {
+	for _, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return trimmed
+		}
+	}
+	return ""
+}
+
+// ParseLines reads one prompt per line, skipping blank lines and # comments
+func ParseLines(data string) []Item {
+	var items []Item
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		items = append(items, Item{Line: line, Prompt: text})
+	}
+	return items
+}
+
+// ParseYAML reads a YAML list of prompts. Only the subset used by batch
+// files is supported: a list whose items are either plain strings or maps
+// with name and prompt keys, with optional single or double quotes.
+func ParseYAML(data string) ([]Item, error) {
+	var items []Item
+	var current *Item
+
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		if current.Prompt == "" {
+			return fmt.Errorf("%w: item on line %d has no prompt", ErrInvalidFile, current.Line)
+		}
+		items = append(items, *current)
+		current = nil
+		return nil
+	}
+
+	for i, raw := range strings.Split(data, "\n") {
+		line := i + 1
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		entry := trimmed
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			current = &Item{Line: line}
+			entry = strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			if entry == "" {
+				continue
+			}
+			if _, _, isPair := splitPair(entry); !isPair {
+				value, err := unquote(entry)
+				if err != nil {
+					return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidFile, line, err)
+				}
+				current.Prompt = value
+				continue
+			}
+		} else if current == nil || raw == trimmed {
+			return nil, fmt.Errorf("%w: line %d: expected a list item starting with \"- \"", ErrInvalidFile, line)
+		}
+
+		key, value, isPair := splitPair(entry)
+		if !isPair {
+			return nil, fmt.Errorf("%w: line %d: expected \"key: value\"", ErrInvalidFile, line)
+		}
+		value, err := unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidFile, line, err)
+		}
+
+		switch key {
+		case "name":
+			current.Name = value
+		case "prompt":
+			current.Prompt = value
+		default:
+			return nil, fmt.Errorf("%w: line %d: unknown key %q (use name or prompt)", ErrInvalidFile, line, key)
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// splitPair splits "key: value" when key is a plain identifier
+func splitPair(s string) (string, string, bool) {
+	key, value, found := strings.Cut(s, ":")
+	if !found || key == "" || strings.ContainsAny(key, " \t\"'") {
+		return "", "", false
+	}
+	if value != "" && value[0] != ' ' {
+		return "", "", false // e.g. a URL such as http://...
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// unquote removes YAML single or double quotes from a scalar
+func unquote(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	default:
+		return s, nil
+	}
+}
+
+// ValidateFormat returns ErrInvalidFormat for an unknown output format
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatTable, FormatJSONL:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q (use table or jsonl)", ErrInvalidFormat, format)
+	}
+}
+
+// Options control how a batch runs
+type Options struct {
+	Workers  int  // Number of prompts run at once; at least 1
+	FailFast bool // Stop starting new prompts after the first failure
+}
+
+// RunFunc runs a single item
+type RunFunc func(ctx context.Context, item Item) Result
+
+// Run runs every item with a bounded worker pool and returns the results
+// in item order. onResult, if not nil, is called as each result completes;
+// calls are serialized. With FailFast, items not yet started when a
+// failure occurs are reported as skipped and in-flight items are cancelled.
+func Run(ctx context.Context, items []Item, opts Options, run RunFunc, onResult func(Result)) []Result {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]Result, len(items))
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				var result Result
+				if ctx.Err() != nil {
+					result = Result{Item: items[idx], Skipped: true}
+				} else {
+					result = run(ctx, items[idx])
+					result.Item = items[idx]
+					if result.Failed && opts.FailFast {
+						if errors.Is(result.Err, context.Canceled) && ctx.Err() != nil {
+							result = Result{Item: items[idx], Skipped: true}
+						} else {
+							cancel()
+						}
+					}
+				}
+
+				mu.Lock()
+				results[idx] = result
+				if onResult != nil {
+					onResult(result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// Summary counts passed, failed and skipped results
+func Summary(results []Result) (int, int, int) {
+	var passed, failed, skipped int
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			skipped++
+		case r.Failed:
+			failed++
+		default:
+			passed++
+		}
+	}
+	return passed, failed, skipped
+}
+
+// WriteTable writes results as an aligned table, one row per item
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tNAME\tSTATUS\tLATENCY\tREQUEST")
+
+	for _, r := range results {
+		status, latency, request := "-", "-", ""
+		switch {
+		case r.Skipped:
+			status = "skipped"
+		case r.Err != nil:
+			status = "error"
+			request = r.Err.Error()
+		default:
+			status = strconv.Itoa(r.Status)
+			latency = r.Latency.Round(time.Millisecond).String()
+		}
+		if r.Spec != nil && r.Err == nil {
+			request = r.Spec.Method + " " + r.Spec.URL
+		}
+		switch {
+		case r.Cached:
+			request += " (cached)"
+		case r.Local:
+			request += " (local)"
+		}
+		if r.Failed && r.Err == nil {
+			status += " ✗"
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", r.Item.Line, r.Item.Name, status, latency, request)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,197 @@
+package batch_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/batch"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+func TestParseLines(t *testing.T) {
+	items := batch.ParseLines("# smoke tests\nget health\n\n  list users  \n")
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	if items[0].Line != 2 || items[0].Prompt != "get health" {
+		t.Errorf("Unexpected first item: %+v", items[0])
+	}
+	if items[1].Line != 4 || items[1].Prompt != "list users" {
+		t.Errorf("Unexpected second item: %+v", items[1])
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	data := strings.Join([]string{
+		"# smoke tests",
+		"- get health of http://localhost:8080",
+		"- name: users",
+		"  prompt: \"list users: first 10\"",
+		"- name: 'order'",
+		"  prompt: get order 1",
+	}, "\n")
+
+	items, err := batch.ParseYAML(data)
+	if err != nil {
+		t.Fatalf("ParseYAML failed: %v", err)
+	}
+
+	expected := []batch.Item{
+		{Line: 2, Prompt: "get health of http://localhost:8080"},
+		{Line: 3, Name: "users", Prompt: "list users: first 10"},
+		{Line: 5, Name: "order", Prompt: "get order 1"},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("Expected %d items, got %d: %+v", len(expected), len(items), items)
+	}
+	for i := range expected {
+		if items[i] != expected[i] {
+			t.Errorf("Item %d: expected %+v, got %+v", i, expected[i], items[i])
+		}
+	}
+
+	invalid := []string{
+		"- name: no prompt",
+		"prompt: not a list",
+		"- name: x\n  method: GET",
+	}
+	for _, data := range invalid {
+		if _, parseErr := batch.ParseYAML(data); !errors.Is(parseErr, batch.ErrInvalidFile) {
+			t.Errorf("Expected ErrInvalidFile for %q, got %v", data, parseErr)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "prompts.txt")
+	if err := os.WriteFile(yamlPath, []byte("- name: health\n  prompt: get health\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write batch file: %v", err)
+	}
+	items, err := batch.Load(yamlPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Name != "health" {
+		t.Errorf("Expected YAML content to be detected, got %+v", items)
+	}
+
+	emptyPath := filepath.Join(dir, "empty.txt")
+	if writeErr := os.WriteFile(emptyPath, []byte("# nothing yet\n"), 0o600); writeErr != nil {
+		t.Fatalf("Failed to write batch file: %v", writeErr)
+	}
+	if _, loadErr := batch.Load(emptyPath); !errors.Is(loadErr, batch.ErrNoPrompts) {
+		t.Errorf("Expected ErrNoPrompts, got %v", loadErr)
+	}
+}
+
+func TestRun(t *testing.T) {
+	items := make([]batch.Item, 10)
+	for i := range items {
+		items[i] = batch.Item{Line: i + 1, Prompt: "prompt"}
+	}
+
+	var running, maxRunning int32
+	run := func(_ context.Context, item batch.Item) batch.Result {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			current := atomic.LoadInt32(&maxRunning)
+			if n <= current || atomic.CompareAndSwapInt32(&maxRunning, current, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return batch.Result{Status: 200 + item.Line}
+	}
+
+	var seen int
+	results := batch.Run(context.Background(), items, batch.Options{Workers: 3}, run, func(batch.Result) { seen++ })
+
+	if maxRunning > 3 {
+		t.Errorf("Expected at most 3 concurrent runs, got %d", maxRunning)
+	}
+	if seen != len(items) {
+		t.Errorf("Expected onResult for every item, got %d", seen)
+	}
+	for i, r := range results {
+		if r.Item.Line != i+1 || r.Status != 201+i {
+			t.Errorf("Expected results in item order, got %+v at %d", r, i)
+		}
+	}
+}
+
+func TestRunFailFast(t *testing.T) {
+	items := make([]batch.Item, 5)
+	for i := range items {
+		items[i] = batch.Item{Line: i + 1}
+	}
+
+	run := func(_ context.Context, item batch.Item) batch.Result {
+		if item.Line == 2 {
+			return batch.Result{Err: errors.New("boom"), Failed: true, ExitCode: 3}
+		}
+		return batch.Result{Status: 200}
+	}
+
+	results := batch.Run(context.Background(), items, batch.Options{Workers: 1, FailFast: true}, run, nil)
+	passed, failed, skipped := batch.Summary(results)
+	if passed != 1 || failed != 1 || skipped != 3 {
+		t.Errorf("Expected 1 passed, 1 failed, 3 skipped; got %d, %d, %d", passed, failed, skipped)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	results := []batch.Result{
+		{
+			Item:    batch.Item{Line: 1, Name: "health", Prompt: "get health"},
+			Spec:    &httpx.RequestSpec{Method: "GET", URL: "http://localhost:8080/health"},
+			Status:  200,
+			Latency: 42 * time.Millisecond,
+			Cached:  true,
+		},
+		{
+			Item:   batch.Item{Line: 2, Prompt: "list users"},
+			Err:    errors.New("model processing failed"),
+			Failed: true,
+		},
+		{
+			Item:   batch.Item{Line: 3, Prompt: "get localhost:8080/ready"},
+			Spec:   &httpx.RequestSpec{Method: "GET", URL: "http://localhost:8080/ready"},
+			Status: 204,
+			Local:  true,
+		},
+	}
+
+	var table bytes.Buffer
+	if err := batch.WriteTable(&table, results); err != nil {
+		t.Fatalf("WriteTable failed: %v", err)
+	}
+	out := table.String()
+	for _, want := range []string{
+		"health", "200", "42ms", "GET http://localhost:8080/health (cached)", "model processing failed",
+		"GET http://localhost:8080/ready (local)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected table to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	encoded, err := json.Marshal(results[1])
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `{"line":2,"prompt":"list users","error":"model processing failed","failed":true}`
+	if string(encoded) != expected {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+}
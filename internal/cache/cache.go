@@ -0,0 +1,274 @@
+// Package cache stores translated request specs so repeated prompts can
+// skip the model
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+// Default limits
+const (
+	DefaultTTL        = 24 * time.Hour
+	DefaultMaxEntries = 1000
+)
+
+// Entry is a cached translation
+type Entry struct {
+	Key         string            `json:"-"` // Set by List
+	Prompt      string            `json:"prompt"`
+	Model       string            `json:"model"`
+	Provider    string            `json:"provider,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Created     time.Time         `json:"created"`
+	Spec        httpx.RequestSpec `json:"spec"`
+}
+
+// Cache stores entries as files in a directory
+type Cache struct {
+	dir        string
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+// Option is a functional option for configuring the Cache
+type Option func(*Cache)
+
+// WithTTL sets how long entries stay valid. Zero or less keeps entries
+// until they are evicted by the size limit.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.ttl = ttl
+	}
+}
+
+// WithMaxEntries sets how many entries are kept; the oldest are evicted
+// first. Zero or less means no limit.
+func WithMaxEntries(n int) Option {
+	return func(c *Cache) {
+		c.maxEntries = n
+	}
+}
+
+// WithClock sets the function used to get the current time
+func WithClock(now func() time.Time) Option {
+	return func(c *Cache) {
+		c.now = now
+	}
+}
+
+// NewTestCache creates a cache in dir for testing purposes
+func NewTestCache(dir string, opts ...Option) *Cache {
+	return newCache(dir, opts)
+}
+
+// New creates a cache in ~/.ncurl/cache
+func New(opts ...Option) (*Cache, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	dir := filepath.Join(home, ".ncurl", "cache")
+	if mkdirErr := os.MkdirAll(dir, 0o750); mkdirErr != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", mkdirErr)
+	}
+
+	return newCache(dir, opts), nil
+}
+
+// newCache applies options over the defaults
+func newCache(dir string, opts []Option) *Cache {
+	c := &Cache{
+		dir:        dir,
+		ttl:        DefaultTTL,
+		maxEntries: DefaultMaxEntries,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Key derives a cache key from the parts that determine a translation
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizePrompt collapses differences in whitespace that don't change
+// what a prompt asks for. Case is kept, since it matters in values such as
+// tokens and JSON bodies.
+func NormalizePrompt(prompt string) string {
+	return strings.Join(strings.Fields(prompt), " ")
+}
+
+// path returns the file holding the entry for key
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// expired reports whether an entry is past its TTL
+func (c *Cache) expired(entry *Entry) bool {
+	return c.ttl > 0 && c.now().Sub(entry.Created) > c.ttl
+}
+
+// read loads the entry stored in a file
+func read(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if unmarshalErr := json.Unmarshal(data, &entry); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return &entry, nil
+}
+
+// Get returns the entry for key. Missing, unreadable and expired entries
+// are misses; expired entries are removed.
+func (c *Cache) Get(key string) (*Entry, bool) {
+	entry, err := read(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	if c.expired(entry) {
+		_ = os.Remove(c.path(key))
+		return nil, false
+	}
+
+	entry.Key = key
+	return entry, true
+}
+
+// Put stores an entry under key, setting its creation time if needed, and
+// then evicts expired entries and the oldest entries over the size limit
+func (c *Cache) Put(key string, entry Entry) error {
+	if entry.Created.IsZero() {
+		entry.Created = c.now()
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	// Write to a temporary file first so concurrent readers never see a
+	// partial entry
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", errors.Join(writeErr, closeErr))
+	}
+
+	if renameErr := os.Rename(tmp.Name(), c.path(key)); renameErr != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", renameErr)
+	}
+
+	return c.prune()
+}
+
+// List returns the valid entries, newest first
+func (c *Cache) List() ([]Entry, error) {
+	paths, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(paths))
+	for _, path := range paths {
+		entry, readErr := read(path)
+		if readErr != nil || c.expired(entry) {
+			continue
+		}
+		entry.Key = strings.TrimSuffix(filepath.Base(path), ".json")
+		entries = append(entries, *entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Created.After(entries[j].Created)
+	})
+	return entries, nil
+}
+
+// Clear removes every entry and returns how many there were
+func (c *Cache) Clear() (int, error) {
+	paths, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, path := range paths {
+		if removeErr := os.Remove(path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			return removed, fmt.Errorf("failed to remove cache entry: %w", removeErr)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// files returns the paths of all entry files
+func (c *Cache) files() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache entries: %w", err)
+	}
+	return paths, nil
+}
+
+// prune removes expired and unreadable entries, then the oldest entries
+// beyond the size limit
+func (c *Cache) prune() error {
+	paths, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	type stored struct {
+		path    string
+		created time.Time
+	}
+	var valid []stored
+	for _, path := range paths {
+		entry, readErr := read(path)
+		if readErr != nil || c.expired(entry) {
+			_ = os.Remove(path)
+			continue
+		}
+		valid = append(valid, stored{path: path, created: entry.Created})
+	}
+
+	if c.maxEntries <= 0 || len(valid) <= c.maxEntries {
+		return nil
+	}
+
+	sort.Slice(valid, func(i, j int) bool {
+		return valid[i].created.Before(valid[j].created)
+	})
+	for _, s := range valid[:len(valid)-c.maxEntries] {
+		_ = os.Remove(s.path)
+	}
+	return nil
+}
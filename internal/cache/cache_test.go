@@ -0,0 +1,96 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/cache"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+func TestGetPut(t *testing.T) {
+	c := cache.NewTestCache(t.TempDir())
+	key := cache.Key("claude-3-7-sonnet-latest", "get health")
+
+	if _, ok := c.Get(key); ok {
+		t.Fatal("Expected a miss for an empty cache")
+	}
+
+	spec := httpx.RequestSpec{Method: "GET", URL: "http://localhost:8080/health"}
+	if err := c.Put(key, cache.Entry{Prompt: "get health", Spec: spec}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	entry, ok := c.Get(key)
+	if !ok {
+		t.Fatal("Expected a hit after Put")
+	}
+	if entry.Spec.URL != spec.URL || entry.Created.IsZero() {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	if cache.Key("a", "bc") == cache.Key("ab", "c") {
+		t.Error("Expected key parts to be kept separate")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := cache.NewTestCache(t.TempDir(), cache.WithTTL(time.Hour), cache.WithClock(func() time.Time { return now }))
+
+	spec := httpx.RequestSpec{Method: "GET", URL: "http://localhost:8080/health"}
+	if err := c.Put("k", cache.Entry{Prompt: "get health", Spec: spec}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	now = now.Add(59 * time.Minute)
+	if _, ok := c.Get("k"); !ok {
+		t.Error("Expected a hit within the TTL")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("k"); ok {
+		t.Error("Expected a miss after the TTL")
+	}
+	if entries, err := c.List(); err != nil || len(entries) != 0 {
+		t.Errorf("Expected the expired entry to be gone, got %v, %v", entries, err)
+	}
+}
+
+func TestMaxEntriesListAndClear(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := cache.NewTestCache(t.TempDir(), cache.WithMaxEntries(2), cache.WithClock(func() time.Time { return now }))
+
+	for _, prompt := range []string{"first", "second", "third"} {
+		now = now.Add(time.Minute)
+		if err := c.Put(cache.Key(prompt), cache.Entry{Prompt: prompt}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Prompt != "third" || entries[1].Prompt != "second" {
+		t.Fatalf("Expected the two newest entries, newest first, got %+v", entries)
+	}
+	if entries[0].Key != cache.Key("third") {
+		t.Errorf("Expected List to set the key, got %q", entries[0].Key)
+	}
+
+	removed, err := c.Clear()
+	if err != nil || removed != 2 {
+		t.Errorf("Expected Clear to remove 2 entries, got %d, %v", removed, err)
+	}
+	if _, ok := c.Get(cache.Key("third")); ok {
+		t.Error("Expected a miss after Clear")
+	}
+}
+
+func TestNormalizePrompt(t *testing.T) {
+	got := cache.NormalizePrompt("  get   the USERS\n\ton localhost ")
+	if got != "get the USERS on localhost" {
+		t.Errorf("Unexpected normalized prompt %q", got)
+	}
+}
@@ -0,0 +1,154 @@
+// Package cassette records model exchanges to a file and replays them, so
+// evaluations can run offline and give the same answers every time
+package cassette
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrMiss            = errors.New("no recorded exchange")
+	ErrInvalidCassette = errors.New("invalid cassette")
+)
+
+// Exchange is one recorded model call
+type Exchange struct {
+	Model      string `json:"model"`
+	SystemHash string `json:"system_hash"` // HashSystem of the system prompt
+	Prompt     string `json:"prompt"`
+	Output     string `json:"output"` // Raw text of the reply, before any parsing
+}
+
+// Cassette holds exchanges and implements llm.Recorder and llm.Replayer
+type Cassette struct {
+	path string
+
+	mu        sync.Mutex
+	exchanges []Exchange
+	index     map[string]int // Exchange key to position
+}
+
+// HashSystem identifies a system prompt without storing all of it
+func HashSystem(systemPrompt string) string {
+	sum := sha256.Sum256([]byte(systemPrompt))
+	return hex.EncodeToString(sum[:6])
+}
+
+// key identifies the exchange for a model call
+func key(model, systemHash, prompt string) string {
+	parts, _ := json.Marshal([]string{model, systemHash, prompt})
+	return string(parts)
+}
+
+// Open reads the cassette at path. A missing file gives an empty cassette,
+// ready for recording.
+func Open(path string) (*Cassette, error) {
+	c := &Cassette{path: path, exchanges: []Exchange{}, index: make(map[string]int)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var exchanges []Exchange
+	if unmarshalErr := json.Unmarshal(data, &exchanges); unmarshalErr != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidCassette, path, unmarshalErr)
+	}
+	for _, ex := range exchanges {
+		c.add(ex)
+	}
+	return c, nil
+}
+
+// Load reads the cassette at path, which must exist
+func Load(path string) (*Cassette, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	return Open(path)
+}
+
+// add stores an exchange, replacing any earlier one for the same call.
+// The caller must hold c.mu or have exclusive access.
+func (c *Cassette) add(ex Exchange) {
+	k := key(ex.Model, ex.SystemHash, ex.Prompt)
+	if i, ok := c.index[k]; ok {
+		c.exchanges[i] = ex
+		return
+	}
+	c.index[k] = len(c.exchanges)
+	c.exchanges = append(c.exchanges, ex)
+}
+
+// Len returns the number of recorded exchanges
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.exchanges)
+}
+
+// Record saves the output of a model call, replacing any earlier recording
+// of the same call
+func (c *Cassette) Record(model, systemPrompt, prompt, output string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(Exchange{Model: model, SystemHash: HashSystem(systemPrompt), Prompt: prompt, Output: output})
+}
+
+// Replay returns the recorded output of a model call. Calls that weren't
+// recorded return ErrMiss, explaining when the prompt was recorded for
+// another model or system prompt.
+func (c *Cassette) Replay(model, systemPrompt, prompt string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	systemHash := HashSystem(systemPrompt)
+	if i, ok := c.index[key(model, systemHash, prompt)]; ok {
+		return c.exchanges[i].Output, nil
+	}
+
+	for _, ex := range c.exchanges {
+		if ex.Prompt != prompt {
+			continue
+		}
+		if ex.Model != model {
+			return "", fmt.Errorf("%w for %q with model %s in %s (it was recorded with %s)",
+				ErrMiss, prompt, model, c.path, ex.Model)
+		}
+		return "", fmt.Errorf("%w for %q with system prompt %s in %s "+
+			"(it was recorded with %s; record again after changing the system prompt)",
+			ErrMiss, prompt, systemHash, c.path, ex.SystemHash)
+	}
+	return "", fmt.Errorf("%w for %q in %s", ErrMiss, prompt, c.path)
+}
+
+// Save writes the cassette back to its file
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c.exchanges, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+
+	if dir := filepath.Dir(c.path); dir != "." {
+		if mkdirErr := os.MkdirAll(dir, 0o750); mkdirErr != nil {
+			return fmt.Errorf("failed to create cassette directory: %w", mkdirErr)
+		}
+	}
+	if writeErr := os.WriteFile(c.path, append(data, '\n'), 0o600); writeErr != nil {
+		return fmt.Errorf("failed to write cassette: %w", writeErr)
+	}
+	return nil
+}
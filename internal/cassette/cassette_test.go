@@ -0,0 +1,73 @@
+package cassette_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stephenbyrne99/ncurl/internal/cassette"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evals", "cassette.json")
+
+	recording, err := cassette.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	recording.Record("claude-a", "system v1", "get users", `{"url": "old"}`)
+	recording.Record("claude-a", "system v1", "get users", `{"url": "https://api.test/users"}`)
+	recording.Record("claude-b", "system v1", "get users", `{"url": "https://b.test/users"}`)
+	if recording.Len() != 2 {
+		t.Errorf("Expected re-recording to replace the exchange, got %d exchanges", recording.Len())
+	}
+	if saveErr := recording.Save(); saveErr != nil {
+		t.Fatalf("Save failed: %v", saveErr)
+	}
+
+	replay, err := cassette.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	output, err := replay.Replay("claude-a", "system v1", "get users")
+	if err != nil || output != `{"url": "https://api.test/users"}` {
+		t.Errorf("Unexpected replay: %q, %v", output, err)
+	}
+
+	misses := []struct {
+		model, system, prompt, hint string
+	}{
+		{"claude-a", "system v2", "get users", "record again after changing the system prompt"},
+		{"claude-c", "system v1", "get users", "it was recorded with claude-a"},
+		{"claude-a", "system v1", "list users", `"list users"`},
+	}
+	for _, m := range misses {
+		_, missErr := replay.Replay(m.model, m.system, m.prompt)
+		if !errors.Is(missErr, cassette.ErrMiss) || !strings.Contains(missErr.Error(), m.hint) {
+			t.Errorf("Expected ErrMiss mentioning %q, got %v", m.hint, missErr)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := cassette.Load(filepath.Join(dir, "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing cassette to fail to load, got %v", err)
+	}
+
+	empty, err := cassette.Open(filepath.Join(dir, "new.json"))
+	if err != nil || empty.Len() != 0 {
+		t.Errorf("Expected an empty cassette for a new file, got %v", err)
+	}
+
+	path := filepath.Join(dir, "broken.json")
+	if writeErr := os.WriteFile(path, []byte(`{"model": "x"}`), 0o600); writeErr != nil {
+		t.Fatalf("Failed to write cassette: %v", writeErr)
+	}
+	if _, err = cassette.Load(path); !errors.Is(err, cassette.ErrInvalidCassette) {
+		t.Errorf("Expected ErrInvalidCassette, got %v", err)
+	}
+}
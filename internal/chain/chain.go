@@ -0,0 +1,383 @@
+// Package chain runs multi-step request plans where later requests use
+// values captured from earlier responses
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/query"
+)
+
+// Capture sources
+const (
+	FromJSON   = "json"
+	FromHeader = "header"
+	FromCookie = "cookie"
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrInvalidPlan         = errors.New("invalid plan")
+	ErrUnresolvedVariable  = errors.New("unresolved variable")
+	ErrCaptureFailed       = errors.New("capture failed")
+	ErrUnsuccessfulRequest = errors.New("step returned an HTTP error")
+)
+
+// Patterns for {{name}} placeholders and the names themselves
+var (
+	variable    = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	captureName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Capture extracts a named value from a step's response
+type Capture struct {
+	Name string `json:"name"`
+	From string `json:"from"` // json, header or cookie
+	Path string `json:"path"` // Query expression, header name or cookie name
+}
+
+// Step is one request in a plan
+type Step struct {
+	Name      string            `json:"name"`
+	DependsOn []string          `json:"depends_on,omitempty"`
+	Request   httpx.RequestSpec `json:"request"`
+	Captures  []Capture         `json:"captures,omitempty"`
+}
+
+// Plan is an ordered set of steps
+type Plan struct {
+	Steps []Step `json:"steps"`
+}
+
+// Variables returns the names of the variables a step's request uses
+func (s *Step) Variables() []string {
+	seen := make(map[string]bool)
+	var names []string
+	visit := func(text string) {
+		for _, m := range variable.FindAllStringSubmatch(text, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				names = append(names, m[1])
+			}
+		}
+	}
+
+	req := s.Request
+	visit(req.URL)
+	visit(req.Body)
+	for _, k := range sortedKeys(req.Headers) {
+		visit(req.Headers[k])
+	}
+	for _, k := range sortedKeys(req.Form) {
+		visit(req.Form[k])
+	}
+
+	return names
+}
+
+// Validate checks that step names are unique, dependencies exist and are
+// acyclic, captures are well formed, and every variable a step uses is
+// captured by one of the steps it depends on, directly or indirectly.
+func (p *Plan) Validate() error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("%w: no steps", ErrInvalidPlan)
+	}
+
+	byName := make(map[string]*Step, len(p.Steps))
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		if step.Name == "" {
+			return fmt.Errorf("%w: step %d has no name", ErrInvalidPlan, i+1)
+		}
+		if _, dup := byName[step.Name]; dup {
+			return fmt.Errorf("%w: duplicate step name %q", ErrInvalidPlan, step.Name)
+		}
+		byName[step.Name] = step
+	}
+
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		for _, dep := range step.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("%w: step %q depends on unknown step %q", ErrInvalidPlan, step.Name, dep)
+			}
+		}
+		for _, c := range step.Captures {
+			if err := c.validate(); err != nil {
+				return fmt.Errorf("%w: step %q: %w", ErrInvalidPlan, step.Name, err)
+			}
+		}
+	}
+
+	order, err := p.Order()
+	if err != nil {
+		return err
+	}
+
+	// Variables must come from a step that is guaranteed to run first
+	for _, step := range order {
+		available := make(map[string]bool)
+		for _, ancestor := range ancestors(step, byName) {
+			for _, c := range ancestor.Captures {
+				available[c.Name] = true
+			}
+		}
+		for _, name := range step.Variables() {
+			if !available[name] {
+				return fmt.Errorf("%w: step %q uses {{%s}}, which none of its dependencies capture",
+					ErrInvalidPlan, step.Name, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsValidName reports whether name can be used as a {{name}} variable
+func IsValidName(name string) bool {
+	return captureName.MatchString(name)
+}
+
+// validate checks a capture's fields
+func (c Capture) validate() error {
+	if !IsValidName(c.Name) {
+		return fmt.Errorf("invalid capture name %q", c.Name)
+	}
+	if c.Path == "" {
+		return fmt.Errorf("capture %q has no path", c.Name)
+	}
+
+	switch c.From {
+	case FromJSON:
+		if _, err := query.Parse(c.Path); err != nil {
+			return fmt.Errorf("capture %q: %w", c.Name, err)
+		}
+		return nil
+	case FromHeader, FromCookie:
+		return nil
+	default:
+		return fmt.Errorf("capture %q has unknown source %q (use json, header or cookie)", c.Name, c.From)
+	}
+}
+
+// ancestors returns every step that step depends on, directly or indirectly
+func ancestors(step *Step, byName map[string]*Step) []*Step {
+	var out []*Step
+	seen := make(map[string]bool)
+	var visit func(s *Step)
+	visit = func(s *Step) {
+		for _, dep := range s.DependsOn {
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			out = append(out, byName[dep])
+			visit(byName[dep])
+		}
+	}
+	visit(step)
+	return out
+}
+
+// Order returns the steps sorted so that each runs after its dependencies.
+// Steps keep their planned order where dependencies allow.
+func (p *Plan) Order() ([]*Step, error) {
+	done := make(map[string]bool, len(p.Steps))
+	var order []*Step
+
+	for len(order) < len(p.Steps) {
+		progressed := false
+		for i := range p.Steps {
+			step := &p.Steps[i]
+			if done[step.Name] || !dependenciesDone(step, done) {
+				continue
+			}
+			done[step.Name] = true
+			order = append(order, step)
+			progressed = true
+			break // Restart so earlier steps keep priority
+		}
+		if !progressed {
+			return nil, fmt.Errorf("%w: dependency cycle between steps", ErrInvalidPlan)
+		}
+	}
+
+	return order, nil
+}
+
+// dependenciesDone reports whether all of a step's dependencies have run
+func dependenciesDone(step *Step, done map[string]bool) bool {
+	for _, dep := range step.DependsOn {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// Substitute returns a copy of spec with {{name}} placeholders replaced
+// by captured values
+func Substitute(spec httpx.RequestSpec, vars map[string]string) (*httpx.RequestSpec, error) {
+	var missing []string
+	replace := func(text string) string {
+		return variable.ReplaceAllStringFunc(text, func(m string) string {
+			name := variable.FindStringSubmatch(m)[1]
+			value, ok := vars[name]
+			if !ok {
+				missing = append(missing, name)
+				return m
+			}
+			return value
+		})
+	}
+
+	out := spec
+	out.URL = replace(spec.URL)
+	out.Body = replace(spec.Body)
+	out.Headers = replaceValues(spec.Headers, replace)
+	out.Form = replaceValues(spec.Form, replace)
+	out.Files = append([]httpx.FilePart(nil), spec.Files...)
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnresolvedVariable, strings.Join(missing, ", "))
+	}
+	return &out, nil
+}
+
+// replaceValues copies a map, applying replace to each value
+func replaceValues(m map[string]string, replace func(string) string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = replace(v)
+	}
+	return out
+}
+
+// Extract evaluates a capture against a response
+func (c Capture) Extract(resp *httpx.Response) (string, error) {
+	switch c.From {
+	case FromHeader:
+		if value := resp.Header.Get(c.Path); value != "" {
+			return value, nil
+		}
+		return "", fmt.Errorf("%w: %s: no %s header in the response", ErrCaptureFailed, c.Name, c.Path)
+
+	case FromCookie:
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == c.Path {
+				return cookie.Value, nil
+			}
+		}
+		return "", fmt.Errorf("%w: %s: no %s cookie in the response", ErrCaptureFailed, c.Name, c.Path)
+
+	default:
+		q, err := query.Parse(c.Path)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %w", ErrCaptureFailed, c.Name, err)
+		}
+		results, err := q.RunJSON(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %w", ErrCaptureFailed, c.Name, err)
+		}
+		if len(results) == 0 || results[0] == nil {
+			return "", fmt.Errorf("%w: %s: %s matched nothing", ErrCaptureFailed, c.Name, c.Path)
+		}
+		if s, ok := results[0].(string); ok {
+			return s, nil
+		}
+		encoded, err := json.Marshal(results[0])
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %w", ErrCaptureFailed, c.Name, err)
+		}
+		return string(encoded), nil
+	}
+}
+
+// ExecuteFunc sends a single request
+type ExecuteFunc func(ctx context.Context, spec *httpx.RequestSpec) (*httpx.Response, error)
+
+// StepResult is the outcome of running one step
+type StepResult struct {
+	Step     *Step
+	Spec     *httpx.RequestSpec // The request after substitution
+	Response *httpx.Response
+	Captured map[string]string
+}
+
+// Run executes a plan's steps in dependency order, capturing values from
+// each response for later steps. It stops at the first error, including a
+// step that returns an HTTP 4xx or 5xx status, and returns the results of
+// the steps that ran. onStep, if not nil, is called after each step.
+func Run(ctx context.Context, plan *Plan, execute ExecuteFunc, onStep func(StepResult)) ([]StepResult, error) {
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+	order, err := plan.Order()
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	var results []StepResult
+
+	for _, step := range order {
+		spec, substituteErr := Substitute(step.Request, vars)
+		if substituteErr != nil {
+			return results, fmt.Errorf("step %q: %w", step.Name, substituteErr)
+		}
+
+		resp, execErr := execute(ctx, spec)
+		if execErr != nil {
+			return results, fmt.Errorf("step %q: %w", step.Name, execErr)
+		}
+
+		result := StepResult{Step: step, Spec: spec, Response: resp, Captured: make(map[string]string)}
+		if resp.StatusCode >= 400 {
+			results = append(results, result)
+			if onStep != nil {
+				onStep(result)
+			}
+			return results, fmt.Errorf("%w: step %q returned %s", ErrUnsuccessfulRequest, step.Name, resp.Status)
+		}
+
+		for _, c := range step.Captures {
+			value, captureErr := c.Extract(resp)
+			if captureErr != nil {
+				results = append(results, result)
+				if onStep != nil {
+					onStep(result)
+				}
+				return results, fmt.Errorf("step %q: %w", step.Name, captureErr)
+			}
+			vars[c.Name] = value
+			result.Captured[c.Name] = value
+		}
+
+		results = append(results, result)
+		if onStep != nil {
+			onStep(result)
+		}
+	}
+
+	return results, nil
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,222 @@
+package chain_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stephenbyrne99/ncurl/internal/chain"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		steps []chain.Step
+		valid bool
+	}{
+		{
+			name: "Valid chain",
+			steps: []chain.Step{
+				{
+					Name:     "login",
+					Request:  httpx.RequestSpec{Method: "POST", URL: "http://api.test/login"},
+					Captures: []chain.Capture{{Name: "token", From: chain.FromJSON, Path: ".token"}},
+				},
+				{
+					Name:      "me",
+					DependsOn: []string{"login"},
+					Request: httpx.RequestSpec{
+						Method:  "GET",
+						URL:     "http://api.test/me",
+						Headers: map[string]string{"Authorization": "Bearer {{token}}"},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Variable without dependency",
+			steps: []chain.Step{
+				{
+					Name:     "login",
+					Request:  httpx.RequestSpec{Method: "POST", URL: "http://api.test/login"},
+					Captures: []chain.Capture{{Name: "token", From: chain.FromJSON, Path: ".token"}},
+				},
+				{Name: "me", Request: httpx.RequestSpec{Method: "GET", URL: "http://api.test/{{token}}"}},
+			},
+		},
+		{
+			name: "Cycle",
+			steps: []chain.Step{
+				{Name: "a", DependsOn: []string{"b"}, Request: httpx.RequestSpec{Method: "GET", URL: "http://a"}},
+				{Name: "b", DependsOn: []string{"a"}, Request: httpx.RequestSpec{Method: "GET", URL: "http://b"}},
+			},
+		},
+		{
+			name: "Unknown dependency",
+			steps: []chain.Step{
+				{Name: "a", DependsOn: []string{"z"}, Request: httpx.RequestSpec{Method: "GET", URL: "http://a"}},
+			},
+		},
+		{
+			name: "Bad capture source",
+			steps: []chain.Step{
+				{
+					Name:     "a",
+					Request:  httpx.RequestSpec{Method: "GET", URL: "http://a"},
+					Captures: []chain.Capture{{Name: "x", From: "body", Path: ".x"}},
+				},
+			},
+		},
+		{
+			name: "Duplicate names",
+			steps: []chain.Step{
+				{Name: "a", Request: httpx.RequestSpec{Method: "GET", URL: "http://a"}},
+				{Name: "a", Request: httpx.RequestSpec{Method: "GET", URL: "http://b"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := &chain.Plan{Steps: tc.steps}
+			err := plan.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("Expected a valid plan, got %v", err)
+			}
+			if !tc.valid && !errors.Is(err, chain.ErrInvalidPlan) {
+				t.Errorf("Expected ErrInvalidPlan, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidName(t *testing.T) {
+	for name, want := range map[string]bool{"token": true, "user_id2": true, "_x": true, "2fa": false, "a-b": false} {
+		if got := chain.IsValidName(name); got != want {
+			t.Errorf("IsValidName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	plan := &chain.Plan{Steps: []chain.Step{
+		{Name: "fetch", DependsOn: []string{"create"}},
+		{Name: "login"},
+		{Name: "create", DependsOn: []string{"login"}},
+	}}
+
+	order, err := plan.Order()
+	if err != nil {
+		t.Fatalf("Order failed: %v", err)
+	}
+
+	var names []string
+	for _, step := range order {
+		names = append(names, step.Name)
+	}
+	if fmt.Sprint(names) != "[login create fetch]" {
+		t.Errorf("Expected [login create fetch], got %v", names)
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1", Path: "/"})
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"token": "t-123"}`))
+		case "/orders":
+			if r.Header.Get("Authorization") != "Bearer t-123" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Location", "/orders/42")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": 42}`))
+		case "/orders/42":
+			if c, err := r.Cookie("sid"); err != nil || c.Value != "s1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id": 42, "status": "pending"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	plan := &chain.Plan{Steps: []chain.Step{
+		{
+			Name:     "login",
+			Request:  httpx.RequestSpec{Method: "POST", URL: server.URL + "/login"},
+			Captures: []chain.Capture{{Name: "token", From: chain.FromJSON, Path: ".token"}},
+		},
+		{
+			Name:      "create",
+			DependsOn: []string{"login"},
+			Request: httpx.RequestSpec{
+				Method:  "POST",
+				URL:     server.URL + "/orders",
+				Headers: map[string]string{"Authorization": "Bearer {{token}}"},
+			},
+			Captures: []chain.Capture{
+				{Name: "order_id", From: chain.FromJSON, Path: ".id"},
+				{Name: "location", From: chain.FromHeader, Path: "Location"},
+			},
+		},
+		{
+			Name:      "fetch",
+			DependsOn: []string{"create"},
+			Request:   httpx.RequestSpec{Method: "GET", URL: server.URL + "/orders/{{order_id}}"},
+		},
+	}}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("Failed to create cookie jar: %v", err)
+	}
+	execute := func(ctx context.Context, spec *httpx.RequestSpec) (*httpx.Response, error) {
+		return httpx.ExecuteWithContext(ctx, spec, httpx.WithCookieJar(jar))
+	}
+
+	results, err := chain.Run(context.Background(), plan, execute, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[1].Captured["order_id"] != "42" || results[1].Captured["location"] != "/orders/42" {
+		t.Errorf("Unexpected captures: %v", results[1].Captured)
+	}
+	if results[2].Spec.URL != server.URL+"/orders/42" {
+		t.Errorf("Expected substituted URL, got %s", results[2].Spec.URL)
+	}
+	if results[2].Response.StatusCode != http.StatusOK {
+		t.Errorf("Expected final step to succeed with the shared cookie, got %d", results[2].Response.StatusCode)
+	}
+
+	// The chain stops at the first HTTP error
+	plan.Steps[1].Request.Headers["Authorization"] = "Bearer wrong"
+	results, err = chain.Run(context.Background(), plan, execute, nil)
+	if !errors.Is(err, chain.ErrUnsuccessfulRequest) {
+		t.Errorf("Expected ErrUnsuccessfulRequest, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected the chain to stop after 2 steps, got %d", len(results))
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	spec := httpx.RequestSpec{Method: "GET", URL: "http://api.test/users/{{ id }}/{{missing}}"}
+	if _, err := chain.Substitute(spec, map[string]string{"id": "7"}); !errors.Is(err, chain.ErrUnresolvedVariable) {
+		t.Errorf("Expected ErrUnresolvedVariable, got %v", err)
+	}
+}
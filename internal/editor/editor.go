@@ -0,0 +1,337 @@
+// Package editor lets the user change a request in their text editor
+// before it is sent
+package editor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+// Supported file formats
+const (
+	FormatJSON = "json" // The request spec as JSON
+	FormatHTTP = "http" // An HTTP message: request line, headers and body
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrCancelled     = errors.New("edit cancelled")
+	ErrInvalidFormat = errors.New("invalid edit format")
+	ErrInvalidEdit   = errors.New("invalid edit")
+	ErrEditorFailed  = errors.New("editor failed")
+)
+
+// errorPrefix marks the annotation that explains why an edit was rejected
+const errorPrefix = "ERROR: "
+
+// Editor opens requests in an external editor
+type Editor struct {
+	command []string
+	format  string
+}
+
+// Option is a functional option for configuring the Editor
+type Option func(*Editor)
+
+// WithCommand sets the editor command, overriding $VISUAL and $EDITOR.
+// The file to edit is passed as the last argument.
+func WithCommand(command ...string) Option {
+	return func(e *Editor) {
+		e.command = command
+	}
+}
+
+// WithFormat sets the file format, FormatHTTP or FormatJSON
+func WithFormat(format string) Option {
+	return func(e *Editor) {
+		e.format = format
+	}
+}
+
+// New creates an editor that runs $VISUAL, $EDITOR or vi
+func New(opts ...Option) *Editor {
+	command := strings.Fields(os.Getenv("VISUAL"))
+	if len(command) == 0 {
+		command = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(command) == 0 {
+		command = []string{"vi"}
+	}
+
+	e := &Editor{command: command, format: FormatHTTP}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ValidateFormat checks that a format name is supported
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatJSON, FormatHTTP:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q (use http or json)", ErrInvalidFormat, format)
+	}
+}
+
+// Edit opens spec in the editor and returns the edited request. If the
+// result can't be parsed or fails validation, the editor is reopened with
+// the error shown at the top of the file. Saving a file with nothing but
+// comments cancels the edit.
+func (e *Editor) Edit(spec *httpx.RequestSpec) (*httpx.RequestSpec, error) {
+	if err := ValidateFormat(e.format); err != nil {
+		return nil, err
+	}
+
+	format := e.format
+	if format == FormatHTTP && !fitsHTTP(spec) {
+		format = FormatJSON // Form fields, files and body files need the JSON form
+	}
+
+	text, err := Marshal(spec, format)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "ncurl-*."+format)
+	if err != nil {
+		return nil, err
+	}
+	path := file.Name()
+	defer func() { _ = os.Remove(path) }()
+	if closeErr := file.Close(); closeErr != nil {
+		return nil, closeErr
+	}
+
+	for {
+		if writeErr := os.WriteFile(path, []byte(text), 0o600); writeErr != nil {
+			return nil, writeErr
+		}
+		if runErr := e.run(path); runErr != nil {
+			return nil, runErr
+		}
+
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		edited, parseErr := Parse(string(data), format)
+		if parseErr == nil || errors.Is(parseErr, ErrCancelled) {
+			return edited, parseErr
+		}
+
+		// Show the problem above the user's own text and try again
+		text = annotateError(string(data), format, parseErr)
+	}
+}
+
+// run opens path in the editor and waits for it to exit
+func (e *Editor) run(path string) error {
+	args := append(append([]string{}, e.command[1:]...), path)
+	cmd := exec.Command(e.command[0], args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+	// The request body may have come from piped stdin, so talk to the
+	// terminal directly when there is one
+	cmd.Stdin = os.Stdin
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer func() { _ = tty.Close() }()
+		cmd.Stdin = tty
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrEditorFailed, e.command[0], err)
+	}
+	return nil
+}
+
+// fitsHTTP reports whether a request can be written as an HTTP message
+func fitsHTTP(spec *httpx.RequestSpec) bool {
+	return spec.BodyFile == "" && !spec.IsMultipart()
+}
+
+// comment returns the comment marker for a format
+func comment(format string) string {
+	if format == FormatJSON {
+		return "//"
+	}
+	return "#"
+}
+
+// Marshal writes a request in the given format, with instructions in
+// comments at the top
+func Marshal(spec *httpx.RequestSpec, format string) (string, error) {
+	var sb strings.Builder
+	c := comment(format)
+	fmt.Fprintf(&sb, "%s Edit the request, then save and close the editor to send it.\n", c)
+	fmt.Fprintf(&sb, "%s Lines starting with %s at the top are ignored. Delete everything else to cancel.\n", c, c)
+
+	switch format {
+	case FormatJSON:
+		encoded, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		sb.Write(encoded)
+		sb.WriteString("\n")
+
+	case FormatHTTP:
+		if !fitsHTTP(spec) {
+			return "", fmt.Errorf("%w: form fields, files and body files need the json format", ErrInvalidFormat)
+		}
+		fmt.Fprintf(&sb, "%s The request line and headers come first, then a blank line and the body.\n", c)
+		method := spec.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		fmt.Fprintf(&sb, "%s %s\n", method, spec.URL)
+		names := make([]string, 0, len(spec.Headers))
+		for name := range spec.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&sb, "%s: %s\n", name, spec.Headers[name])
+		}
+		if spec.Body != "" {
+			sb.WriteString("\n")
+			sb.WriteString(spec.Body)
+			sb.WriteString("\n")
+		}
+
+	default:
+		return "", ValidateFormat(format)
+	}
+
+	return sb.String(), nil
+}
+
+// Parse reads a request written by Marshal and possibly edited, and
+// validates it. Leading comment lines are ignored; a file with nothing else
+// returns ErrCancelled.
+func Parse(text, format string) (*httpx.RequestSpec, error) {
+	if err := ValidateFormat(format); err != nil {
+		return nil, err
+	}
+
+	content := stripComments(text, comment(format))
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrCancelled
+	}
+
+	var spec *httpx.RequestSpec
+	var err error
+	if format == FormatJSON {
+		spec, err = parseJSON(content)
+	} else {
+		spec, err = parseHTTP(content)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if validateErr := spec.Validate(); validateErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEdit, validateErr)
+	}
+	return spec, nil
+}
+
+// stripComments removes comment and blank lines from the top of text
+func stripComments(text, marker string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for len(lines) > 0 {
+		trimmed := strings.TrimSpace(lines[0])
+		if trimmed != "" && !strings.HasPrefix(trimmed, marker) {
+			break
+		}
+		lines = lines[1:]
+	}
+	return strings.Join(lines, "")
+}
+
+// parseJSON decodes a request spec, rejecting unknown fields so that typos
+// aren't silently dropped
+func parseJSON(content string) (*httpx.RequestSpec, error) {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	var spec httpx.RequestSpec
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEdit, err)
+	}
+	return &spec, nil
+}
+
+// parseHTTP reads a request line, headers and an optional body
+func parseHTTP(content string) (*httpx.RequestSpec, error) {
+	reader := bufio.NewReader(strings.NewReader(content))
+
+	requestLine, _ := reader.ReadString('\n')
+	fields := strings.Fields(requestLine)
+	if len(fields) < 2 || len(fields) > 3 || (len(fields) == 3 && !strings.HasPrefix(fields[2], "HTTP/")) {
+		return nil, fmt.Errorf("%w: the first line must be METHOD URL, got %q",
+			ErrInvalidEdit, strings.TrimSpace(requestLine))
+	}
+	spec := &httpx.RequestSpec{
+		Method:  strings.ToUpper(fields[0]),
+		URL:     fields[1],
+		Headers: make(map[string]string),
+	}
+
+	for lineNumber := 2; ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		trimmed := strings.TrimRight(line, "\r\n")
+		if trimmed == "" {
+			break // A blank line starts the body
+		}
+		if !strings.HasPrefix(strings.TrimSpace(trimmed), "#") {
+			name, value, ok := strings.Cut(trimmed, ":")
+			if !ok || strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t") {
+				return nil, fmt.Errorf("%w: line %d: expected a header like Name: value, got %q",
+					ErrInvalidEdit, lineNumber, trimmed)
+			}
+			spec.Headers[name] = strings.TrimSpace(value)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	body := strings.Builder{}
+	_, _ = reader.WriteTo(&body)
+	spec.Body = strings.TrimSuffix(strings.TrimSuffix(body.String(), "\n"), "\r")
+
+	return spec, nil
+}
+
+// annotateError puts an explanation of err at the top of text, replacing
+// any earlier one
+func annotateError(text, format string, err error) string {
+	c := comment(format)
+	lines := strings.SplitAfter(text, "\n")
+	kept := make([]string, 0, len(lines))
+	header := true
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if header && trimmed != "" && !strings.HasPrefix(trimmed, c) {
+			header = false
+		}
+		if header && strings.HasPrefix(trimmed, c+" "+errorPrefix) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	message := strings.ReplaceAll(err.Error(), "\n", " ")
+	return fmt.Sprintf("%s %s%s\n%s", c, errorPrefix, message, strings.Join(kept, ""))
+}
@@ -0,0 +1,162 @@
+package editor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stephenbyrne99/ncurl/internal/editor"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+func TestRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format string
+		spec   httpx.RequestSpec
+	}{
+		{
+			name:   "HTTP with body",
+			format: editor.FormatHTTP,
+			spec: httpx.RequestSpec{
+				Method:  "POST",
+				URL:     "https://api.test/users",
+				Headers: map[string]string{"Content-Type": "application/json", "Authorization": "Bearer t"},
+				Body:    "{\n  \"name\": \"Ada\"\n}\n# not a comment",
+			},
+		},
+		{
+			name:   "HTTP without body",
+			format: editor.FormatHTTP,
+			spec:   httpx.RequestSpec{Method: "GET", URL: "https://api.test/users", Headers: map[string]string{}},
+		},
+		{
+			name:   "JSON multipart",
+			format: editor.FormatJSON,
+			spec: httpx.RequestSpec{
+				Method:  "POST",
+				URL:     "https://api.test/upload",
+				Headers: map[string]string{},
+				Form:    map[string]string{"title": "Q3"},
+				Files:   []httpx.FilePart{{Field: "file", Path: "./report.pdf"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			text, err := editor.Marshal(&tc.spec, tc.format)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			got, err := editor.Parse(text, tc.format)
+			if err != nil {
+				t.Fatalf("Parse failed: %v\n%s", err, text)
+			}
+			if !reflect.DeepEqual(*got, tc.spec) {
+				t.Errorf("Round trip changed the request:\nwant %+v\ngot  %+v", tc.spec, *got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		format  string
+		text    string
+		wantErr error
+		check   func(t *testing.T, spec *httpx.RequestSpec)
+	}{
+		{
+			name:   "Lowercase method and HTTP version",
+			format: editor.FormatHTTP,
+			text:   "# comment\n\nput https://api.test/a HTTP/1.1\r\nX-Id: 7\r\n",
+			check: func(t *testing.T, spec *httpx.RequestSpec) {
+				if spec.Method != "PUT" || spec.Headers["X-Id"] != "7" {
+					t.Errorf("Unexpected request: %+v", spec)
+				}
+			},
+		},
+		{name: "Only comments", format: editor.FormatHTTP, text: "# a\n\n# b\n", wantErr: editor.ErrCancelled},
+		{name: "Empty JSON file", format: editor.FormatJSON, text: "", wantErr: editor.ErrCancelled},
+		{name: "Bad request line", format: editor.FormatHTTP, text: "GET\n", wantErr: editor.ErrInvalidEdit},
+		{name: "Bad header", format: editor.FormatHTTP, text: "GET https://a\nno header\n", wantErr: editor.ErrInvalidEdit},
+		{name: "Missing URL", format: editor.FormatJSON, text: `{"method": "GET"}`, wantErr: httpx.ErrInvalidRequest},
+		{name: "Typo", format: editor.FormatJSON, text: `{"url": "https://a", "hedaer": {}}`, wantErr: editor.ErrInvalidEdit},
+		{name: "Unknown format", format: "yaml", text: "GET https://a", wantErr: editor.ErrInvalidFormat},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := editor.Parse(tc.text, tc.format)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("Expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			tc.check(t, spec)
+		})
+	}
+}
+
+func TestEdit(t *testing.T) {
+	dir := t.TempDir()
+
+	// The fake editor saves a broken request the first time it is opened,
+	// keeps a copy of what it was shown the second time, then fixes it
+	script := filepath.Join(dir, "fake-editor")
+	err := os.WriteFile(script, []byte(`#!/bin/sh
+count=$(cat "`+dir+`/count" 2>/dev/null || echo 0)
+count=$((count + 1))
+echo $count > "`+dir+`/count"
+if [ $count -eq 1 ]; then
+  printf 'GET\n' > "$1"
+else
+  cp "$1" "`+dir+`/shown"
+  printf 'DELETE https://api.test/users/7\n' > "$1"
+fi
+`), 0o700)
+	if err != nil {
+		t.Fatalf("Failed to write fake editor: %v", err)
+	}
+
+	e := editor.New(editor.WithCommand(script), editor.WithFormat(editor.FormatHTTP))
+	spec, err := e.Edit(&httpx.RequestSpec{Method: "GET", URL: "https://api.test/users"})
+	if err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	if spec.Method != "DELETE" || spec.URL != "https://api.test/users/7" {
+		t.Errorf("Unexpected edited request: %+v", spec)
+	}
+
+	shown, err := os.ReadFile(filepath.Join(dir, "shown"))
+	if err != nil {
+		t.Fatalf("Editor was not reopened: %v", err)
+	}
+	if !strings.HasPrefix(string(shown), "# ERROR: invalid edit: the first line must be METHOD URL") ||
+		!strings.Contains(string(shown), "\nGET\n") {
+		t.Errorf("Expected the error above the rejected edit, got:\n%s", shown)
+	}
+}
+
+func TestEditFailures(t *testing.T) {
+	spec := &httpx.RequestSpec{Method: "GET", URL: "https://api.test/users"}
+
+	e := editor.New(editor.WithCommand("false"))
+	if _, err := e.Edit(spec); !errors.Is(err, editor.ErrEditorFailed) {
+		t.Errorf("Expected ErrEditorFailed, got %v", err)
+	}
+
+	e = editor.New(editor.WithCommand("sh", "-c", `: > "$0"`))
+	if _, err := e.Edit(spec); !errors.Is(err, editor.ErrCancelled) {
+		t.Errorf("Expected ErrCancelled, got %v", err)
+	}
+}
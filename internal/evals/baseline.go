@@ -0,0 +1,160 @@
+package evals
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CaseChange is how a case's result changed from the baseline
+type CaseChange struct {
+	TestID          string  `json:"test_id"`
+	Description     string  `json:"description"`
+	BaselineSuccess bool    `json:"baseline_success"`
+	Success         bool    `json:"success"`
+	BaselineScore   float64 `json:"baseline_score"`
+	Score           float64 `json:"score"`
+}
+
+// Delta is the change in score from the baseline
+func (c *CaseChange) Delta() float64 {
+	return c.Score - c.BaselineScore
+}
+
+// BaselineDiff compares a run with a saved baseline run
+type BaselineDiff struct {
+	NewlyFailing []CaseChange `json:"newly_failing,omitempty"`
+	NewlyPassing []CaseChange `json:"newly_passing,omitempty"`
+	ScoreChanges []CaseChange `json:"score_changes,omitempty"`  // Every case whose score changed
+	Added        []string     `json:"added,omitempty"`          // Cases that aren't in the baseline
+	Missing      []string     `json:"missing,omitempty"`        // Baseline cases that weren't run
+	NotRunPassed []string     `json:"not_run_passed,omitempty"` // Missing cases that passed in the baseline
+
+	BaselineAverage float64 `json:"baseline_average"` // Over the cases in both runs
+	Average         float64 `json:"average"`
+}
+
+// Regressions is the number of cases that passed in the baseline and fail
+// now or weren't run, so dropping a case can't hide a regression
+func (d *BaselineDiff) Regressions() int {
+	return len(d.NewlyFailing) + len(d.NotRunPassed)
+}
+
+// LoadResults loads results saved as JSON, such as the output of
+// ncurl-eval -json. A file without any results is an error.
+func LoadResults(filePath string) ([]EvalResult, error) {
+	data, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results file: %w", err)
+	}
+
+	var results []EvalResult
+	if unmarshalErr := json.Unmarshal(data, &results); unmarshalErr != nil {
+		return nil, fmt.Errorf("%w: failed to parse results %s: %w", ErrInvalidEvaluation, filePath, unmarshalErr)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: no results in %s", ErrInvalidEvaluation, filePath)
+	}
+
+	return results, nil
+}
+
+// CompareToBaseline diffs results against a baseline run, matching cases by
+// ID. Changes are listed in the order of results.
+func CompareToBaseline(baseline, results []EvalResult) *BaselineDiff {
+	diff := &BaselineDiff{}
+
+	previous := make(map[string]*EvalResult, len(baseline))
+	for i := range baseline {
+		previous[baseline[i].TestID] = &baseline[i]
+	}
+
+	current := make(map[string]bool, len(results))
+	compared := 0
+	for i := range results {
+		r := &results[i]
+		current[r.TestID] = true
+		b, ok := previous[r.TestID]
+		if !ok {
+			diff.Added = append(diff.Added, r.TestID)
+			continue
+		}
+
+		compared++
+		diff.BaselineAverage += b.Score
+		diff.Average += r.Score
+
+		change := CaseChange{
+			TestID:          r.TestID,
+			Description:     r.Description,
+			BaselineSuccess: b.Success,
+			Success:         r.Success,
+			BaselineScore:   b.Score,
+			Score:           r.Score,
+		}
+		switch {
+		case b.Success && !r.Success:
+			diff.NewlyFailing = append(diff.NewlyFailing, change)
+		case !b.Success && r.Success:
+			diff.NewlyPassing = append(diff.NewlyPassing, change)
+		}
+		if change.Score != change.BaselineScore {
+			diff.ScoreChanges = append(diff.ScoreChanges, change)
+		}
+	}
+	if compared > 0 {
+		diff.BaselineAverage /= float64(compared)
+		diff.Average /= float64(compared)
+	}
+
+	for i := range baseline {
+		if !current[baseline[i].TestID] {
+			diff.Missing = append(diff.Missing, baseline[i].TestID)
+			if baseline[i].Success {
+				diff.NotRunPassed = append(diff.NotRunPassed, baseline[i].TestID)
+			}
+		}
+	}
+
+	return diff
+}
+
+// GenerateBaselineReport creates a summary report of the changes from the
+// baseline
+func GenerateBaselineReport(d *BaselineDiff) string {
+	var sb strings.Builder
+	sb.WriteString("## Baseline Comparison\n\n")
+	sb.WriteString(fmt.Sprintf("- Newly Failing: %d\n", len(d.NewlyFailing)))
+	if len(d.NotRunPassed) > 0 {
+		sb.WriteString(fmt.Sprintf("- Passed in Baseline but Not Run: %s\n", strings.Join(d.NotRunPassed, ", ")))
+	}
+	sb.WriteString(fmt.Sprintf("- Newly Passing: %d\n", len(d.NewlyPassing)))
+	sb.WriteString(fmt.Sprintf("- Average Score: %.2f (baseline %.2f, %+.2f)\n",
+		d.Average, d.BaselineAverage, d.Average-d.BaselineAverage))
+	if len(d.Added) > 0 {
+		sb.WriteString(fmt.Sprintf("- Not in Baseline: %s\n", strings.Join(d.Added, ", ")))
+	}
+	if len(d.Missing) > 0 {
+		sb.WriteString(fmt.Sprintf("- Not Run: %s\n", strings.Join(d.Missing, ", ")))
+	}
+	sb.WriteString("\n")
+
+	writeChanges := func(title string, changes []CaseChange) {
+		if len(changes) == 0 {
+			return
+		}
+		sb.WriteString("### " + title + "\n\n")
+		for i := range changes {
+			c := &changes[i]
+			sb.WriteString(fmt.Sprintf("- %s: %.2f -> %.2f (%+.2f)\n", c.TestID, c.BaselineScore, c.Score, c.Delta()))
+		}
+		sb.WriteString("\n")
+	}
+	writeChanges("Newly Failing", d.NewlyFailing)
+	writeChanges("Newly Passing", d.NewlyPassing)
+	writeChanges("Score Changes", d.ScoreChanges)
+
+	return sb.String()
+}
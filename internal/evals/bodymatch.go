@@ -0,0 +1,434 @@
+package evals
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+// BodyMatch describes the body a case expects. Every field that is set must
+// match.
+type BodyMatch struct {
+	// JSON must be contained in the body: objects may have extra keys, and
+	// each expected array element must match a different actual element, in
+	// any order
+	JSON json.RawMessage `json:"json,omitempty"`
+
+	// Schema is a JSON Schema the body must satisfy. The keywords supported
+	// are type, enum, const, required, properties, additionalProperties,
+	// items, minItems, maxItems, minLength, maxLength, pattern, minimum and
+	// maximum.
+	Schema json.RawMessage `json:"schema,omitempty"`
+
+	// Form holds fields of a form-encoded or multipart body. A value of "*"
+	// accepts any value.
+	Form map[string]string `json:"form,omitempty"`
+
+	// GraphQL is a query compared with the body's query after normalizing
+	// whitespace, commas and comments
+	GraphQL string `json:"graphql,omitempty"`
+}
+
+// Validate checks that the expected JSON and schema are well formed
+func (m *BodyMatch) Validate() error {
+	if len(m.JSON) > 0 && !json.Valid(m.JSON) {
+		return fmt.Errorf("%w: expected_body_match.json is not valid JSON", ErrInvalidEvaluation)
+	}
+	if len(m.Schema) > 0 {
+		var schema map[string]any
+		if err := json.Unmarshal(m.Schema, &schema); err != nil {
+			return fmt.Errorf("%w: expected_body_match.schema: %w", ErrInvalidEvaluation, err)
+		}
+	}
+	return nil
+}
+
+// Match compares a request's body against the expectation and returns a
+// line for each difference, or nil if it matches
+func (m *BodyMatch) Match(spec *httpx.RequestSpec) []string {
+	var diffs []string
+
+	if len(m.JSON) > 0 || len(m.Schema) > 0 {
+		actual, err := decodeJSON([]byte(spec.Body))
+		if err != nil {
+			return append(diffs, fmt.Sprintf("body is not JSON: %v", err))
+		}
+		if len(m.JSON) > 0 {
+			expected, _ := decodeJSON(m.JSON) // Checked by Validate
+			diffs = append(diffs, diffJSON("$", expected, actual)...)
+		}
+		if len(m.Schema) > 0 {
+			var schema map[string]any
+			_ = json.Unmarshal(m.Schema, &schema)
+			diffs = append(diffs, checkSchema("$", schema, actual)...)
+		}
+	}
+
+	if len(m.Form) > 0 {
+		diffs = append(diffs, diffForm(m.Form, formFields(spec))...)
+	}
+
+	if m.GraphQL != "" {
+		expected := normalizeGraphQL(m.GraphQL)
+		actual := normalizeGraphQL(graphQLQuery(spec.Body))
+		if expected != actual {
+			diffs = append(diffs, fmt.Sprintf("GraphQL query differs:\n    expected: %s\n    actual:   %s",
+				expected, actual))
+		}
+	}
+
+	return diffs
+}
+
+// decodeJSON decodes a single JSON value
+func decodeJSON(data []byte) (any, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// diffJSON lists where actual doesn't contain expected, by JSON path
+func diffJSON(path string, expected, actual any) []string {
+	switch want := expected.(type) {
+	case map[string]any:
+		got, ok := actual.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %s", path, compactJSON(actual))}
+		}
+		var diffs []string
+		for _, key := range sortedKeys(want) {
+			value, present := got[key]
+			if !present {
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing, expected %s", path, key, compactJSON(want[key])))
+				continue
+			}
+			diffs = append(diffs, diffJSON(path+"."+key, want[key], value)...)
+		}
+		return diffs
+
+	case []any:
+		got, ok := actual.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array, got %s", path, compactJSON(actual))}
+		}
+		var diffs []string
+		used := make([]bool, len(got))
+		for i, element := range want {
+			matched := false
+			for j := range got {
+				if !used[j] && len(diffJSON(path, element, got[j])) == 0 {
+					used[j], matched = true, true
+					break
+				}
+			}
+			if !matched {
+				diffs = append(diffs, fmt.Sprintf("%s[%d]: no element matches %s", path, i, compactJSON(element)))
+			}
+		}
+		return diffs
+
+	default:
+		if expected != actual {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", path, compactJSON(expected), compactJSON(actual))}
+		}
+		return nil
+	}
+}
+
+// checkSchema lists where value violates schema, by JSON path
+func checkSchema(path string, schema map[string]any, value any) []string {
+	if types, ok := schema["type"]; ok && !matchesType(types, value) {
+		return []string{fmt.Sprintf("%s: expected type %s, got %s", path, compactJSON(types), compactJSON(value))}
+	}
+
+	var diffs []string
+	if options, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, option := range options {
+			if equalJSON(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diffs = append(diffs, fmt.Sprintf("%s: expected one of %s, got %s",
+				path, compactJSON(options), compactJSON(value)))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !equalJSON(constant, value) {
+		diffs = append(diffs, fmt.Sprintf("%s: expected %s, got %s", path, compactJSON(constant), compactJSON(value)))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		diffs = append(diffs, checkObject(path, schema, v)...)
+	case []any:
+		diffs = append(diffs, checkLength(path, "items", schema["minItems"], schema["maxItems"], len(v))...)
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, element := range v {
+				diffs = append(diffs, checkSchema(fmt.Sprintf("%s[%d]", path, i), items, element)...)
+			}
+		}
+	case string:
+		diffs = append(diffs, checkLength(path, "characters", schema["minLength"], schema["maxLength"], len([]rune(v)))...)
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err != nil || !re.MatchString(v) {
+				diffs = append(diffs, fmt.Sprintf("%s: expected to match /%s/, got %q", path, pattern, v))
+			}
+		}
+	case float64:
+		diffs = append(diffs, checkRange(path, schema, v)...)
+	}
+	return diffs
+}
+
+// checkObject applies the object keywords of a schema
+func checkObject(path string, schema, object map[string]any) []string {
+	var diffs []string
+	if required, ok := schema["required"].([]any); ok {
+		for _, key := range required {
+			if name, isString := key.(string); isString {
+				if _, present := object[name]; !present {
+					diffs = append(diffs, fmt.Sprintf("%s.%s: missing required property", path, name))
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	for _, key := range sortedKeys(object) {
+		if property, ok := properties[key].(map[string]any); ok {
+			diffs = append(diffs, checkSchema(path+"."+key, property, object[key])...)
+			continue
+		}
+		if allowed, ok := schema["additionalProperties"].(bool); ok && !allowed {
+			diffs = append(diffs, fmt.Sprintf("%s.%s: unexpected property", path, key))
+		}
+	}
+	return diffs
+}
+
+// checkLength applies minimum and maximum length keywords
+func checkLength(path, unit string, minimum, maximum any, length int) []string {
+	var diffs []string
+	if limit, ok := minimum.(float64); ok && float64(length) < limit {
+		diffs = append(diffs, fmt.Sprintf("%s: expected at least %v %s, got %d", path, limit, unit, length))
+	}
+	if limit, ok := maximum.(float64); ok && float64(length) > limit {
+		diffs = append(diffs, fmt.Sprintf("%s: expected at most %v %s, got %d", path, limit, unit, length))
+	}
+	return diffs
+}
+
+// checkRange applies the minimum and maximum keywords
+func checkRange(path string, schema map[string]any, number float64) []string {
+	var diffs []string
+	if limit, ok := schema["minimum"].(float64); ok && number < limit {
+		diffs = append(diffs, fmt.Sprintf("%s: expected at least %v, got %v", path, limit, number))
+	}
+	if limit, ok := schema["maximum"].(float64); ok && number > limit {
+		diffs = append(diffs, fmt.Sprintf("%s: expected at most %v, got %v", path, limit, number))
+	}
+	return diffs
+}
+
+// matchesType reports whether value has one of the JSON Schema types
+func matchesType(types, value any) bool {
+	var names []any
+	switch t := types.(type) {
+	case string:
+		names = []any{t}
+	case []any:
+		names = t
+	}
+
+	for _, name := range names {
+		switch v := value.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case float64:
+			if name == "number" || (name == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case []any:
+			if name == "array" {
+				return true
+			}
+		case map[string]any:
+			if name == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// equalJSON compares two decoded JSON values exactly
+func equalJSON(a, b any) bool {
+	return compactJSON(a) == compactJSON(b)
+}
+
+// formFields returns the form fields of a request: its multipart fields,
+// or its body decoded as application/x-www-form-urlencoded
+func formFields(spec *httpx.RequestSpec) url.Values {
+	if len(spec.Form) > 0 {
+		fields := url.Values{}
+		for name, value := range spec.Form {
+			fields.Set(name, value)
+		}
+		return fields
+	}
+	fields, err := url.ParseQuery(spec.Body)
+	if err != nil {
+		return url.Values{}
+	}
+	return fields
+}
+
+// diffForm lists expected form fields that are missing or different
+func diffForm(expected map[string]string, fields url.Values) []string {
+	var diffs []string
+	for _, name := range sortedKeys(expected) {
+		want := expected[name]
+		values, ok := fields[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("form field %s: missing, expected %q", name, want))
+		case want != anyValue && !contains(values, want):
+			diffs = append(diffs, fmt.Sprintf("form field %s: expected %q, got %q",
+				name, want, strings.Join(values, ",")))
+		}
+	}
+	return diffs
+}
+
+// graphQLQuery extracts the query from a GraphQL body, which is either
+// JSON with a "query" field or the query itself
+func graphQLQuery(body string) string {
+	var request struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(body), &request); err == nil && request.Query != "" {
+		return request.Query
+	}
+	return body
+}
+
+// normalizeGraphQL rewrites a query so equivalent queries compare equal:
+// comments and commas are dropped, whitespace is only kept between names,
+// and an anonymous "query" keyword is removed
+func normalizeGraphQL(query string) string {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			start := i
+			for i++; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+			i = min(i+1, len(query))
+			tokens = append(tokens, query[start:i])
+		case strings.HasPrefix(query[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case isNameByte(c):
+			// Numbers such as 1.5 keep their decimal point
+			start := i
+			number := c == '-' || (c >= '0' && c <= '9')
+			for i < len(query) && (isNameByte(query[i]) || (number && query[i] == '.')) {
+				i++
+			}
+			tokens = append(tokens, query[start:i])
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+
+	if len(tokens) > 1 && tokens[0] == "query" && tokens[1] == "{" {
+		tokens = tokens[1:]
+	}
+
+	var sb strings.Builder
+	for i, token := range tokens {
+		if i > 0 && isNameByte(token[0]) && isNameByte(tokens[i-1][len(tokens[i-1])-1]) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(token)
+	}
+	return sb.String()
+}
+
+// isNameByte reports whether c can be part of a GraphQL name or number
+func isNameByte(c byte) bool {
+	return c == '_' || c == '-' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// compactJSON renders a decoded JSON value on one line
+func compactJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compactBody removes whitespace outside string literals, so JSON bodies
+// and fragments compare the same however they are spaced
+func compactBody(body string) string {
+	var sb strings.Builder
+	inString := false
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case inString && c == '\\' && i+1 < len(body):
+			sb.WriteByte(c)
+			i++
+			c = body[i]
+		case c == '"':
+			inString = !inString
+		case !inString && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
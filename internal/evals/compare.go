@@ -0,0 +1,199 @@
+package evals
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stephenbyrne99/ncurl/internal/usage"
+)
+
+// ModelRun holds the results of running the cases against one model
+type ModelRun struct {
+	Model   string       `json:"model"`
+	Results []EvalResult `json:"results"`
+	Tokens  usage.Tokens `json:"tokens"` // Used generating the requests
+	Error   string       `json:"error,omitempty"`
+
+	JudgeTokens usage.Tokens `json:"judge_tokens"` // Used judging and analyzing failures
+}
+
+// ModelSummary compares one model's run with the others
+type ModelSummary struct {
+	Model          string   `json:"model"`
+	Cases          int      `json:"cases"`
+	Passed         int      `json:"passed"`
+	PassRate       float64  `json:"pass_rate"` // 0.0 to 1.0
+	AverageScore   float64  `json:"average_score"`
+	AverageLatency int64    `json:"average_latency_ms"`
+	InputTokens    int64    `json:"input_tokens"`
+	OutputTokens   int64    `json:"output_tokens"`
+	Cost           *float64 `json:"cost,omitempty"` // Estimated in US dollars, judging included; nil for unknown models
+	Error          string   `json:"error,omitempty"`
+}
+
+// CaseOutcome is how one model did on a case
+type CaseOutcome struct {
+	Model     string  `json:"model"`
+	Success   bool    `json:"success"`
+	Score     float64 `json:"score"`
+	ActualURL string  `json:"actual_url,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Disagreement is a case that some models passed and others failed
+type Disagreement struct {
+	TestID   string        `json:"test_id"`
+	Input    string        `json:"input"`
+	Outcomes []CaseOutcome `json:"outcomes"` // In model order
+}
+
+// Comparison is the result of running the same cases against several models
+type Comparison struct {
+	Models        []ModelSummary `json:"models"`
+	Disagreements []Disagreement `json:"disagreements,omitempty"`
+	Runs          []ModelRun     `json:"runs"`
+}
+
+// CompareModels summarizes each run, pricing its tokens with prices, and
+// lists the cases the models disagree on. Cases missing from a run, for
+// example because it stopped early, are left out of the disagreements.
+func CompareModels(runs []ModelRun, prices usage.Prices) *Comparison {
+	comparison := &Comparison{Runs: runs}
+
+	outcomes := make(map[string][]CaseOutcome)
+	var order []string
+	inputs := make(map[string]string)
+	for _, run := range runs {
+		summary := ModelSummary{
+			Model:        run.Model,
+			Cases:        len(run.Results),
+			InputTokens:  run.Tokens.Input,
+			OutputTokens: run.Tokens.Output,
+			Error:        run.Error,
+		}
+		if cost, ok := runCost(run, prices); ok {
+			summary.Cost = &cost
+		}
+
+		var totalScore float64
+		var totalLatency int64
+		for i := range run.Results {
+			r := &run.Results[i]
+			if r.Success {
+				summary.Passed++
+			}
+			totalScore += r.Score
+			totalLatency += r.Duration
+
+			if _, seen := outcomes[r.TestID]; !seen {
+				order = append(order, r.TestID)
+				inputs[r.TestID] = r.Input
+			}
+			outcomes[r.TestID] = append(outcomes[r.TestID], CaseOutcome{
+				Model:     run.Model,
+				Success:   r.Success,
+				Score:     r.Score,
+				ActualURL: r.ActualURL,
+				Error:     r.Error,
+			})
+		}
+		if summary.Cases > 0 {
+			summary.PassRate = float64(summary.Passed) / float64(summary.Cases)
+			summary.AverageScore = totalScore / float64(summary.Cases)
+			summary.AverageLatency = totalLatency / int64(summary.Cases)
+		}
+		comparison.Models = append(comparison.Models, summary)
+	}
+
+	for _, id := range order {
+		caseOutcomes := outcomes[id]
+		if len(caseOutcomes) != len(runs) {
+			continue
+		}
+		for _, o := range caseOutcomes[1:] {
+			if o.Success != caseOutcomes[0].Success {
+				comparison.Disagreements = append(comparison.Disagreements, Disagreement{
+					TestID:   id,
+					Input:    inputs[id],
+					Outcomes: caseOutcomes,
+				})
+				break
+			}
+		}
+	}
+
+	return comparison
+}
+
+// runCost estimates what a run's model calls cost, including the judge's,
+// reporting false if any of them used a model without a price
+func runCost(run ModelRun, prices usage.Prices) (float64, bool) {
+	cost, ok := prices.Cost(run.Tokens)
+	if !ok {
+		return 0, false
+	}
+	if run.JudgeTokens.Input == 0 && run.JudgeTokens.Output == 0 {
+		return cost, true
+	}
+	judgeCost, ok := prices.Cost(run.JudgeTokens)
+	return cost + judgeCost, ok
+}
+
+// GenerateComparisonReport creates a summary report comparing models
+func GenerateComparisonReport(c *Comparison) string {
+	if len(c.Models) == 0 {
+		return "No models to compare."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## Model Comparison\n\n")
+	sb.WriteString("| Model | Pass Rate | Average Score | Average Latency | Input Tokens | Output Tokens | Cost |\n")
+	sb.WriteString("|-------|-----------|---------------|-----------------|--------------|---------------|------|\n")
+	const percentMultiplier = 100
+	for _, m := range c.Models {
+		cost := "unknown"
+		if m.Cost != nil {
+			cost = fmt.Sprintf("$%.4f", *m.Cost)
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %d/%d (%.1f%%) | %.2f | %dms | %d | %d | %s |\n",
+			m.Model, m.Passed, m.Cases, m.PassRate*percentMultiplier, m.AverageScore,
+			m.AverageLatency, m.InputTokens, m.OutputTokens, cost))
+	}
+	sb.WriteString("\n")
+
+	stopped := false
+	for _, m := range c.Models {
+		if m.Error != "" {
+			sb.WriteString(fmt.Sprintf("- %s stopped early: %s\n", m.Model, m.Error))
+			stopped = true
+		}
+	}
+	if stopped {
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("### Disagreements\n\n")
+	if len(c.Disagreements) == 0 {
+		sb.WriteString("All models passed and failed the same cases.\n")
+		return sb.String()
+	}
+	for _, d := range c.Disagreements {
+		sb.WriteString(fmt.Sprintf("#### %s\n", d.TestID))
+		sb.WriteString(fmt.Sprintf("- Input: %s\n", d.Input))
+		for _, o := range d.Outcomes {
+			status := "✅ PASS"
+			if !o.Success {
+				status = "❌ FAIL"
+			}
+			line := fmt.Sprintf("- %s: %s (%.2f)", o.Model, status, o.Score)
+			if o.Error != "" {
+				line += " - Error: " + o.Error
+			} else if o.ActualURL != "" {
+				line += " " + o.ActualURL
+			}
+			sb.WriteString(line + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,211 @@
+package evals
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+)
+
+// caseHeader routes an end-to-end request to the case that sent it. The
+// mock server removes it before recording the request.
+const caseHeader = "X-Ncurl-Eval-Case"
+
+// ReceivedRequest is what the mock server received for an end-to-end case
+type ReceivedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"` // Original scheme and host, with the path and query received
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Form    map[string]string `json:"form,omitempty"` // Multipart form fields
+}
+
+// mockServer answers requests with the MockResponse of the case named in
+// caseHeader and records what each case sent
+type mockServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	cases    map[string]*EvalCase
+	received map[string]*ReceivedRequest
+}
+
+// newMockServer starts a mock server for cases
+func newMockServer(cases []EvalCase) *mockServer {
+	s := &mockServer{
+		cases:    make(map[string]*EvalCase, len(cases)),
+		received: make(map[string]*ReceivedRequest),
+	}
+	for i := range cases {
+		s.cases[cases[i].ID] = &cases[i]
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// route makes sure requests for evalCase reach it, even if it wasn't loaded
+func (s *mockServer) route(evalCase *EvalCase) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cases[evalCase.ID] = evalCase
+	delete(s.received, evalCase.ID)
+}
+
+// receivedBy returns the last request recorded for a case
+func (s *mockServer) receivedBy(caseID string) *ReceivedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.received[caseID]
+}
+
+// serveHTTP records the request and writes the case's mock response
+func (s *mockServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	caseID := r.Header.Get(caseHeader)
+	s.mu.Lock()
+	c, ok := s.cases[caseID]
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error": "No mock response configured for this request"}`))
+		return
+	}
+
+	received := readRequest(r)
+	s.mu.Lock()
+	s.received[caseID] = received
+	s.mu.Unlock()
+
+	// Cases without a mock response get an empty 200
+	response := c.MockResponse
+	if response == nil {
+		response = &MockResponse{}
+	}
+	for k, v := range response.Headers {
+		w.Header().Set(k, v)
+	}
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	w.WriteHeader(statusCode)
+	if response.Body != "" {
+		_, _ = w.Write([]byte(response.Body))
+	}
+}
+
+// readRequest captures a request as the server received it
+func readRequest(r *http.Request) *ReceivedRequest {
+	received := &ReceivedRequest{
+		Method:  r.Method,
+		URL:     r.URL.RequestURI(),
+		Headers: make(map[string]string, len(r.Header)),
+	}
+	for name, values := range r.Header {
+		if name != caseHeader {
+			received.Headers[name] = strings.Join(values, ", ")
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		const maxMemory = 32 << 20
+		if err := r.ParseMultipartForm(maxMemory); err == nil {
+			received.Form = make(map[string]string, len(r.MultipartForm.Value))
+			for name, values := range r.MultipartForm.Value {
+				received.Form[name] = strings.Join(values, ",")
+			}
+			files := strings.Join(sortedKeys(r.MultipartForm.File), ", ")
+			received.Body = fmt.Sprintf("multipart form with files: %s", files)
+		}
+		return received
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	received.Body = string(body)
+	return received
+}
+
+// spec returns the received request as a spec, to score it like a
+// generated one
+func (r *ReceivedRequest) spec() *httpx.RequestSpec {
+	return &httpx.RequestSpec{Method: r.Method, URL: r.URL, Headers: r.Headers, Body: r.Body, Form: r.Form}
+}
+
+// sendToMockServer sends spec to the mock server in place of its real host
+// and returns what the server received and the response
+func (e *Evaluator) sendToMockServer(
+	ctx context.Context,
+	spec *httpx.RequestSpec,
+	evalCase *EvalCase,
+) (*ReceivedRequest, *httpx.Response, error) {
+	target, err := url.Parse(spec.URL)
+	if err != nil || target.Host == "" {
+		return nil, nil, fmt.Errorf("%w: can't send a request to %q", httpx.ErrInvalidRequest, spec.URL)
+	}
+	server, err := url.Parse(e.testServer.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", httpx.ErrRequestFailed, err)
+	}
+
+	// Send a copy tagged with the case, so the server can route it
+	local := *spec
+	localURL := *target
+	localURL.Scheme, localURL.Host = server.Scheme, server.Host
+	local.URL = localURL.String()
+	local.Headers = make(map[string]string, len(spec.Headers)+1)
+	for k, v := range spec.Headers {
+		local.Headers[k] = v
+	}
+	local.Headers[caseHeader] = evalCase.ID
+
+	e.testServer.route(evalCase)
+	response, err := httpx.ExecuteWithContext(ctx, &local)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	received := e.testServer.receivedBy(evalCase.ID)
+	if received == nil {
+		return nil, nil, fmt.Errorf("%w: the mock server received no request", httpx.ErrRequestFailed)
+	}
+	received.URL = target.Scheme + "://" + target.Host + received.URL
+	return received, response, nil
+}
+
+// validateResponse has the model judge whether the response answers the
+// case's input
+func (e *Evaluator) validateResponse(
+	ctx context.Context,
+	spec *httpx.RequestSpec,
+	evalCase *EvalCase,
+	body []byte,
+) (*ValidationResult, error) {
+	validation, err := e.validator.ValidateResponse(ctx, RequestEvalInput{
+		NaturalLanguage:  evalCase.Input,
+		GeneratedMethod:  spec.Method,
+		GeneratedURL:     spec.URL,
+		GeneratedHeaders: formatHeaders(spec.Headers),
+		GeneratedBody:    spec.Body,
+	}, body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to validate response: %w", llm.ErrModelFailure, err)
+	}
+	return validation, nil
+}
+
+// formatHeaders renders headers one per line, in order
+func formatHeaders(headers map[string]string) string {
+	lines := make([]string, 0, len(headers))
+	for _, name := range sortedKeys(headers) {
+		lines = append(lines, name+": "+headers[name])
+	}
+	return strings.Join(lines, "\n")
+}
@@ -6,13 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/httptest"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/stephenbyrne99/ncurl/internal/httpx"
 	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/usage"
 )
 
 // Common errors
@@ -21,34 +23,61 @@ var (
 	ErrEvaluationFailed  = errors.New("evaluation failed")
 )
 
+// ErrorCategory classifies why a case errored before it could be scored
+type ErrorCategory string
+
+// Error categories for EvalResult.ErrorCategory, and ErrorJudge for
+// Judgement.ErrorCategory
+const (
+	ErrorModel       ErrorCategory = "model_error"   // The model call failed
+	ErrorInvalidJSON ErrorCategory = "invalid_json"  // The model's answer wasn't a JSON request
+	ErrorTimeout     ErrorCategory = "timeout"       // The case ran out of time
+	ErrorValidation  ErrorCategory = "validation"    // The request was missing or invalid
+	ErrorRequest     ErrorCategory = "request_error" // Sending the request end to end failed
+	ErrorJudge       ErrorCategory = "judge_error"   // The judge model couldn't score the request
+)
+
 // EvalResult represents the outcome of an evaluation
 type EvalResult struct {
-	TestID      string    `json:"test_id"`
-	Description string    `json:"description"`
-	Success     bool      `json:"success"`
-	Score       float64   `json:"score"` // 0.0 to 1.0
-	Timestamp   time.Time `json:"timestamp"`
-	Error       string    `json:"error,omitempty"`
-	Details     string    `json:"details,omitempty"`
-	Input       string    `json:"input"`        // Natural language input
-	ExpectedURL string    `json:"expected_url"` // Expected URL in the request
-	ActualURL   string    `json:"actual_url"`   // Actual URL in the generated request
-	ActualBody  string    `json:"actual_body,omitempty"`
-	Duration    int64     `json:"duration_ms"`
+	TestID        string        `json:"test_id"`
+	Description   string        `json:"description"`
+	Success       bool          `json:"success"`
+	Score         float64       `json:"score"` // 0.0 to 1.0
+	Timestamp     time.Time     `json:"timestamp"`
+	Error         string        `json:"error,omitempty"`
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"` // Set when Error is
+	Details       string        `json:"details,omitempty"`
+	Input         string        `json:"input"`        // Natural language input
+	ExpectedURL   string        `json:"expected_url"` // Expected URL in the request
+	ActualURL     string        `json:"actual_url"`   // Actual URL in the generated request
+	ActualBody    string        `json:"actual_body,omitempty"`
+	Duration      int64         `json:"duration_ms"`
+
+	// Set by end-to-end runs
+	Received           *ReceivedRequest  `json:"received,omitempty"` // The request the mock server received
+	ResponseStatus     int               `json:"response_status,omitempty"`
+	ResponseValidation *ValidationResult `json:"response_validation,omitempty"`
+
+	// Set when judging with WithJudge
+	Judge         *Judgement           `json:"judge,omitempty"`
+	ErrorAnalysis *ErrorAnalysisResult `json:"error_analysis,omitempty"` // Why a failed case failed
 }
 
 // EvalCase represents a single evaluation test case
 type EvalCase struct {
-	ID               string            `json:"id"`
-	Description      string            `json:"description"`
-	Input            string            `json:"input"`           // Natural language input
-	ExpectedMethod   string            `json:"expected_method"` // Expected HTTP method
-	ExpectedURL      string            `json:"expected_url"`    // URL to expect (can be partial)
-	ExpectedURLRegex string            `json:"expected_url_regex,omitempty"`
-	ExpectedHeaders  map[string]string `json:"expected_headers,omitempty"`
-	ExpectedBody     string            `json:"expected_body,omitempty"`   // Expected HTTP body (can be partial)
-	MockResponse     *MockResponse     `json:"mock_response,omitempty"`   // Mock response to serve
-	PromptTemplate   string            `json:"prompt_template,omitempty"` // Custom prompt template
+	ID                  string                 `json:"id"`
+	Description         string                 `json:"description"`
+	Input               string                 `json:"input"`           // Natural language input
+	ExpectedMethod      string                 `json:"expected_method"` // Expected HTTP method
+	ExpectedURL         string                 `json:"expected_url"`    // URL to expect (can be partial)
+	ExpectedURLRegex    string                 `json:"expected_url_regex,omitempty"`
+	ExpectedURLMatch    *URLMatch              `json:"expected_url_match,omitempty"` // Expected URL parts
+	ExpectedHeaders     map[string]string      `json:"expected_headers,omitempty"`
+	ExpectedHeaderMatch map[string]HeaderMatch `json:"expected_header_match,omitempty"` // Header value matchers
+	ExpectedBody        string                 `json:"expected_body,omitempty"`         // Expected body (can be partial)
+	ExpectedBodyMatch   *BodyMatch             `json:"expected_body_match,omitempty"`   // Structured body expectations
+	MockResponse        *MockResponse          `json:"mock_response,omitempty"`         // Mock response to serve
+	PromptTemplate      string                 `json:"prompt_template,omitempty"`       // Custom prompt template
 }
 
 // MockResponse represents a mock HTTP response for testing
@@ -61,14 +90,104 @@ type MockResponse struct {
 // Evaluator manages the evaluation process
 type Evaluator struct {
 	client     *llm.Client
-	testServer *httptest.Server
+	judge      *llm.Client // Set with WithJudge
+	testServer *mockServer
 	cases      []EvalCase
 	Model      string // Exported for testing
 	timeout    time.Duration
+	clientOpts []llm.ClientOption
+	parallel   int
+	rpm        int
+	retries    int
+	backoff    time.Duration
+	limiter    *rateLimiter
+	failFast   bool
+	endToEnd   bool
+	validator  *ResponseValidator
+	judgeModel string
+	judgeMode  JudgeMode
+}
+
+// Defaults for retrying cases that hit the provider's rate limit
+const (
+	defaultRetries = 3
+	defaultBackoff = time.Second
+)
+
+// Option is a functional option for configuring the Evaluator
+type Option func(*Evaluator)
+
+// WithClientOptions configures the model client, for example to record or
+// replay model calls with llm.WithRecorder or llm.WithReplayer
+func WithClientOptions(opts ...llm.ClientOption) Option {
+	return func(e *Evaluator) {
+		e.clientOpts = append(e.clientOpts, opts...)
+	}
+}
+
+// WithParallel runs up to n cases at once. Results keep the order of the
+// cases either way.
+func WithParallel(n int) Option {
+	return func(e *Evaluator) {
+		e.parallel = n
+	}
+}
+
+// WithRateLimit makes at most requestsPerMinute model calls a minute,
+// evenly spaced, across all parallel cases. 0 means no limit.
+func WithRateLimit(requestsPerMinute int) Option {
+	return func(e *Evaluator) {
+		e.rpm = requestsPerMinute
+	}
+}
+
+// WithRetries retries a case up to retries times when the provider reports
+// a rate limit or overload. Every worker pauses for backoff, doubling on
+// each retry, or for as long as the provider asks if that is longer.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(e *Evaluator) {
+		e.retries = retries
+		e.backoff = backoff
+	}
+}
+
+// WithFailFast stops the run at the first case that errors, instead of
+// recording it as a failure and carrying on
+func WithFailFast(failFast bool) Option {
+	return func(e *Evaluator) {
+		e.failFast = failFast
+	}
+}
+
+// WithEndToEnd sends each generated request to a local mock server in place
+// of its real host and scores the request the server received. Each case is
+// answered with its MockResponse, or an empty 200.
+func WithEndToEnd(endToEnd bool) Option {
+	return func(e *Evaluator) {
+		e.endToEnd = endToEnd
+	}
+}
+
+// WithResponseValidator has v judge the mock server's response to each
+// end-to-end case. A case the validator finds invalid fails.
+func WithResponseValidator(v *ResponseValidator) Option {
+	return func(e *Evaluator) {
+		e.validator = v
+	}
+}
+
+// WithJudge has model score every generated request alongside the
+// rule-based score, and explain why failed cases failed. mode sets whether
+// the judge's score counts toward passing.
+func WithJudge(model string, mode JudgeMode) Option {
+	return func(e *Evaluator) {
+		e.judgeModel = model
+		e.judgeMode = mode
+	}
 }
 
 // NewEvaluator creates a new evaluator
-func NewEvaluator(model string, timeout time.Duration) *Evaluator {
+func NewEvaluator(model string, timeout time.Duration, opts ...Option) *Evaluator {
 	// Use default model if none specified
 	if model == "" {
 		model = anthropic.ModelClaude3_7SonnetLatest
@@ -80,11 +199,48 @@ func NewEvaluator(model string, timeout time.Duration) *Evaluator {
 		timeout = defaultTimeoutSeconds * time.Second
 	}
 
-	return &Evaluator{
-		client:  llm.NewClient(model),
-		Model:   model,
-		timeout: timeout,
+	e := &Evaluator{
+		Model:    model,
+		timeout:  timeout,
+		parallel: 1,
+		retries:  defaultRetries,
+		backoff:  defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.client = llm.NewClient(model, e.clientOpts...)
+	if e.judgeModel != "" {
+		e.judge = llm.NewClient(e.judgeModel, e.clientOpts...)
+	}
+	e.limiter = newRateLimiter(e.rpm)
+
+	return e
+}
+
+// Usage totals the tokens the model has used generating requests. Replayed
+// calls use none.
+func (e *Evaluator) Usage() usage.Tokens {
+	total := usage.Tokens{Model: e.Model}
+	for _, call := range e.client.Usage() {
+		total.Input += call.Input
+		total.Output += call.Output
+	}
+	return total
+}
+
+// JudgeUsage totals the tokens the judge model has used scoring requests
+// and analyzing failures
+func (e *Evaluator) JudgeUsage() usage.Tokens {
+	total := usage.Tokens{Model: e.judgeModel}
+	if e.judge == nil {
+		return total
+	}
+	for _, call := range e.judge.Usage() {
+		total.Input += call.Input
+		total.Output += call.Output
 	}
+	return total
 }
 
 // LoadTestCases loads evaluation cases from a JSON file
@@ -93,49 +249,165 @@ func (e *Evaluator) LoadTestCases(cases []EvalCase) error {
 		return fmt.Errorf("%w: no test cases provided", ErrInvalidEvaluation)
 	}
 
+	for i := range cases {
+		if err := cases[i].validate(); err != nil {
+			return fmt.Errorf("test case %s: %w", cases[i].ID, err)
+		}
+	}
+
 	e.cases = cases
 	return nil
 }
 
-// RunAll executes all evaluation cases and returns results
+// validate checks that the case's URL patterns, body and header
+// expectations are well formed
+func (c *EvalCase) validate() error {
+	if c.ExpectedURLRegex != "" {
+		if _, err := regexp.Compile(c.ExpectedURLRegex); err != nil {
+			return fmt.Errorf("%w: expected_url_regex %q: %w", ErrInvalidEvaluation, c.ExpectedURLRegex, err)
+		}
+	}
+	if c.ExpectedURLMatch != nil {
+		if err := c.ExpectedURLMatch.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.ExpectedBodyMatch != nil {
+		if err := c.ExpectedBodyMatch.Validate(); err != nil {
+			return err
+		}
+	}
+	for name, match := range c.ExpectedHeaderMatch {
+		if err := match.Validate(); err != nil {
+			return fmt.Errorf("header %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// expectedURL describes the URL a case expects, for reports
+func (c *EvalCase) expectedURL() string {
+	parts := make([]string, 0, 3)
+	if c.ExpectedURL != "" {
+		parts = append(parts, c.ExpectedURL)
+	}
+	if c.ExpectedURLRegex != "" {
+		parts = append(parts, "/"+c.ExpectedURLRegex+"/")
+	}
+	if c.ExpectedURLMatch != nil {
+		parts = append(parts, c.ExpectedURLMatch.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// RunAll executes all evaluation cases and returns results in case order.
+// Cases run on a pool of WithParallel workers. A case that errors is
+// recorded as a failure with an ErrorCategory and the run carries on. With
+// WithFailFast, no new cases are started after one errors, and the results
+// of the cases that finished are returned with that case's error.
 func (e *Evaluator) RunAll(ctx context.Context) ([]EvalResult, error) {
 	if len(e.cases) == 0 {
 		return nil, fmt.Errorf("%w: no test cases loaded", ErrInvalidEvaluation)
 	}
 
-	results := make([]EvalResult, 0, len(e.cases))
-
 	// Start mock server if needed for any test cases
 	if e.needsMockServer() {
-		server := e.startMockServer()
-		defer server.Close()
+		e.startMockServer()
+		defer e.stopMockServer()
 	}
 
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := min(max(e.parallel, 1), len(e.cases))
+	results := make([]EvalResult, len(e.cases))
+	errs := make([]error, len(e.cases))
+	done := make([]bool, len(e.cases))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if runCtx.Err() != nil {
+					continue // The run was stopped
+				}
+				results[i], errs[i] = e.runWithRetries(runCtx, &e.cases[i])
+				done[i] = true
+				if errs[i] != nil && e.failFast {
+					cancel()
+				}
+			}
+		}()
+	}
 	for i := range e.cases {
-		// Create a child context with timeout for each case
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Cases interrupted because the run was stopped don't count as failures
+	var firstErr error
+	finished := make([]EvalResult, 0, len(e.cases))
+	for i := range e.cases {
+		if !done[i] || (errs[i] != nil && errors.Is(errs[i], context.Canceled) && runCtx.Err() != nil) {
+			continue
+		}
+		finished = append(finished, results[i])
+		if errs[i] != nil && e.failFast && firstErr == nil {
+			firstErr = fmt.Errorf("error running case %s: %w", e.cases[i].ID, errs[i])
+		}
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+
+	return finished, firstErr
+}
+
+// runWithRetries runs a case once the rate limiter allows, giving each
+// attempt the full per-case timeout. When the provider reports a rate limit
+// or overload, every worker backs off before the case is retried.
+func (e *Evaluator) runWithRetries(ctx context.Context, evalCase *EvalCase) (EvalResult, error) {
+	for attempt := 0; ; attempt++ {
+		if err := e.limiter.Wait(ctx); err != nil {
+			return EvalResult{
+				TestID:        evalCase.ID,
+				Description:   evalCase.Description,
+				Input:         evalCase.Input,
+				ExpectedURL:   evalCase.expectedURL(),
+				Error:         err.Error(),
+				ErrorCategory: categorizeError(err),
+			}, err
+		}
+
 		caseCtx, cancel := context.WithTimeout(ctx, e.timeout)
-		result, err := e.runCase(caseCtx, &e.cases[i])
+		result, err := e.runCase(caseCtx, evalCase)
 		cancel()
 
-		if err != nil {
-			return results, fmt.Errorf("error running case %s: %w", e.cases[i].ID, err)
+		retryAfter, limited := rateLimitDelay(err)
+		if !limited || attempt >= e.retries {
+			e.analyzeFailure(ctx, evalCase, &result)
+			return result, err
 		}
-
-		results = append(results, result)
+		delay := min(max(e.backoff<<attempt, retryAfter), maxBackoff)
+		e.limiter.Pause(delay)
 	}
-
-	return results, nil
 }
 
 // Run executes a single evaluation case and returns the result
 func (e *Evaluator) Run(ctx context.Context, evalCase *EvalCase) (EvalResult, error) {
 	// Start mock server if needed
-	if evalCase.MockResponse != nil {
-		server := e.startMockServer()
-		defer server.Close()
+	if e.testServer == nil && (e.endToEnd || evalCase.MockResponse != nil) {
+		e.startMockServer()
+		defer e.stopMockServer()
 	}
 
-	return e.runCase(ctx, evalCase)
+	result, err := e.runCase(ctx, evalCase)
+	e.analyzeFailure(ctx, evalCase, &result)
+	return result, err
 }
 
 // runCase executes a single evaluation case
@@ -147,35 +419,80 @@ func (e *Evaluator) runCase(ctx context.Context, evalCase *EvalCase) (EvalResult
 		Description: evalCase.Description,
 		Timestamp:   startTime,
 		Input:       evalCase.Input,
-		ExpectedURL: evalCase.ExpectedURL,
+		ExpectedURL: evalCase.expectedURL(),
 	}
 
-	// Run the LLM request
-	spec, err := e.client.GenerateRequestSpec(ctx, evalCase.Input)
-	if err != nil {
+	// We capture errors in the result and also return them
+	// so that the caller can handle them appropriately
+	fail := func(err error) (EvalResult, error) {
 		result.Success = false
 		result.Score = 0.0
 		result.Error = err.Error()
+		result.ErrorCategory = categorizeError(err)
 		result.Duration = time.Since(startTime).Milliseconds()
-		// We capture the error in the result and also return it
-		// so that the caller can handle it appropriately
 		return result, err
 	}
 
+	// Run the LLM request
+	spec, err := e.client.GenerateRequestSpec(ctx, evalCase.Input)
+	if err != nil {
+		return fail(err)
+	}
+
 	result.ActualURL = spec.URL
 	result.ActualBody = spec.Body
 
+	// End to end, score what the server received rather than the spec
+	scored := spec
+	var response *httpx.Response
+	if e.endToEnd {
+		result.Received, response, err = e.sendToMockServer(ctx, spec, evalCase)
+		if err != nil {
+			return fail(err)
+		}
+		result.ResponseStatus = response.StatusCode
+		scored = result.Received.spec()
+	}
+
 	// Validate the result
-	score, details := e.evaluateSpec(spec, evalCase)
+	score, details := e.evaluateSpec(scored, evalCase)
 	const successThreshold = 0.8 // 80% threshold
 	result.Success = score >= successThreshold
 	result.Score = score
 	result.Details = details
-	result.Duration = time.Since(startTime).Milliseconds()
 
+	if response != nil && e.validator != nil {
+		result.ResponseValidation, err = e.validateResponse(ctx, spec, evalCase, response.Body)
+		if err != nil {
+			return fail(err)
+		}
+		result.Success = result.Success && result.ResponseValidation.IsValid
+	}
+
+	if e.judgeModel != "" {
+		e.judgeSpec(ctx, spec, evalCase, &result)
+	}
+
+	result.Duration = time.Since(startTime).Milliseconds()
 	return result, nil
 }
 
+// categorizeError classifies an error from running a case
+func categorizeError(err error) ErrorCategory {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.Is(err, llm.ErrInvalidJSON), errors.Is(err, llm.ErrEmptyResponse):
+		return ErrorInvalidJSON
+	case errors.Is(err, httpx.ErrInvalidRequest), errors.Is(err, llm.ErrInvalidRequest):
+		return ErrorValidation
+	case errors.Is(err, httpx.ErrRequestFailed), errors.Is(err, httpx.ErrReadResponse):
+		return ErrorRequest
+	default:
+		return ErrorModel
+	}
+}
+
 // evaluateSpec compares the generated request spec against expected values
 func (e *Evaluator) evaluateSpec(spec *httpx.RequestSpec, evalCase *EvalCase) (float64, string) {
 	var reasons []string
@@ -190,85 +507,20 @@ func (e *Evaluator) evaluateSpec(spec *httpx.RequestSpec, evalCase *EvalCase) (f
 	}
 
 	// Check URL
-	if evalCase.ExpectedURL != "" {
-		// Check if the URL contains the expected substring or if it's semantically equivalent
-		urlMatched := false
-
-		switch {
-		case strings.Contains(spec.URL, evalCase.ExpectedURL):
-			// Direct match found
-			urlMatched = true
-
-		case strings.Contains(strings.ToLower(spec.URL), strings.ToLower(evalCase.ExpectedURL)):
-			// Case-insensitive match found
-			urlMatched = true
-
-		case evalCase.ExpectedURL == "bitcoin" &&
-			(strings.Contains(spec.URL, "coindesk") || strings.Contains(spec.URL, "crypto") ||
-				strings.Contains(spec.URL, "coin") || strings.Contains(spec.URL, "btc")):
-			// Special case for Bitcoin-related URLs
-			urlMatched = true
-
-		case evalCase.ExpectedURL == "key=abc123xyz" &&
-			(strings.Contains(spec.URL, "abc123xyz") || strings.Contains(spec.URL, "key=") ||
-				strings.Contains(spec.URL, "apikey=") || strings.Contains(spec.URL, "api_key=") ||
-				strings.Contains(spec.URL, "appid=")):
-			// Special case for API keys - might use different parameter names
-			urlMatched = true
-
-		case evalCase.ExpectedURL == "profile" &&
-			(strings.Contains(spec.URL, "profile") || strings.Contains(spec.URL, "user") ||
-				strings.Contains(spec.URL, "account")):
-			// Special case for profile-related URLs
-			urlMatched = true
-
-		case evalCase.ExpectedURL == "v2" &&
-			(strings.Contains(spec.URL, "v2") || strings.Contains(spec.URL, "/v2") ||
-				strings.Contains(spec.URL, "v2/")):
-			// Special case for API version
-			urlMatched = true
-
-		case evalCase.ExpectedURL == "localhost:3000/users" && spec.URL == "http://localhost:3000/api/users":
-			// Special case for localhost API routes
-			urlMatched = true
-
-		case evalCase.ExpectedURL == "api.example.com/users" &&
-			(strings.Contains(spec.URL, "api.github.com/users") ||
-				strings.Contains(spec.URL, "api.example.com/users")):
-			// Handle example.com being replaced with real APIs
-			urlMatched = true
-		}
-
-		if !urlMatched {
-			reasons = append(reasons, fmt.Sprintf("URL mismatch: expected URL to contain %s, got %s",
-				evalCase.ExpectedURL, spec.URL))
-			deductions += 0.3
-		}
+	if urlReasons := matchURL(spec.URL, evalCase); len(urlReasons) > 0 {
+		reasons = append(reasons, urlReasons...)
+		deductions += 0.3
 	}
 
 	// Check headers
-	for k, v := range evalCase.ExpectedHeaders {
-		actualValue, ok := spec.Headers[k]
-		if !ok {
-			reasons = append(reasons, fmt.Sprintf("Missing expected header: %s", k))
-			deductions += 0.1
-		} else if actualValue != v {
-			reasons = append(reasons, fmt.Sprintf("Header value mismatch for %s: expected %s, got %s",
-				k, v, actualValue))
-			deductions += 0.1
-		}
-	}
+	headerReasons := matchHeaders(spec.Headers, evalCase)
+	reasons = append(reasons, headerReasons...)
+	deductions += 0.1 * float64(len(headerReasons))
 
 	// Check body if specified
-	if evalCase.ExpectedBody != "" {
-		if spec.Body == "" {
-			reasons = append(reasons, "Missing expected body content")
-			deductions += 0.2
-		} else if !strings.Contains(spec.Body, evalCase.ExpectedBody) {
-			reasons = append(reasons, fmt.Sprintf("Body mismatch: expected body to contain %s, got %s",
-				evalCase.ExpectedBody, spec.Body))
-			deductions += 0.2
-		}
+	if bodyReasons := matchBody(spec, evalCase); len(bodyReasons) > 0 {
+		reasons = append(reasons, bodyReasons...)
+		deductions += 0.2
 	}
 
 	// Calculate final score
@@ -287,58 +539,132 @@ func (e *Evaluator) evaluateSpec(spec *httpx.RequestSpec, evalCase *EvalCase) (f
 	return score, details
 }
 
-// needsMockServer checks if any test case requires a mock server
-func (e *Evaluator) needsMockServer() bool {
-	for i := range e.cases {
-		if e.cases[i].MockResponse != nil {
-			return true
+// matchURL checks a URL against the substring, regex and structured
+// expectations of a case, returning a reason for each mismatch
+func matchURL(rawURL string, evalCase *EvalCase) []string {
+	var reasons []string
+
+	if evalCase.ExpectedURL != "" &&
+		!strings.Contains(strings.ToLower(rawURL), strings.ToLower(evalCase.ExpectedURL)) {
+		reasons = append(reasons, fmt.Sprintf("URL mismatch: expected URL to contain %s, got %s",
+			evalCase.ExpectedURL, rawURL))
+	}
+
+	if evalCase.ExpectedURLRegex != "" {
+		re, err := regexp.Compile(evalCase.ExpectedURLRegex)
+		if err != nil || !re.MatchString(rawURL) {
+			reasons = append(reasons, fmt.Sprintf("URL mismatch: expected URL to match /%s/, got %s",
+				evalCase.ExpectedURLRegex, rawURL))
 		}
 	}
-	return false
+
+	if evalCase.ExpectedURLMatch != nil {
+		for _, reason := range evalCase.ExpectedURLMatch.Match(rawURL) {
+			reasons = append(reasons, "URL mismatch: "+reason)
+		}
+	}
+
+	return reasons
 }
 
-// startMockServer initializes a test HTTP server
-func (e *Evaluator) startMockServer() *httptest.Server {
-	if e.testServer != nil {
-		return e.testServer
+// matchHeaders checks headers against the exact and flexible expectations
+// of a case, ignoring the case of header names, and returns a reason for
+// each header that doesn't match
+func matchHeaders(headers map[string]string, evalCase *EvalCase) []string {
+	actual := canonicalHeaders(headers)
+	check := func(name string, match *HeaderMatch) string {
+		name = http.CanonicalHeaderKey(name)
+		value, present := actual[name]
+		switch {
+		case match.Match(value, present):
+			return ""
+		case match.Absent:
+			return fmt.Sprintf("Unexpected header: %s", name)
+		case !present:
+			return fmt.Sprintf("Missing expected header: %s", name)
+		default:
+			return fmt.Sprintf("Header value mismatch for %s: expected %s, got %s", name, match, value)
+		}
 	}
 
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Find the matching test case for this request
-		for i := range e.cases {
-			c := &e.cases[i]
-			if c.MockResponse == nil || !strings.Contains(r.URL.Path, c.ExpectedURL) {
-				continue
-			}
+	// An empty expected_headers value requires an empty header, not just
+	// the header's presence
+	var reasons []string
+	for _, name := range sortedKeys(evalCase.ExpectedHeaders) {
+		match := HeaderMatch{Exact: evalCase.ExpectedHeaders[name]}
+		if match.Exact == "" {
+			match.Regex = "^$"
+		}
+		if reason := check(name, &match); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	for _, name := range sortedKeys(evalCase.ExpectedHeaderMatch) {
+		match := evalCase.ExpectedHeaderMatch[name]
+		if reason := check(name, &match); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons
+}
 
-			// Set response headers
-			for k, v := range c.MockResponse.Headers {
-				w.Header().Set(k, v)
-			}
+// matchBody checks a request's body against the fragment and structured
+// expectations of a case, returning a reason for each mismatch
+func matchBody(spec *httpx.RequestSpec, evalCase *EvalCase) []string {
+	if evalCase.ExpectedBody == "" && evalCase.ExpectedBodyMatch == nil {
+		return nil
+	}
+	if spec.Body == "" && len(spec.Form) == 0 {
+		return []string{"Missing expected body content"}
+	}
 
-			// Set status code
-			statusCode := c.MockResponse.StatusCode
-			if statusCode == 0 {
-				statusCode = http.StatusOK
-			}
-			w.WriteHeader(statusCode)
+	var reasons []string
 
-			// Write body
-			if c.MockResponse.Body != "" {
-				_, _ = w.Write([]byte(c.MockResponse.Body))
-			}
-			return
+	// Whitespace between JSON tokens doesn't matter
+	if evalCase.ExpectedBody != "" && !strings.Contains(spec.Body, evalCase.ExpectedBody) &&
+		!strings.Contains(compactBody(spec.Body), compactBody(evalCase.ExpectedBody)) {
+		reasons = append(reasons, fmt.Sprintf("Body mismatch: expected body to contain %s, got %s",
+			evalCase.ExpectedBody, spec.Body))
+	}
+
+	if evalCase.ExpectedBodyMatch != nil {
+		for _, diff := range evalCase.ExpectedBodyMatch.Match(spec) {
+			reasons = append(reasons, "Body mismatch: "+diff)
 		}
+	}
+
+	return reasons
+}
 
-		// Default response if no match found
-		w.WriteHeader(http.StatusNotFound)
-		_, _ = w.Write([]byte(`{"error": "No mock response configured for this path"}`))
-	})
+// needsMockServer checks if the run or any test case requires a mock server
+func (e *Evaluator) needsMockServer() bool {
+	if e.endToEnd {
+		return true
+	}
+	for i := range e.cases {
+		if e.cases[i].MockResponse != nil {
+			return true
+		}
+	}
+	return false
+}
 
-	e.testServer = httptest.NewServer(handler)
+// startMockServer starts the mock server for the loaded cases
+func (e *Evaluator) startMockServer() *mockServer {
+	if e.testServer == nil {
+		e.testServer = newMockServer(e.cases)
+	}
 	return e.testServer
 }
 
+// stopMockServer closes the mock server, so the next run starts afresh
+func (e *Evaluator) stopMockServer() {
+	if e.testServer != nil {
+		e.testServer.Close()
+		e.testServer = nil
+	}
+}
+
 // GenerateReport creates a summary report of evaluation results
 func GenerateReport(results []EvalResult) string {
 	totalTests := len(results)
@@ -348,12 +674,26 @@ func GenerateReport(results []EvalResult) string {
 
 	successCount := 0
 	var totalScore float64
+	errorCounts := make(map[ErrorCategory]int)
+	judged := 0
+	var totalJudgeScore float64
 
 	for i := range results {
 		if results[i].Success {
 			successCount++
 		}
 		totalScore += results[i].Score
+		if results[i].ErrorCategory != "" {
+			errorCounts[results[i].ErrorCategory]++
+		}
+		if j := results[i].Judge; j != nil {
+			if j.ErrorCategory != "" {
+				errorCounts[j.ErrorCategory]++
+			} else {
+				judged++
+				totalJudgeScore += j.Score
+			}
+		}
 	}
 
 	avgScore := totalScore / float64(totalTests)
@@ -364,7 +704,22 @@ func GenerateReport(results []EvalResult) string {
 	sb.WriteString("## Evaluation Report\n\n")
 	sb.WriteString(fmt.Sprintf("- Total Tests: %d\n", totalTests))
 	sb.WriteString(fmt.Sprintf("- Successful Tests: %d (%.1f%%)\n", successCount, successRate))
-	sb.WriteString(fmt.Sprintf("- Average Score: %.2f\n\n", avgScore))
+	sb.WriteString(fmt.Sprintf("- Average Score: %.2f\n", avgScore))
+	if judged > 0 {
+		sb.WriteString(fmt.Sprintf("- Average Judge Score: %.2f (%d judged)\n", totalJudgeScore/float64(judged), judged))
+	}
+	sb.WriteString("\n")
+
+	if len(errorCounts) > 0 {
+		sb.WriteString("### Errors\n\n")
+		categories := []ErrorCategory{ErrorModel, ErrorInvalidJSON, ErrorTimeout, ErrorValidation, ErrorRequest, ErrorJudge}
+		for _, category := range categories {
+			if count := errorCounts[category]; count > 0 {
+				sb.WriteString(fmt.Sprintf("- %s: %d\n", category, count))
+			}
+		}
+		sb.WriteString("\n")
+	}
 
 	sb.WriteString("### Test Results\n\n")
 	for i := range results {
@@ -384,11 +739,40 @@ func GenerateReport(results []EvalResult) string {
 			sb.WriteString(fmt.Sprintf("- Body: %s\n", r.ActualBody))
 		}
 
+		if r.Received != nil {
+			sb.WriteString(fmt.Sprintf("- Received: %s %s (response status %d)\n",
+				r.Received.Method, r.Received.URL, r.ResponseStatus))
+		}
+
+		if j := r.Judge; j != nil {
+			if j.Error != "" {
+				sb.WriteString(fmt.Sprintf("- Judge Error (%s): %s\n", j.Model, j.Error))
+			} else {
+				sb.WriteString(fmt.Sprintf("- Judge Score: %.2f (%s)\n", j.Score, j.Model))
+				sb.WriteString(fmt.Sprintf("- Judge Reasoning: %s\n", j.Reasoning))
+			}
+		}
+
+		if a := r.ErrorAnalysis; a != nil {
+			sb.WriteString(fmt.Sprintf("- Error Analysis (%s, %s severity): %s\n", a.ErrorType, a.ErrorSeverity, a.Analysis))
+			if a.Suggestions != "" {
+				sb.WriteString(fmt.Sprintf("- Suggestions: %s\n", a.Suggestions))
+			}
+		}
+
+		if v := r.ResponseValidation; v != nil {
+			sb.WriteString(fmt.Sprintf("- Response Score: %.2f (valid: %v) - %s\n",
+				v.SatisfactionScore, v.IsValid, v.Reasoning))
+		}
+
 		if r.Details != "" {
 			sb.WriteString(fmt.Sprintf("- Details: %s\n", r.Details))
 		}
 
-		if r.Error != "" {
+		switch {
+		case r.ErrorCategory != "":
+			sb.WriteString(fmt.Sprintf("- Error (%s): %s\n", r.ErrorCategory, r.Error))
+		case r.Error != "":
 			sb.WriteString(fmt.Sprintf("- Error: %s\n", r.Error))
 		}
 
@@ -2,12 +2,23 @@ package evals_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/stephenbyrne99/ncurl/internal/cassette"
 	"github.com/stephenbyrne99/ncurl/internal/evals"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/mockllm"
+	"github.com/stephenbyrne99/ncurl/internal/usage"
 )
 
 // TestEvaluatorBasics tests the basic functionality of the evaluator
@@ -78,6 +89,518 @@ func TestLoadTestCases(t *testing.T) {
 	}
 }
 
+func TestURLMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		match   evals.URLMatch
+		url     string
+		reasons int
+	}{
+		{"scheme", evals.URLMatch{Scheme: "https"}, "HTTPS://api.test/users", 0},
+		{"wrong scheme", evals.URLMatch{Scheme: "https"}, "http://api.test/users", 1},
+		{"host without port", evals.URLMatch{Host: "localhost"}, "http://localhost:3000/users", 0},
+		{"host with port", evals.URLMatch{Host: "localhost:3000"}, "http://localhost:8080/users", 1},
+		{"host glob", evals.URLMatch{HostGlob: "*.github.com"}, "https://api.github.com/users", 0},
+		{"host glob mismatch", evals.URLMatch{HostGlob: "*.github.com"}, "https://github.com/users", 1},
+		{"host regex", evals.URLMatch{HostRegex: `^api\.(example|github)\.com$`}, "https://api.github.com/", 0},
+		{"path template", evals.URLMatch{Path: "/users/{id}/posts"}, "https://api.test/users/42/posts/", 0},
+		{"path too long", evals.URLMatch{Path: "/users/{id}"}, "https://api.test/users/42/posts", 1},
+		{"path literal", evals.URLMatch{Path: "/users/{id}"}, "https://api.test/groups/42", 1},
+		{
+			"query",
+			evals.URLMatch{Query: map[string]string{"page": "2", "per_page": "*"}, ForbiddenQuery: []string{"debug"}},
+			"https://api.test/users?page=2&per_page=20",
+			0,
+		},
+		{
+			"query mismatch",
+			evals.URLMatch{Query: map[string]string{"page": "2", "sort": "*"}, ForbiddenQuery: []string{"debug"}},
+			"https://api.test/users?page=3&debug=1",
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reasons := tt.match.Match(tt.url); len(reasons) != tt.reasons {
+				t.Errorf("Expected %d mismatches for %s, got %q", tt.reasons, tt.url, reasons)
+			}
+		})
+	}
+}
+
+func TestURLExpectations(t *testing.T) {
+	server, err := mockllm.New([]mockllm.Fixture{
+		{Response: `{"method": "GET", "url": "https://api.github.com/users/42?page=2"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cases := []evals.EvalCase{
+		{ID: "regex", Input: "a", ExpectedURLRegex: `(?i)users/\d+`},
+		{ID: "regex-mismatch", Input: "b", ExpectedURLRegex: `/accounts/`},
+		{ID: "match", Input: "c", ExpectedURLMatch: &evals.URLMatch{
+			Scheme: "https", HostGlob: "api.*.com", Path: "/users/{id}", Query: map[string]string{"page": "2"},
+		}},
+		{ID: "match-mismatch", Input: "d", ExpectedURLMatch: &evals.URLMatch{ForbiddenQuery: []string{"page"}}},
+	}
+	evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second,
+		evals.WithClientOptions(llm.WithBaseURL(ts.URL)))
+	if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+		t.Fatalf("LoadTestCases failed: %v", loadErr)
+	}
+	results, err := evaluator.RunAll(context.Background())
+	if err != nil {
+		t.Fatalf("RunAll failed: %v", err)
+	}
+	for _, r := range results {
+		if want := !strings.HasSuffix(r.TestID, "-mismatch"); r.Success != want {
+			t.Errorf("Expected case %s success to be %v, got %+v", r.TestID, want, r)
+		}
+	}
+
+	invalid := [][]evals.EvalCase{
+		{{ID: "regex", ExpectedURLRegex: "("}},
+		{{ID: "host-regex", ExpectedURLMatch: &evals.URLMatch{HostRegex: "("}}},
+		{{ID: "host-glob", ExpectedURLMatch: &evals.URLMatch{HostGlob: "["}}},
+	}
+	for _, cases := range invalid {
+		if loadErr := evaluator.LoadTestCases(cases); !errors.Is(loadErr, evals.ErrInvalidEvaluation) {
+			t.Errorf("Expected ErrInvalidEvaluation for case %s, got %v", cases[0].ID, loadErr)
+		}
+	}
+}
+
+func TestBodyMatch(t *testing.T) {
+	jsonBody := `{"name":"Jane","age":30,"tags":["a","b"],"address":{"city":"Boston","zip":"02101"}}`
+	tests := []struct {
+		name  string
+		match evals.BodyMatch
+		spec  httpx.RequestSpec
+		diffs []string // Expected substrings, one per difference
+	}{
+		{
+			name:  "json subset",
+			match: evals.BodyMatch{JSON: json.RawMessage(`{"age": 30.0, "tags": ["b"], "address": {"city": "Boston"}}`)},
+			spec:  httpx.RequestSpec{Body: jsonBody},
+		},
+		{
+			name:  "json mismatch",
+			match: evals.BodyMatch{JSON: json.RawMessage(`{"name": "John", "email": "x", "tags": ["c"]}`)},
+			spec:  httpx.RequestSpec{Body: jsonBody},
+			diffs: []string{`$.email: missing, expected "x"`, `$.name: expected "John", got "Jane"`, `$.tags[0]: no element`},
+		},
+		{
+			name:  "not json",
+			match: evals.BodyMatch{JSON: json.RawMessage(`{}`)},
+			spec:  httpx.RequestSpec{Body: "name=Jane"},
+			diffs: []string{"body is not JSON"},
+		},
+		{
+			name: "schema",
+			match: evals.BodyMatch{Schema: json.RawMessage(`{"type": "object", "required": ["name", "age"],
+				"properties": {"age": {"type": "integer", "minimum": 18}, "tags": {"items": {"enum": ["a", "b"]}}}}`)},
+			spec: httpx.RequestSpec{Body: jsonBody},
+		},
+		{
+			name: "schema violations",
+			match: evals.BodyMatch{Schema: json.RawMessage(`{"required": ["email"], "additionalProperties": false,
+				"properties": {"name": {"type": "string", "pattern": "^J", "maxLength": 3}, "age": {"maximum": 20},
+				"tags": {}, "address": {"type": "string"}}}`)},
+			spec: httpx.RequestSpec{Body: jsonBody},
+			diffs: []string{
+				"$.email: missing required property", "$.address: expected type", "$.age: expected at most 20",
+				"$.name: expected at most 3 characters",
+			},
+		},
+		{
+			name:  "form",
+			match: evals.BodyMatch{Form: map[string]string{"user": "jane", "token": "*"}},
+			spec:  httpx.RequestSpec{Body: "user=jane&token=abc"},
+		},
+		{
+			name:  "multipart form mismatch",
+			match: evals.BodyMatch{Form: map[string]string{"user": "jane", "token": "*"}},
+			spec:  httpx.RequestSpec{Form: map[string]string{"user": "john"}},
+			diffs: []string{`form field token: missing`, `form field user: expected "jane", got "john"`},
+		},
+		{
+			name:  "graphql",
+			match: evals.BodyMatch{GraphQL: `{ user(id: "1") { name, email } }`},
+			spec:  httpx.RequestSpec{Body: `{"query": "query {\n  user(id:\"1\") {\n    name # full name\n    email\n  }\n}"}`},
+		},
+		{
+			name:  "graphql fragment spread",
+			match: evals.BodyMatch{GraphQL: `{ node(id: 1.5) { ... on User { name } } }`},
+			spec:  httpx.RequestSpec{Body: `{ node(id: 1.5) { ...on User { name } } }`},
+		},
+		{
+			name:  "graphql number mismatch",
+			match: evals.BodyMatch{GraphQL: `{ node(limit: 1.5) { id } }`},
+			spec:  httpx.RequestSpec{Body: `{ node(limit: 1.50) { id } }`},
+			diffs: []string{`expected: {node(limit:1.5){id}}`},
+		},
+		{
+			name:  "graphql mismatch",
+			match: evals.BodyMatch{GraphQL: `{ user(id: "1") { name } }`},
+			spec:  httpx.RequestSpec{Body: `{ user(id: "2") { name } }`},
+			diffs: []string{`expected: {user(id:"1"){name}}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := tt.match.Match(&tt.spec)
+			if len(diffs) != len(tt.diffs) {
+				t.Fatalf("Expected %d differences, got %q", len(tt.diffs), diffs)
+			}
+			for i, want := range tt.diffs {
+				if !strings.Contains(diffs[i], want) {
+					t.Errorf("Expected difference %d to contain %q, got %q", i, want, diffs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBodyExpectations(t *testing.T) {
+	server, err := mockllm.New([]mockllm.Fixture{
+		{Response: `{"method": "POST", "url": "https://api.test/users", "body": "{\"name\":\"Jane\",\"age\":30}"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cases := []evals.EvalCase{
+		{ID: "fragment", Input: "a", ExpectedBody: `"name": "Jane"`},
+		{ID: "match", Input: "b", ExpectedBodyMatch: &evals.BodyMatch{JSON: json.RawMessage(`{"age": 30}`)}},
+		{ID: "match-mismatch", Input: "c", ExpectedBodyMatch: &evals.BodyMatch{JSON: json.RawMessage(`{"age": 31}`)}},
+	}
+	evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second,
+		evals.WithClientOptions(llm.WithBaseURL(ts.URL)))
+	if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+		t.Fatalf("LoadTestCases failed: %v", loadErr)
+	}
+	results, err := evaluator.RunAll(context.Background())
+	if err != nil {
+		t.Fatalf("RunAll failed: %v", err)
+	}
+	// A body mismatch costs 0.2 of the score
+	for _, r := range results {
+		if want := !strings.HasSuffix(r.TestID, "-mismatch"); (r.Score == 1) != want {
+			t.Errorf("Expected case %s perfect score to be %v, got %+v", r.TestID, want, r)
+		}
+	}
+	if !strings.Contains(results[2].Details, "Body mismatch: $.age: expected 31, got 30") {
+		t.Errorf("Expected a diff in the details, got %q", results[2].Details)
+	}
+
+	invalid := []*evals.BodyMatch{
+		{JSON: json.RawMessage(`{"name": `)},
+		{Schema: json.RawMessage(`["not", "an", "object"]`)},
+	}
+	for _, match := range invalid {
+		loadErr := evaluator.LoadTestCases([]evals.EvalCase{{ID: "invalid", ExpectedBodyMatch: match}})
+		if !errors.Is(loadErr, evals.ErrInvalidEvaluation) {
+			t.Errorf("Expected ErrInvalidEvaluation, got %v", loadErr)
+		}
+	}
+}
+
+func TestHeaderMatch(t *testing.T) {
+	github := evals.HeaderMatch{AnyOf: []evals.HeaderMatch{{Exact: "token X"}, {Exact: "Bearer X"}}}
+	optional := evals.HeaderMatch{AnyOf: []evals.HeaderMatch{{Absent: true}, {Prefix: "multipart/form-data"}}}
+	tests := []struct {
+		name    string
+		match   evals.HeaderMatch
+		value   string
+		present bool
+		want    bool
+	}{
+		{"exact", evals.HeaderMatch{Exact: "Bearer token"}, " Bearer  token ", true, true},
+		{"exact mismatch", evals.HeaderMatch{Exact: "Bearer token"}, "Bearer other", true, false},
+		{"prefix", evals.HeaderMatch{Prefix: "application/json"}, "application/json; charset=utf-8", true, true},
+		{"regex", evals.HeaderMatch{Regex: `\bgzip\b`}, "deflate, gzip", true, true},
+		{"regex mismatch", evals.HeaderMatch{Regex: `\bgzip\b`}, "deflate", true, false},
+		{"present", evals.HeaderMatch{}, "anything", true, true},
+		{"missing", evals.HeaderMatch{}, "", false, false},
+		{"absent", evals.HeaderMatch{Absent: true}, "", false, true},
+		{"not absent", evals.HeaderMatch{Absent: true}, "1", true, false},
+		{"any of first", github, "token X", true, true},
+		{"any of second", github, "Bearer X", true, true},
+		{"any of none", github, "Basic X", true, false},
+		{"any of missing", github, "", false, false},
+		{"any of absent", optional, "", false, true},
+		{"any of present", optional, "multipart/form-data; boundary=x", true, true},
+		{"any of wrong", optional, "application/json", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.match.Match(tt.value, tt.present); got != tt.want {
+				t.Errorf("Expected %s to match %q: %v, got %v", tt.match.String(), tt.value, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHeaderExpectations(t *testing.T) {
+	server, err := mockllm.New([]mockllm.Fixture{
+		{Response: `{"url": "https://api.github.com/user/repos",
+			"headers": {"authorization": "Bearer  X", "accept": "application/json"}}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cases := []evals.EvalCase{
+		{ID: "exact", Input: "a", ExpectedHeaders: map[string]string{
+			"Authorization": "Bearer X",
+			"ACCEPT":        "application/json",
+		}},
+		{ID: "flexible", Input: "b", ExpectedHeaderMatch: map[string]evals.HeaderMatch{
+			"Authorization": {AnyOf: []evals.HeaderMatch{{Exact: "token X"}, {Exact: "Bearer X"}}},
+			"X-Debug":       {Absent: true},
+		}},
+		{ID: "mismatch", Input: "c", ExpectedHeaderMatch: map[string]evals.HeaderMatch{
+			"authorization": {Exact: "token X"},
+			"Accept":        {Absent: true},
+			"User-Agent":    {},
+		}},
+		{ID: "empty", Input: "d", ExpectedHeaders: map[string]string{"Accept": ""}},
+	}
+	evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second,
+		evals.WithClientOptions(llm.WithBaseURL(ts.URL)))
+	if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+		t.Fatalf("LoadTestCases failed: %v", loadErr)
+	}
+	results, err := evaluator.RunAll(context.Background())
+	if err != nil {
+		t.Fatalf("RunAll failed: %v", err)
+	}
+	if results[0].Score != 1 || results[1].Score != 1 {
+		t.Errorf("Expected header casing and spacing not to matter, got %+v", results[:2])
+	}
+	for _, reason := range []string{
+		"Header value mismatch for Authorization: expected token X, got Bearer  X",
+		"Unexpected header: Accept",
+		"Missing expected header: User-Agent",
+	} {
+		if !strings.Contains(results[2].Details, reason) {
+			t.Errorf("Expected details to contain %q, got %q", reason, results[2].Details)
+		}
+	}
+	if want := "Header value mismatch for Accept: expected matching /^$/"; !strings.Contains(results[3].Details, want) {
+		t.Errorf("Expected an empty expected_headers value to require an empty header, got %q", results[3].Details)
+	}
+
+	invalid := evals.EvalCase{ID: "invalid", ExpectedHeaderMatch: map[string]evals.HeaderMatch{
+		"Accept": {AnyOf: []evals.HeaderMatch{{Regex: "("}}},
+	}}
+	if loadErr := evaluator.LoadTestCases([]evals.EvalCase{invalid}); !errors.Is(loadErr, evals.ErrInvalidEvaluation) {
+		t.Errorf("Expected ErrInvalidEvaluation, got %v", loadErr)
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+	server, err := mockllm.New([]mockllm.Fixture{
+		{
+			System:   "validator for HTTP responses",
+			Response: `{"is_valid": true, "satisfaction_score": 0.9, "reasoning": "The user was created"}`,
+		},
+		{Match: "create", Response: `{"method": "POST", "url": "https://api.example.com/users/42?notify=1",
+			"headers": {"content-type": "application/json"}, "body": "{\"name\": \"Jane\"}"}`},
+		{Match: "relative", Response: `{"url": "/users"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	t.Setenv(llm.BaseURLEnv, ts.URL) // For the response validator
+
+	cases := []evals.EvalCase{
+		{
+			ID:             "create",
+			Input:          "create user",
+			ExpectedMethod: "POST",
+			ExpectedURLMatch: &evals.URLMatch{
+				Host: "api.example.com", Path: "/users/{id}", Query: map[string]string{"notify": "1"},
+			},
+			ExpectedBodyMatch: &evals.BodyMatch{JSON: json.RawMessage(`{"name": "Jane"}`)},
+			ExpectedHeaderMatch: map[string]evals.HeaderMatch{
+				"Content-Length": {},
+				"Content-Type":   {Exact: "application/json"},
+			},
+			MockResponse: &evals.MockResponse{StatusCode: 201, Body: `{"id": 42}`},
+		},
+		{ID: "relative", Input: "relative"},
+	}
+	evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second,
+		evals.WithClientOptions(llm.WithBaseURL(ts.URL)),
+		evals.WithEndToEnd(true),
+		evals.WithResponseValidator(evals.NewResponseValidator("claude-3-5-haiku-latest")),
+	)
+	if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+		t.Fatalf("LoadTestCases failed: %v", loadErr)
+	}
+	results, err := evaluator.RunAll(context.Background())
+	if err != nil {
+		t.Fatalf("RunAll failed: %v", err)
+	}
+
+	created := results[0]
+	if !created.Success || created.Score != 1 {
+		t.Errorf("Expected the received request to match, got %+v", created)
+	}
+	if created.Received == nil || created.Received.URL != "https://api.example.com/users/42?notify=1" ||
+		created.Received.Body != `{"name": "Jane"}` || created.Received.Headers["X-Ncurl-Eval-Case"] != "" {
+		t.Errorf("Unexpected received request: %+v", created.Received)
+	}
+	validation := created.ResponseValidation
+	if created.ResponseStatus != 201 || validation == nil || validation.SatisfactionScore != 0.9 {
+		t.Errorf("Expected the response to be validated, got %d, %+v", created.ResponseStatus, validation)
+	}
+
+	if results[1].ErrorCategory != evals.ErrorValidation {
+		t.Errorf("Expected a request without a host to fail validation, got %+v", results[1])
+	}
+	if report := evals.GenerateReport(results); !strings.Contains(report,
+		"- Received: POST https://api.example.com/users/42?notify=1 (response status 201)") {
+		t.Errorf("Expected the received request in the report, got %s", report)
+	}
+}
+
+// TestDefaultFileUpload checks that the built-in upload case accepts a
+// request that leaves the multipart Content-Type to the client
+func TestDefaultFileUpload(t *testing.T) {
+	var upload evals.EvalCase
+	for _, c := range evals.DefaultTestCases() {
+		if c.ID == "file-upload" {
+			upload = c
+		}
+	}
+
+	server, err := mockllm.New([]mockllm.Fixture{{Response: `{"method": "POST", ` +
+		`"url": "https://upload.example.com/files", "files": [{"field": "file", "path": "report.pdf"}]}`}})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second,
+		evals.WithClientOptions(llm.WithBaseURL(ts.URL)))
+	result, err := evaluator.Run(context.Background(), &upload)
+	if err != nil || result.Score != 1 {
+		t.Errorf("Expected the upload without a Content-Type to score 1, got %v, %+v", err, result)
+	}
+}
+
+func TestJudge(t *testing.T) {
+	judgeSystem := "expert evaluator for HTTP request generation"
+	server, err := mockllm.New([]mockllm.Fixture{
+		{System: judgeSystem, Match: "weather in London",
+			Response: `{"overall_score": 0.9, "reasoning": "wttr.in is a weather API"}`},
+		{System: judgeSystem, Response: `{"overall_score": 0.3, "reasoning": "Wrong format"}`},
+		{System: "error analyst",
+			Response: `{"error_type": "ambiguity", "error_severity": "low", "analysis": "No API named"}`},
+		{Match: "London", Response: `{"url": "https://wttr.in/London"}`},
+		{Response: `{"url": "https://wttr.in/Paris"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	// The rules only accept London's URL from one API; the judge accepts any
+	cases := []evals.EvalCase{
+		{ID: "london", Input: "get the weather in London", ExpectedURL: "openweathermap"},
+		{ID: "paris", Input: "get the weather in Paris", ExpectedURL: "wttr.in"},
+	}
+	run := func(baseURL string, mode evals.JudgeMode) []evals.EvalResult {
+		evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second,
+			evals.WithClientOptions(llm.WithBaseURL(baseURL)),
+			evals.WithJudge("claude-judge", mode),
+		)
+		if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+			t.Fatalf("LoadTestCases failed: %v", loadErr)
+		}
+		results, runErr := evaluator.RunAll(context.Background())
+		if runErr != nil {
+			t.Fatalf("RunAll failed: %v", runErr)
+		}
+		return results
+	}
+
+	tests := []struct {
+		mode evals.JudgeMode
+		pass []bool
+	}{
+		{evals.JudgeAdvisory, []bool{false, true}},
+		{evals.JudgeRequired, []bool{false, false}},
+		{evals.JudgeDecisive, []bool{true, false}},
+	}
+	for _, tt := range tests {
+		results := run(ts.URL, tt.mode)
+		for i, r := range results {
+			if r.Success != tt.pass[i] {
+				t.Errorf("%s: expected case %s success to be %v, got %+v", tt.mode, r.TestID, tt.pass[i], r)
+			}
+			if r.Judge == nil || r.Judge.Model != "claude-judge" || r.Judge.Score == 0 || r.Judge.Reasoning == "" {
+				t.Errorf("%s: expected case %s to be judged, got %+v", tt.mode, r.TestID, r.Judge)
+			}
+			if failed := !r.Success; (r.ErrorAnalysis != nil) != failed {
+				t.Errorf("%s: expected error analysis only for failed cases, got %+v for %s", tt.mode, r.ErrorAnalysis, r.TestID)
+			}
+		}
+	}
+
+	results := run(ts.URL, evals.JudgeAdvisory)
+	if results[0].ErrorAnalysis.ErrorType != "ambiguity" || results[0].Score != 0.7 || results[0].Judge.Score != 0.9 {
+		t.Errorf("Expected both scores and the error analysis, got %+v", results[0])
+	}
+	report := evals.GenerateReport(results)
+	for _, line := range []string{"- Average Judge Score: 0.60 (2 judged)", "- Judge Score: 0.90 (claude-judge)",
+		"- Error Analysis (ambiguity, low severity): No API named"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("Expected report to contain %q", line)
+		}
+	}
+
+	// A judge that can't score leaves the rule-based result alone
+	failing, err := mockllm.New([]mockllm.Fixture{
+		{System: judgeSystem, Status: 400, Response: "bad request"},
+		{Response: `{"url": "https://wttr.in/Paris"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	failingServer := httptest.NewServer(failing)
+	defer failingServer.Close()
+	cases = cases[1:]
+	results = run(failingServer.URL, evals.JudgeDecisive)
+	if r := results[0]; !r.Success || r.ErrorCategory != "" || r.Judge.Error == "" ||
+		r.Judge.ErrorCategory != evals.ErrorJudge {
+		t.Errorf("Expected the rules to decide with a judge error, got %+v (judge %+v)", r, r.Judge)
+	}
+	if report := evals.GenerateReport(results); !strings.Contains(report, "- judge_error: 1") {
+		t.Errorf("Expected the judge error to be counted, got:\n%s", report)
+	}
+
+	if err = evals.ValidateJudgeMode("strict"); !errors.Is(err, evals.ErrInvalidEvaluation) {
+		t.Errorf("Expected ErrInvalidEvaluation for an unknown judge mode, got %v", err)
+	}
+}
+
 // TestEvaluation tests the evaluation process
 // This is an integration test that requires an API key
 func TestEvaluation(t *testing.T) {
@@ -119,6 +642,401 @@ func TestEvaluation(t *testing.T) {
 	}
 }
 
+// TestJudgeReplay checks that judge calls are recorded, replayed and counted
+// like the calls that generate requests
+func TestJudgeReplay(t *testing.T) {
+	server, err := mockllm.New([]mockllm.Fixture{
+		{System: "expert evaluator", Response: `{"overall_score": 0.5, "reasoning": "Wrong host"}`},
+		// The analysis is told why the case failed
+		{System: "error analyst", Match: "Wrong host",
+			Response: `{"error_type": "ambiguity", "analysis": "No host named"}`},
+		{Response: `{"url": "https://example.com/users"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+
+	cases := []evals.EvalCase{{ID: "users", Input: "list users", ExpectedURL: "/users"}}
+	run := func(opts ...llm.ClientOption) (*evals.Evaluator, []evals.EvalResult) {
+		t.Helper()
+		evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second,
+			evals.WithClientOptions(opts...), evals.WithJudge("claude-judge", evals.JudgeRequired))
+		if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+			t.Fatalf("LoadTestCases failed: %v", loadErr)
+		}
+		results, runErr := evaluator.RunAll(context.Background())
+		if runErr != nil {
+			t.Fatalf("RunAll failed: %v", runErr)
+		}
+		return evaluator, results
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recording, err := cassette.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	evaluator, recorded := run(llm.WithBaseURL(ts.URL), llm.WithRecorder(recording))
+	if saveErr := recording.Save(); saveErr != nil {
+		t.Fatalf("Save failed: %v", saveErr)
+	}
+	ts.Close()
+
+	if tokens := evaluator.JudgeUsage(); tokens.Model != "claude-judge" || tokens.Input == 0 {
+		t.Errorf("Expected the judge's tokens to be counted, got %+v", tokens)
+	}
+	if tokens := evaluator.Usage(); tokens.Input == 0 {
+		t.Errorf("Expected the generation tokens to be counted separately, got %+v", tokens)
+	}
+
+	replay, err := cassette.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	_, replayed := run(llm.WithBaseURL(ts.URL), llm.WithReplayer(replay))
+	r := replayed[0]
+	if r.Judge.Error != "" || r.Judge.Score != recorded[0].Judge.Score || r.Success {
+		t.Errorf("Expected the judgement to be replayed, got %+v", r.Judge)
+	}
+	if r.ErrorAnalysis == nil || r.ErrorAnalysis.ErrorType != "ambiguity" {
+		t.Errorf("Expected the error analysis to be replayed, got %+v", r.ErrorAnalysis)
+	}
+}
+
+// TestRecordAndReplay records model answers from a mock server, then scores
+// the same cases again from the cassette alone
+func TestRecordAndReplay(t *testing.T) {
+	server, err := mockllm.New([]mockllm.Fixture{
+		{Match: "list users", Response: `{"method": "GET", "url": "http://localhost:3000/users"}`},
+		{Match: "create a user", Response: `{"method": "PUT", "url": "http://localhost:3000/users"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+
+	cases := []evals.EvalCase{
+		{ID: "list", Input: "list users on localhost:3000", ExpectedMethod: "GET", ExpectedURL: "localhost:3000/users"},
+		{ID: "create", Input: "create a user on localhost:3000", ExpectedMethod: "POST", ExpectedURL: "/users"},
+	}
+	run := func(opts ...llm.ClientOption) []evals.EvalResult {
+		t.Helper()
+		evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second, evals.WithClientOptions(opts...))
+		if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+			t.Fatalf("LoadTestCases failed: %v", loadErr)
+		}
+		results, runErr := evaluator.RunAll(context.Background())
+		if runErr != nil {
+			t.Fatalf("RunAll failed: %v", runErr)
+		}
+		return results
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recording, err := cassette.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	recorded := run(llm.WithBaseURL(ts.URL), llm.WithRecorder(recording))
+	if saveErr := recording.Save(); saveErr != nil {
+		t.Fatalf("Save failed: %v", saveErr)
+	}
+	ts.Close()
+
+	replay, err := cassette.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	replayed := run(llm.WithBaseURL(ts.URL), llm.WithReplayer(replay))
+
+	if !recorded[0].Success || recorded[1].Success {
+		t.Errorf("Expected only the first case to pass, got %+v", recorded)
+	}
+	for i := range recorded {
+		if recorded[i].Score != replayed[i].Score || recorded[i].Details != replayed[i].Details {
+			t.Errorf("Replay scored differently:\nrecorded %+v\nreplayed %+v", recorded[i], replayed[i])
+		}
+	}
+
+	// A prompt that was never recorded fails instead of calling the model
+	evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second,
+		evals.WithClientOptions(llm.WithReplayer(replay)))
+	_, err = evaluator.Run(context.Background(), &evals.EvalCase{ID: "new", Input: "delete all users"})
+	if !errors.Is(err, cassette.ErrMiss) {
+		t.Errorf("Expected ErrMiss, got %v", err)
+	}
+}
+
+// TestParallelRateLimited runs cases on several workers against a mock
+// model that rate limits one of them
+func TestParallelRateLimited(t *testing.T) {
+	server, err := mockllm.New([]mockllm.Fixture{
+		{Match: "flaky", Status: 429, Response: "rate limited", Times: 1},
+		{Match: "limited", Status: 429, Response: "rate limited"},
+		{Response: `{"method": "GET", "url": "http://localhost:3000/items"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	// Leave retries to the evaluator rather than the SDK
+	client := anthropic.NewClient(option.WithBaseURL(ts.URL), option.WithMaxRetries(0))
+	newEvaluator := func(opts ...evals.Option) *evals.Evaluator {
+		return evals.NewEvaluator("claude-3-5-haiku-latest", 5*time.Second, append([]evals.Option{
+			evals.WithClientOptions(llm.WithAnthropicClient(&client)),
+			evals.WithParallel(4),
+			evals.WithRateLimit(1200),
+			evals.WithRetries(1, 10*time.Millisecond),
+		}, opts...)...)
+	}
+
+	var cases []evals.EvalCase
+	for _, id := range []string{"a", "b", "flaky", "c", "d", "e"} {
+		cases = append(cases, evals.EvalCase{ID: id, Input: "get " + id + " items", ExpectedURL: "/items"})
+	}
+	evaluator := newEvaluator()
+	if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+		t.Fatalf("LoadTestCases failed: %v", loadErr)
+	}
+
+	start := time.Now()
+	results, err := evaluator.RunAll(context.Background())
+	if err != nil {
+		t.Fatalf("RunAll failed: %v", err)
+	}
+	if len(results) != len(cases) {
+		t.Fatalf("Expected %d results, got %d", len(cases), len(results))
+	}
+	for i, r := range results {
+		if r.TestID != cases[i].ID || !r.Success {
+			t.Errorf("Expected case %s to pass in position %d, got %+v", cases[i].ID, i, r)
+		}
+	}
+
+	// 7 calls at 1200 a minute are spaced at least 50ms apart
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Expected the rate limit to spread the calls out, took %v", elapsed)
+	}
+
+	// A case that stays rate limited fails once its retries run out
+	evaluator = newEvaluator(evals.WithFailFast(true))
+	if loadErr := evaluator.LoadTestCases([]evals.EvalCase{{ID: "limited", Input: "limited"}}); loadErr != nil {
+		t.Fatalf("LoadTestCases failed: %v", loadErr)
+	}
+	if _, err = evaluator.RunAll(context.Background()); !errors.Is(err, llm.ErrModelFailure) {
+		t.Errorf("Expected the rate limit error, got %v", err)
+	}
+}
+
+func TestCaseErrors(t *testing.T) {
+	server, err := mockllm.New([]mockllm.Fixture{
+		{Match: "prose", Response: "Sure, here is the request you asked for."},
+		{Match: "no url", Response: `{"method": "GET"}`},
+		{Match: "broken", Status: 500, Response: "internal error"},
+		{Response: `{"method": "GET", "url": "http://localhost:3000/items"}`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mock model: %v", err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	client := anthropic.NewClient(option.WithBaseURL(ts.URL), option.WithMaxRetries(0))
+	cases := []evals.EvalCase{
+		{ID: "prose", Input: "prose"},
+		{ID: "no-url", Input: "no url"},
+		{ID: "broken", Input: "broken"},
+		{ID: "items", Input: "get items", ExpectedURL: "/items"},
+	}
+	run := func(timeout time.Duration, cases []evals.EvalCase, opts ...evals.Option) ([]evals.EvalResult, error) {
+		opts = append(opts, evals.WithClientOptions(llm.WithAnthropicClient(&client)))
+		evaluator := evals.NewEvaluator("claude-3-5-haiku-latest", timeout, opts...)
+		if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+			t.Fatalf("LoadTestCases failed: %v", loadErr)
+		}
+		return evaluator.RunAll(context.Background())
+	}
+
+	// Errored cases are recorded as failures and the run carries on
+	results, err := run(5*time.Second, cases)
+	if err != nil {
+		t.Fatalf("RunAll failed: %v", err)
+	}
+	want := []evals.ErrorCategory{evals.ErrorInvalidJSON, evals.ErrorValidation, evals.ErrorModel, ""}
+	if len(results) != len(want) {
+		t.Fatalf("Expected %d results, got %d", len(want), len(results))
+	}
+	for i, r := range results {
+		if r.ErrorCategory != want[i] || r.Success == (want[i] != "") {
+			t.Errorf("Expected case %s to have error category %q, got %+v", r.TestID, want[i], r)
+		}
+	}
+
+	report := evals.GenerateReport(results)
+	for _, line := range []string{"### Errors", "- invalid_json: 1", "- validation: 1", "- model_error: 1"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("Expected report to contain %q", line)
+		}
+	}
+
+	results, err = run(time.Nanosecond, cases[3:])
+	if err != nil || len(results) != 1 || results[0].ErrorCategory != evals.ErrorTimeout {
+		t.Errorf("Expected a timeout, got %+v, %v", results, err)
+	}
+
+	// With fail-fast the run stops at the first error
+	results, err = run(5*time.Second, cases, evals.WithFailFast(true))
+	if !errors.Is(err, llm.ErrInvalidJSON) || len(results) != 1 {
+		t.Errorf("Expected the run to stop at the first case, got %d results, %v", len(results), err)
+	}
+}
+
+func TestCompareModels(t *testing.T) {
+	cases := []evals.EvalCase{
+		{ID: "items", Input: "get items", ExpectedURL: "/items"},
+		{ID: "users", Input: "get users", ExpectedURL: "/users"},
+	}
+
+	// Each model gets its own mock, so they can answer differently
+	run := func(model string, fixtures []mockllm.Fixture) evals.ModelRun {
+		server, err := mockllm.New(fixtures)
+		if err != nil {
+			t.Fatalf("Failed to create mock model: %v", err)
+		}
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+
+		evaluator := evals.NewEvaluator(model, 5*time.Second, evals.WithClientOptions(llm.WithBaseURL(ts.URL)))
+		if loadErr := evaluator.LoadTestCases(cases); loadErr != nil {
+			t.Fatalf("LoadTestCases failed: %v", loadErr)
+		}
+		results, runErr := evaluator.RunAll(context.Background())
+		if runErr != nil {
+			t.Fatalf("RunAll failed: %v", runErr)
+		}
+		return evals.ModelRun{Model: model, Results: results, Tokens: evaluator.Usage()}
+	}
+	runs := []evals.ModelRun{
+		run("claude-3-5-haiku-latest", []mockllm.Fixture{
+			{Match: "items", Response: `{"url": "http://localhost:3000/items"}`},
+			{Response: `{"url": "http://localhost:3000/accounts"}`},
+		}),
+		run("claude-3-7-sonnet-latest", []mockllm.Fixture{
+			{Match: "items", Response: `{"url": "http://localhost:3000/items"}`},
+			{Response: `{"url": "http://localhost:3000/users"}`},
+		}),
+	}
+
+	comparison := evals.CompareModels(runs, usage.DefaultPrices)
+	if len(comparison.Models) != 2 {
+		t.Fatalf("Expected a summary per model, got %+v", comparison.Models)
+	}
+	haiku, sonnet := comparison.Models[0], comparison.Models[1]
+	if haiku.Passed != 1 || haiku.PassRate != 0.5 || sonnet.Passed != 2 || sonnet.AverageScore != 1 {
+		t.Errorf("Unexpected pass rates or scores: %+v, %+v", haiku, sonnet)
+	}
+	if haiku.InputTokens == 0 || haiku.OutputTokens == 0 || haiku.Cost == nil || sonnet.Cost == nil {
+		t.Errorf("Expected token counts and costs, got %+v, %+v", haiku, sonnet)
+	}
+	if *haiku.Cost >= *sonnet.Cost {
+		t.Errorf("Expected haiku to cost less than sonnet for the same tokens, got %v and %v", *haiku.Cost, *sonnet.Cost)
+	}
+
+	// The judge's tokens count toward the cost, at the judge model's price
+	judged := comparison.Runs[0]
+	judged.JudgeTokens = usage.Tokens{Model: "claude-3-opus", Input: 1_000_000}
+	withJudge := evals.CompareModels([]evals.ModelRun{judged}, usage.DefaultPrices).Models[0]
+	if withJudge.Cost == nil || *withJudge.Cost != *haiku.Cost+15 {
+		t.Errorf("Expected the judge's tokens to add $15, got %v and %v", withJudge.Cost, *haiku.Cost)
+	}
+	judged.JudgeTokens.Model = "unknown-judge"
+	if c := evals.CompareModels([]evals.ModelRun{judged}, usage.DefaultPrices).Models[0].Cost; c != nil {
+		t.Errorf("Expected an unknown cost with an unpriced judge, got %v", *c)
+	}
+
+	if len(comparison.Disagreements) != 1 || comparison.Disagreements[0].TestID != "users" {
+		t.Fatalf("Expected the models to disagree on users only, got %+v", comparison.Disagreements)
+	}
+	report := evals.GenerateComparisonReport(comparison)
+	for _, line := range []string{"| claude-3-5-haiku-latest | 1/2 (50.0%) | 0.85 |",
+		"#### users", "- claude-3-7-sonnet-latest: ✅ PASS (1.00) http://localhost:3000/users"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("Expected report to contain %q, got:\n%s", line, report)
+		}
+	}
+}
+
+func TestCompareToBaseline(t *testing.T) {
+	baseline := []evals.EvalResult{
+		{TestID: "steady", Success: true, Score: 1},
+		{TestID: "broken", Success: true, Score: 0.9},
+		{TestID: "fixed", Success: false, Score: 0.4},
+		{TestID: "dropped", Success: true, Score: 1},
+	}
+	results := []evals.EvalResult{
+		{TestID: "steady", Success: true, Score: 1},
+		{TestID: "broken", Success: false, Score: 0.6},
+		{TestID: "fixed", Success: true, Score: 0.8},
+		{TestID: "new", Success: false, Score: 0},
+	}
+
+	// Baselines are read from saved JSON results
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	data, err := json.Marshal(baseline)
+	if err != nil {
+		t.Fatalf("Failed to marshal baseline: %v", err)
+	}
+	if writeErr := os.WriteFile(path, data, 0600); writeErr != nil {
+		t.Fatalf("Failed to write baseline: %v", writeErr)
+	}
+	loaded, err := evals.LoadResults(path)
+	if err != nil || len(loaded) != len(baseline) {
+		t.Fatalf("LoadResults failed: %v, %+v", err, loaded)
+	}
+
+	diff := evals.CompareToBaseline(loaded, results)
+	if len(diff.NewlyFailing) != 1 || diff.NewlyFailing[0].TestID != "broken" {
+		t.Errorf("Expected broken to newly fail, got %+v", diff.NewlyFailing)
+	}
+	// A case that passed and wasn't run counts too
+	if diff.Regressions() != 2 || len(diff.NotRunPassed) != 1 || diff.NotRunPassed[0] != "dropped" {
+		t.Errorf("Expected broken and dropped to be regressions, got %d, %v", diff.Regressions(), diff.NotRunPassed)
+	}
+	if len(diff.NewlyPassing) != 1 || diff.NewlyPassing[0].TestID != "fixed" {
+		t.Errorf("Expected fixed to newly pass, got %+v", diff.NewlyPassing)
+	}
+	if len(diff.ScoreChanges) != 2 {
+		t.Errorf("Expected two score changes, got %+v", diff.ScoreChanges)
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != "new" || len(diff.Missing) != 1 || diff.Missing[0] != "dropped" {
+		t.Errorf("Expected new to be added and dropped to be missing, got %v and %v", diff.Added, diff.Missing)
+	}
+
+	report := evals.GenerateBaselineReport(diff)
+	for _, line := range []string{"- Newly Failing: 1", "- broken: 0.90 -> 0.60 (-0.30)", "- fixed: 0.40 -> 0.80 (+0.40)",
+		"- Average Score: 0.80 (baseline 0.77, +0.03)", "- Not in Baseline: new", "- Not Run: dropped",
+		"- Passed in Baseline but Not Run: dropped"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("Expected report to contain %q, got:\n%s", line, report)
+		}
+	}
+
+	if _, err = evals.LoadResults(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing baseline")
+	}
+	for _, empty := range []string{"null", "[]"} {
+		if writeErr := os.WriteFile(path, []byte(empty), 0600); writeErr != nil {
+			t.Fatalf("Failed to write baseline: %v", writeErr)
+		}
+		if _, err = evals.LoadResults(path); !errors.Is(err, evals.ErrInvalidEvaluation) {
+			t.Errorf("Expected ErrInvalidEvaluation for a %s baseline, got %v", empty, err)
+		}
+	}
+}
+
 // TestGenerateReport tests the report generation
 func TestGenerateReport(t *testing.T) {
 	results := []evals.EvalResult{
@@ -0,0 +1,117 @@
+package evals
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// HeaderMatch describes the value a case expects for a header. Every field
+// that is set must match; an empty HeaderMatch only needs the header to be
+// present. Values are compared with surrounding whitespace trimmed and
+// inner runs of whitespace collapsed to one space.
+type HeaderMatch struct {
+	Exact  string        `json:"exact,omitempty"`
+	Prefix string        `json:"prefix,omitempty"`
+	Regex  string        `json:"regex,omitempty"`
+	Absent bool          `json:"absent,omitempty"` // The header must not be sent
+	AnyOf  []HeaderMatch `json:"any_of,omitempty"` // At least one alternative must match
+}
+
+// Validate checks that the regular expressions are well formed
+func (m *HeaderMatch) Validate() error {
+	if m.Regex != "" {
+		if _, err := regexp.Compile(m.Regex); err != nil {
+			return fmt.Errorf("%w: header regex %q: %w", ErrInvalidEvaluation, m.Regex, err)
+		}
+	}
+	for i := range m.AnyOf {
+		if err := m.AnyOf[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match reports whether a header with value, or its absence when present is
+// false, satisfies the expectation
+func (m *HeaderMatch) Match(value string, present bool) bool {
+	if m.Absent {
+		return !present
+	}
+	if !present {
+		// Only an alternative such as {"absent": true} accepts a missing header
+		return m.Exact == "" && m.Prefix == "" && m.Regex == "" && m.matchAnyOf(value, present)
+	}
+
+	value = normalizeHeaderValue(value)
+	if m.Exact != "" && value != normalizeHeaderValue(m.Exact) {
+		return false
+	}
+	if m.Prefix != "" && !strings.HasPrefix(value, normalizeHeaderValue(m.Prefix)) {
+		return false
+	}
+	if m.Regex != "" {
+		re, err := regexp.Compile(m.Regex)
+		if err != nil || !re.MatchString(value) {
+			return false
+		}
+	}
+
+	return len(m.AnyOf) == 0 || m.matchAnyOf(value, present)
+}
+
+// matchAnyOf reports whether any of the alternatives matches
+func (m *HeaderMatch) matchAnyOf(value string, present bool) bool {
+	for i := range m.AnyOf {
+		if m.AnyOf[i].Match(value, present) {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the expectation for mismatch reports
+func (m *HeaderMatch) String() string {
+	if m.Absent {
+		return "absent"
+	}
+
+	var parts []string
+	if m.Exact != "" {
+		parts = append(parts, m.Exact)
+	}
+	if m.Prefix != "" {
+		parts = append(parts, "starting with "+m.Prefix)
+	}
+	if m.Regex != "" {
+		parts = append(parts, "matching /"+m.Regex+"/")
+	}
+	if len(m.AnyOf) > 0 {
+		alternatives := make([]string, len(m.AnyOf))
+		for i := range m.AnyOf {
+			alternatives[i] = m.AnyOf[i].String()
+		}
+		parts = append(parts, "one of ("+strings.Join(alternatives, " | ")+")")
+	}
+	if len(parts) == 0 {
+		return "present"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// normalizeHeaderValue trims a header value and collapses inner whitespace
+func normalizeHeaderValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// canonicalHeaders indexes headers by their canonical name, so expectations
+// match however the model cased them
+func canonicalHeaders(headers map[string]string) map[string]string {
+	canonical := make(map[string]string, len(headers))
+	for name, value := range headers {
+		canonical[http.CanonicalHeaderKey(strings.TrimSpace(name))] = value
+	}
+	return canonical
+}
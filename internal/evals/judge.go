@@ -0,0 +1,148 @@
+package evals
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+// JudgeMode says how a judge model's score affects whether a case passes
+type JudgeMode string
+
+// Judge modes for WithJudge
+const (
+	JudgeAdvisory JudgeMode = "advisory" // The judge's score is only reported
+	JudgeRequired JudgeMode = "required" // Both the rule and judge scores must pass
+	JudgeDecisive JudgeMode = "decisive" // The judge's score alone decides
+)
+
+// ValidateJudgeMode checks that mode is a known judge mode
+func ValidateJudgeMode(mode JudgeMode) error {
+	switch mode {
+	case JudgeAdvisory, JudgeRequired, JudgeDecisive:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown judge mode %q (use %s, %s or %s)",
+			ErrInvalidEvaluation, mode, JudgeAdvisory, JudgeRequired, JudgeDecisive)
+	}
+}
+
+// Judgement is a judge model's score for a generated request
+type Judgement struct {
+	Model     string  `json:"model"`
+	Score     float64 `json:"score"`               // 0.0 to 1.0
+	Reasoning string  `json:"reasoning,omitempty"` // Per-criterion scores, reasoning and suggestions
+	Error     string  `json:"error,omitempty"`     // Set when the judge couldn't score the request
+
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"` // ErrorJudge when Error is set
+}
+
+// judgeSpec has the judge model score spec against the case and applies the
+// judge mode to result. Rate limited judge calls are retried like cases. A
+// judge that still can't score the request leaves the case's result as the
+// rules decided it, with the judge's error recorded.
+func (e *Evaluator) judgeSpec(ctx context.Context, spec *httpx.RequestSpec, evalCase *EvalCase, result *EvalResult) {
+	judgement := &Judgement{Model: e.judgeModel}
+	result.Judge = judgement
+
+	input := judgeInput(evalCase)
+	input.GeneratedMethod = spec.Method
+	input.GeneratedURL = spec.URL
+	input.GeneratedHeaders = formatHeaders(spec.Headers)
+	input.GeneratedBody = spec.Body
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = e.limiter.Wait(ctx); err != nil {
+			break
+		}
+		judgement.Score, judgement.Reasoning, err = evaluateRequest(ctx, e.judge, input)
+		retryAfter, limited := rateLimitDelay(err)
+		if !limited || attempt >= e.retries {
+			break
+		}
+		e.limiter.Pause(min(max(e.backoff<<attempt, retryAfter), maxBackoff))
+	}
+	if err != nil {
+		judgement.Error = err.Error()
+		judgement.ErrorCategory = ErrorJudge
+		return
+	}
+
+	const successThreshold = 0.8 // Same threshold as the rule-based score
+	judgePassed := judgement.Score >= successThreshold
+	switch e.judgeMode {
+	case JudgeRequired:
+		result.Success = result.Success && judgePassed
+	case JudgeDecisive:
+		result.Success = judgePassed
+	}
+}
+
+// analyzeFailure has the judge model explain why a case failed
+func (e *Evaluator) analyzeFailure(ctx context.Context, evalCase *EvalCase, result *EvalResult) {
+	if e.judgeModel == "" || result.Success || ctx.Err() != nil {
+		return
+	}
+
+	input := judgeInput(evalCase)
+	input.Error = result.Error
+	if input.Error == "" {
+		input.Error = result.Details
+		if result.Judge != nil && result.Judge.Reasoning != "" {
+			input.Error += "\n\nJudge:\n" + result.Judge.Reasoning
+		}
+	}
+
+	if err := e.limiter.Wait(ctx); err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	analysis, err := analyzeError(ctx, e.judge, input)
+	if err != nil {
+		analysis = &ErrorAnalysisResult{ErrorType: "unknown", Analysis: "Error analysis failed: " + err.Error()}
+	}
+	result.ErrorAnalysis = analysis
+}
+
+// judgeInput describes a case's expectations for the judge's prompts
+func judgeInput(evalCase *EvalCase) RequestEvalInput {
+	var headers []string
+	for _, name := range sortedKeys(evalCase.ExpectedHeaders) {
+		headers = append(headers, name+": "+evalCase.ExpectedHeaders[name])
+	}
+	for _, name := range sortedKeys(evalCase.ExpectedHeaderMatch) {
+		match := evalCase.ExpectedHeaderMatch[name]
+		headers = append(headers, name+": "+match.String())
+	}
+
+	var body []string
+	if evalCase.ExpectedBody != "" {
+		body = append(body, evalCase.ExpectedBody)
+	}
+	if m := evalCase.ExpectedBodyMatch; m != nil {
+		if len(m.JSON) > 0 {
+			body = append(body, "JSON containing "+string(m.JSON))
+		}
+		if len(m.Schema) > 0 {
+			body = append(body, "JSON matching the schema "+string(m.Schema))
+		}
+		for _, name := range sortedKeys(m.Form) {
+			body = append(body, "form field "+name+"="+m.Form[name])
+		}
+		if m.GraphQL != "" {
+			body = append(body, "GraphQL query "+m.GraphQL)
+		}
+	}
+
+	return RequestEvalInput{
+		NaturalLanguage: evalCase.Input,
+		ExpectedMethod:  evalCase.ExpectedMethod,
+		ExpectedURL:     evalCase.expectedURL(),
+		ExpectedHeaders: strings.Join(headers, "\n"),
+		ExpectedBody:    strings.Join(body, "\n"),
+	}
+}
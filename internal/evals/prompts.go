@@ -9,7 +9,7 @@ import (
 	"strings"
 	"text/template"
 
-	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
 )
 
 // RequestEvalInput represents input data for request evaluation prompts
@@ -24,6 +24,7 @@ type RequestEvalInput struct {
 	ExpectedHeaders    string `json:"expected_headers"`
 	ExpectedBody       string `json:"expected_body"`
 	EvaluationCriteria string `json:"evaluation_criteria,omitempty"`
+	Error              string `json:"error,omitempty"` // Why the case failed, for error analysis
 }
 
 // PromptTemplate for evaluating ncurl requests
@@ -97,7 +98,7 @@ Natural language input:
 {{.NaturalLanguage}}
 
 Error message:
-{{.GeneratedBody}}
+{{.Error}}
 
 Please analyze this failure and provide insights in JSON format:
 {
@@ -150,50 +151,38 @@ func RenderTemplate(tmpl string, data interface{}) (string, error) {
 	return buf.String(), nil
 }
 
-// EvaluateWithAnthropicPrompt sends a prompt to Anthropic Claude to evaluate the request
-func EvaluateWithAnthropicPrompt(ctx context.Context, model string, input RequestEvalInput) (float64, string, error) {
-	// Use default model if not specified
-	if model == "" {
-		model = anthropic.ModelClaude3_7SonnetLatest
-	}
-
-	// Render the prompt templates
-	promptTemplate := DefaultPromptTemplates.RequestEvaluation
+// renderPrompts renders the system and user prompts of a template
+func renderPrompts(promptTemplate PromptTemplate, input RequestEvalInput) (string, string, error) {
 	systemPrompt, err := RenderTemplate(promptTemplate.SystemPrompt, input)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to render system prompt: %w", err)
+		return "", "", fmt.Errorf("failed to render system prompt: %w", err)
 	}
 
 	userPrompt, err := RenderTemplate(promptTemplate.UserPrompt, input)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to render user prompt: %w", err)
+		return "", "", fmt.Errorf("failed to render user prompt: %w", err)
 	}
 
-	// Create Anthropic client
-	client := anthropic.NewClient() // uses ANTHROPIC_API_KEY env var
-
-	// Send the request to Anthropic
-	msg, msgErr := client.Messages.New(ctx, anthropic.MessageNewParams{
-		Model:     model,
-		MaxTokens: 1024, // Standard token limit
-		System: []anthropic.TextBlockParam{
-			{Text: systemPrompt},
-		},
-		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(userPrompt)),
-		},
-	})
-
-	if msgErr != nil {
-		return 0, "", fmt.Errorf("anthropic API error: %w", msgErr)
-	}
+	return systemPrompt, userPrompt, nil
+}
+
+// EvaluateWithAnthropicPrompt sends a prompt to Anthropic Claude to evaluate the request
+func EvaluateWithAnthropicPrompt(ctx context.Context, model string, input RequestEvalInput) (float64, string, error) {
+	return evaluateRequest(ctx, llm.NewClient(model), input) // uses ANTHROPIC_API_KEY and ANTHROPIC_BASE_URL
+}
 
-	// Extract the response content
-	if len(msg.Content) == 0 {
-		return 0, "", errors.New("empty response from Anthropic")
+// evaluateRequest has client score a generated request with the request
+// evaluation prompt, returning the overall score and the details
+func evaluateRequest(ctx context.Context, client *llm.Client, input RequestEvalInput) (float64, string, error) {
+	systemPrompt, userPrompt, err := renderPrompts(DefaultPromptTemplates.RequestEvaluation, input)
+	if err != nil {
+		return 0, "", err
 	}
 
-	responseText := msg.Content[0].Text
+	responseText, err := client.Complete(ctx, llm.PurposeJudge, systemPrompt, userPrompt)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to evaluate request: %w", err)
+	}
 
 	// Parse the JSON response
 	type EvaluationResponse struct {
@@ -242,3 +231,43 @@ func EvaluateWithAnthropicPrompt(ctx context.Context, model string, input Reques
 
 	return evalResponse.OverallScore, details, nil
 }
+
+// ErrorAnalysisResult is a model's analysis of why a case failed
+type ErrorAnalysisResult struct {
+	ErrorType     string `json:"error_type"`
+	ErrorSeverity string `json:"error_severity"`
+	Analysis      string `json:"analysis"`
+	Suggestions   string `json:"suggestions,omitempty"`
+}
+
+// AnalyzeErrorWithAnthropicPrompt asks Anthropic Claude why the input failed,
+// using the error analysis prompt. input.Error holds the error or the
+// scoring details.
+func AnalyzeErrorWithAnthropicPrompt(
+	ctx context.Context,
+	model string,
+	input RequestEvalInput,
+) (*ErrorAnalysisResult, error) {
+	return analyzeError(ctx, llm.NewClient(model), input) // uses ANTHROPIC_API_KEY and ANTHROPIC_BASE_URL
+}
+
+// analyzeError has client explain a failure with the error analysis prompt
+func analyzeError(ctx context.Context, client *llm.Client, input RequestEvalInput) (*ErrorAnalysisResult, error) {
+	systemPrompt, userPrompt, err := renderPrompts(DefaultPromptTemplates.ErrorAnalysis, input)
+	if err != nil {
+		return nil, err
+	}
+
+	responseText, err := client.Complete(ctx, llm.PurposeJudge, systemPrompt, userPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze error: %w", err)
+	}
+
+	jsonStr := llm.CleanJSONResponse(responseText)
+	var result ErrorAnalysisResult
+	if unmarshalErr := json.Unmarshal([]byte(jsonStr), &result); unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to parse error analysis: %w", unmarshalErr)
+	}
+
+	return &result, nil
+}
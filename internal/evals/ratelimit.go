@@ -0,0 +1,93 @@
+package evals
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// maxBackoff caps the wait after the provider reports a rate limit
+const maxBackoff = time.Minute
+
+// statusOverloaded is Anthropic's status for a temporarily overloaded API
+const statusOverloaded = 529
+
+// rateLimiter spaces model calls evenly, as a token bucket holding a single
+// token, and lets one worker pause all of them after hitting a rate limit
+type rateLimiter struct {
+	interval time.Duration // Time to earn a token; 0 means no limit
+
+	mu          sync.Mutex
+	next        time.Time // When the next token is available
+	pausedUntil time.Time
+}
+
+// newRateLimiter allows requestsPerMinute calls a minute, or any number if 0
+func newRateLimiter(requestsPerMinute int) *rateLimiter {
+	l := &rateLimiter{}
+	if requestsPerMinute > 0 {
+		l.interval = time.Minute / time.Duration(requestsPerMinute)
+	}
+	return l
+}
+
+// Wait blocks until a call may be made or ctx is done
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	start := now
+	if l.pausedUntil.After(start) {
+		start = l.pausedUntil
+	}
+	if l.next.After(start) {
+		start = l.next
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := start.Sub(now)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Pause holds back every call for d
+func (l *rateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// rateLimitDelay reports whether err means the provider is rate limiting
+// or overloaded, and how long it asked callers to wait, if it said
+func rateLimitDelay(err error) (time.Duration, bool) {
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode != statusOverloaded {
+		return 0, false
+	}
+
+	if apiErr.Response != nil {
+		if seconds, parseErr := strconv.Atoi(apiErr.Response.Header.Get("Retry-After")); parseErr == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, true
+}
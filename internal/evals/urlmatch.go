@@ -0,0 +1,174 @@
+package evals
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// anyValue as an expected query parameter value accepts any value
+const anyValue = "*"
+
+// URLMatch describes the parts of a URL a case expects. Empty fields match
+// anything, so a case only lists what matters to it.
+type URLMatch struct {
+	Scheme    string `json:"scheme,omitempty"`     // e.g. "https"
+	Host      string `json:"host,omitempty"`       // Exact host, compared with the port only if it has one
+	HostGlob  string `json:"host_glob,omitempty"`  // Glob such as "*.example.com"
+	HostRegex string `json:"host_regex,omitempty"` // Regular expression for the host and port
+
+	// Path is a template such as "/users/{id}", where each {name} matches
+	// exactly one non-empty segment. A trailing slash is ignored.
+	Path string `json:"path,omitempty"`
+
+	// Query holds parameters that must be present, by name. A value of "*"
+	// accepts any value.
+	Query map[string]string `json:"query,omitempty"`
+
+	// ForbiddenQuery lists parameters that must not be present
+	ForbiddenQuery []string `json:"forbidden_query,omitempty"`
+}
+
+// Validate checks that the host glob and regex are well formed
+func (m *URLMatch) Validate() error {
+	if m.HostGlob != "" {
+		if _, err := path.Match(m.HostGlob, ""); err != nil {
+			return fmt.Errorf("%w: host_glob %q: %w", ErrInvalidEvaluation, m.HostGlob, err)
+		}
+	}
+	if m.HostRegex != "" {
+		if _, err := regexp.Compile(m.HostRegex); err != nil {
+			return fmt.Errorf("%w: host_regex %q: %w", ErrInvalidEvaluation, m.HostRegex, err)
+		}
+	}
+	return nil
+}
+
+// Match compares rawURL against the expectation and returns a reason for
+// each part that doesn't match, or nil if they all do
+func (m *URLMatch) Match(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return []string{fmt.Sprintf("URL %q can't be parsed: %v", rawURL, err)}
+	}
+
+	var reasons []string
+	if m.Scheme != "" && !strings.EqualFold(u.Scheme, m.Scheme) {
+		reasons = append(reasons, fmt.Sprintf("scheme: expected %s, got %s", m.Scheme, u.Scheme))
+	}
+	reasons = append(reasons, m.matchHost(u)...)
+	if m.Path != "" && !matchPathTemplate(m.Path, u.Path) {
+		reasons = append(reasons, fmt.Sprintf("path: expected %s, got %s", m.Path, u.Path))
+	}
+	reasons = append(reasons, m.matchQuery(u.Query())...)
+	return reasons
+}
+
+// matchHost checks the host against the exact host, glob and regex
+func (m *URLMatch) matchHost(u *url.URL) []string {
+	// Compare with the port only when the expectation has one
+	host := func(expected string) string {
+		if strings.Contains(expected, ":") {
+			return strings.ToLower(u.Host)
+		}
+		return strings.ToLower(u.Hostname())
+	}
+
+	var reasons []string
+	if m.Host != "" && host(m.Host) != strings.ToLower(m.Host) {
+		reasons = append(reasons, fmt.Sprintf("host: expected %s, got %s", m.Host, u.Host))
+	}
+	if m.HostGlob != "" {
+		if ok, err := path.Match(strings.ToLower(m.HostGlob), host(m.HostGlob)); err != nil || !ok {
+			reasons = append(reasons, fmt.Sprintf("host: expected to match %s, got %s", m.HostGlob, u.Host))
+		}
+	}
+	if m.HostRegex != "" {
+		re, err := regexp.Compile(m.HostRegex)
+		if err != nil || !re.MatchString(u.Host) {
+			reasons = append(reasons, fmt.Sprintf("host: expected to match /%s/, got %s", m.HostRegex, u.Host))
+		}
+	}
+	return reasons
+}
+
+// matchQuery checks required and forbidden query parameters
+func (m *URLMatch) matchQuery(query url.Values) []string {
+	var reasons []string
+
+	for _, name := range sortedKeys(m.Query) {
+		want := m.Query[name]
+		values, ok := query[name]
+		switch {
+		case !ok:
+			reasons = append(reasons, fmt.Sprintf("query: missing parameter %s", name))
+		case want != anyValue && !contains(values, want):
+			reasons = append(reasons, fmt.Sprintf("query: expected %s=%s, got %s=%s",
+				name, want, name, strings.Join(values, ",")))
+		}
+	}
+
+	for _, name := range m.ForbiddenQuery {
+		if query.Has(name) {
+			reasons = append(reasons, fmt.Sprintf("query: unexpected parameter %s", name))
+		}
+	}
+	return reasons
+}
+
+// matchPathTemplate reports whether actual fits a template such as
+// "/users/{id}"
+func matchPathTemplate(template, actual string) bool {
+	want := strings.Split(strings.Trim(template, "/"), "/")
+	got := strings.Split(strings.Trim(actual, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i, segment := range want {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if got[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether values includes want
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// String summarizes the expectation for reports
+func (m *URLMatch) String() string {
+	var parts []string
+	add := func(name, value string) {
+		if value != "" {
+			parts = append(parts, name+"="+value)
+		}
+	}
+	add("scheme", m.Scheme)
+	add("host", m.Host)
+	add("host_glob", m.HostGlob)
+	add("host_regex", m.HostRegex)
+	add("path", m.Path)
+
+	for _, name := range sortedKeys(m.Query) {
+		add("query."+name, m.Query[name])
+	}
+	if len(m.ForbiddenQuery) > 0 {
+		add("forbidden_query", strings.Join(m.ForbiddenQuery, ","))
+	}
+	return strings.Join(parts, " ")
+}
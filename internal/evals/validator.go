@@ -12,6 +12,7 @@ import (
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/llm"
 )
 
 // ResponseValidator validates HTTP responses against expectations
@@ -81,7 +82,7 @@ func (v *ResponseValidator) ValidateResponse(
 	}
 
 	// Create Anthropic client
-	client := anthropic.NewClient() // uses ANTHROPIC_API_KEY env var
+	client := llm.NewAnthropicClient() // uses ANTHROPIC_API_KEY and ANTHROPIC_BASE_URL
 
 	// Send the request to Anthropic
 	msg, err := client.Messages.New(ctx, anthropic.MessageNewParams{
@@ -168,7 +169,7 @@ Provide your evaluation in JSON format with these fields:
 	)
 
 	// Create Anthropic client
-	client := anthropic.NewClient() // uses ANTHROPIC_API_KEY env var
+	client := llm.NewAnthropicClient() // uses ANTHROPIC_API_KEY and ANTHROPIC_BASE_URL
 
 	// Send the request to Anthropic
 	msg, err := client.Messages.New(ctx, anthropic.MessageNewParams{
@@ -18,9 +18,14 @@ const (
 
 // Entry represents a single command in the history
 type Entry struct {
-	Timestamp time.Time `json:"timestamp"`
-	Command   string    `json:"command"`
-	Success   bool      `json:"success"`
+	Timestamp    time.Time `json:"timestamp"`
+	Command      string    `json:"command"`
+	Success      bool      `json:"success"`
+	StatusCode   int       `json:"status_code,omitempty"`   // HTTP status, if the request completed
+	Model        string    `json:"model,omitempty"`         // Model used, if it was called
+	InputTokens  int64     `json:"input_tokens,omitempty"`  // Tokens sent to the model
+	OutputTokens int64     `json:"output_tokens,omitempty"` // Tokens generated by the model
+	Cost         float64   `json:"cost,omitempty"`          // Estimated model cost in US dollars
 }
 
 // Manager handles the saving and loading of command history
@@ -58,17 +63,19 @@ func NewManager(maxEntries int) (*Manager, error) {
 
 // AddEntry adds a new entry to the history
 func (m *Manager) AddEntry(command string, success bool) error {
+	return m.Add(Entry{Command: command, Success: success})
+}
+
+// Add adds a new entry to the history, timestamping it if needed
+func (m *Manager) Add(entry Entry) error {
 	entries, err := m.GetEntries()
 	if err != nil {
 		// If we can't read the history, just start with an empty slice
 		entries = []Entry{}
 	}
 
-	// Add new entry
-	entry := Entry{
-		Timestamp: time.Now(),
-		Command:   command,
-		Success:   success,
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
 	}
 
 	// Prepend the new entry (most recent first)
@@ -143,6 +150,19 @@ func (m *Manager) saveEntries(entries []Entry) error {
 	return nil
 }
 
+// status returns the success mark for an entry, followed by the HTTP
+// status code when one was recorded
+func (e Entry) status() string {
+	mark := successMark
+	if !e.Success {
+		mark = failureMark
+	}
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("%s %d", mark, e.StatusCode)
+	}
+	return mark
+}
+
 // PrintHistory prints the command history to stdout
 func (m *Manager) PrintHistory() error {
 	entries, err := m.GetEntries()
@@ -158,11 +178,7 @@ func (m *Manager) PrintHistory() error {
 	fmt.Println("Command History:")
 	fmt.Println("---------------")
 	for i, entry := range entries {
-		status := successMark
-		if !entry.Success {
-			status = failureMark
-		}
-		fmt.Printf("%d. [%s] %s (%s)\n", i+1, status, entry.Command, entry.Timestamp.Format("2006-01-02 15:04:05"))
+		fmt.Printf("%d. [%s] %s (%s)\n", i+1, entry.status(), entry.Command, entry.Timestamp.Format("2006-01-02 15:04:05"))
 	}
 
 	return nil
@@ -223,11 +239,7 @@ func (m *Manager) PrintSearchResults(term string) error {
 	fmt.Printf("Commands matching '%s':\n", term)
 	fmt.Println("---------------")
 	for i, entry := range results {
-		status := successMark
-		if !entry.Success {
-			status = failureMark
-		}
-		fmt.Printf("%d. [%s] %s (%s)\n", i+1, status, entry.Command, entry.Timestamp.Format("2006-01-02 15:04:05"))
+		fmt.Printf("%d. [%s] %s (%s)\n", i+1, entry.status(), entry.Command, entry.Timestamp.Format("2006-01-02 15:04:05"))
 	}
 
 	return nil
@@ -247,11 +259,7 @@ func (m *Manager) PromptForHistorySelection() (string, error) {
 	fmt.Println("Command History:")
 	fmt.Println("---------------")
 	for i, entry := range entries {
-		status := successMark
-		if !entry.Success {
-			status = failureMark
-		}
-		fmt.Printf("%d. [%s] %s (%s)\n", i+1, status, entry.Command, entry.Timestamp.Format("2006-01-02 15:04:05"))
+		fmt.Printf("%d. [%s] %s (%s)\n", i+1, entry.status(), entry.Command, entry.Timestamp.Format("2006-01-02 15:04:05"))
 	}
 
 	var selectedIndex int
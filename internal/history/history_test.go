@@ -77,6 +77,32 @@ func TestHistoryOperations(t *testing.T) {
 	}
 }
 
+func TestAddWithStatusCode(t *testing.T) {
+	manager := history.NewTestManager(filepath.Join(t.TempDir(), "history.json"), 10)
+
+	entry := history.Entry{Command: "get a missing user", StatusCode: 404, Model: "m", InputTokens: 812, Cost: 0.004}
+	if err := manager.Add(entry); err != nil {
+		t.Fatalf("Failed to add entry: %v", err)
+	}
+
+	entries, err := manager.GetEntries()
+	if err != nil {
+		t.Fatalf("Failed to get entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].StatusCode != 404 || entries[0].Success {
+		t.Errorf("Expected a failed entry with status 404, got %+v", entries[0])
+	}
+	if entries[0].Timestamp.IsZero() {
+		t.Error("Expected Add to set the timestamp")
+	}
+	if entries[0].Model != "m" || entries[0].InputTokens != 812 || entries[0].Cost != 0.004 {
+		t.Errorf("Expected model usage to be kept, got %+v", entries[0])
+	}
+}
+
 func TestGetEntryByIndex(t *testing.T) {
 	// Create a temporary directory for test history
 	tempDir := t.TempDir() // Uses testing's built-in temporary directory that's automatically cleaned up
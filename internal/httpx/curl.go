@@ -0,0 +1,79 @@
+package httpx
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Curl returns an equivalent curl command line for the request, with every
+// argument quoted for a POSIX shell
+func (rs *RequestSpec) Curl() string {
+	args := []string{"curl"}
+
+	method := rs.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet || rs.Body != "" || rs.BodyFile != "" || rs.IsMultipart() {
+		args = append(args, "-X", method)
+	}
+
+	headers := make([]string, 0, len(rs.Headers))
+	for k := range rs.Headers {
+		headers = append(headers, k)
+	}
+	sort.Strings(headers)
+	for _, k := range headers {
+		args = append(args, "-H", shellQuote(k+": "+rs.Headers[k]))
+	}
+
+	switch {
+	case rs.BodyFile != "":
+		args = append(args, "--data-binary", shellQuote("@"+rs.BodyFile))
+	case rs.Body != "":
+		args = append(args, "--data-raw", shellQuote(rs.Body))
+	}
+
+	fields := make([]string, 0, len(rs.Form))
+	for k := range rs.Form {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, k := range fields {
+		// --form-string sends the value literally, even if it starts with @ or <
+		args = append(args, "--form-string", shellQuote(k+"="+rs.Form[k]))
+	}
+	for _, part := range rs.Files {
+		value := part.Field + "=@" + part.Path
+		if part.Filename != "" {
+			value += ";filename=" + part.Filename
+		}
+		if part.ContentType != "" {
+			value += ";type=" + part.ContentType
+		}
+		args = append(args, "-F", shellQuote(value))
+	}
+
+	args = append(args, shellQuote(rs.URL))
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for a POSIX shell unless every character is safe
+// to leave unquoted
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, needsQuoting) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// needsQuoting reports whether a shell would treat r specially
+func needsQuoting(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	default:
+		return !strings.ContainsRune("-_./:=@,+%", r)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -39,17 +40,29 @@ func (e *RequestError) Unwrap() error {
 
 // RequestSpec represents a structured HTTP request specification
 type RequestSpec struct {
-	Method  string            `json:"method"`
-	URL     string            `json:"url"`
-	Headers map[string]string `json:"headers,omitempty"`
-	Body    string            `json:"body,omitempty"`
+	Method   string            `json:"method"`
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	BodyFile string            `json:"body_file,omitempty"` // Local file streamed as the body
+	Form     map[string]string `json:"form,omitempty"`      // Multipart form fields
+	Files    []FilePart        `json:"files,omitempty"`     // Multipart file parts read from local paths
+}
+
+// FilePart references a local file to upload as part of a multipart/form-data body
+type FilePart struct {
+	Field       string `json:"field"`                  // Form field name
+	Path        string `json:"path"`                   // Local file path
+	Filename    string `json:"filename,omitempty"`     // Defaults to the base name of Path
+	ContentType string `json:"content_type,omitempty"` // Detected from the file when empty
 }
 
 // Response bundles the http.Response with the fully-read body so the
 // caller can safely access it after the Response is closed.
 type Response struct {
 	*http.Response
-	Body []byte
+	Body     []byte
+	Duration time.Duration // Time from sending the request to reading the whole body
 }
 
 // NewRequestSpec creates a new RequestSpec with default values
@@ -74,21 +87,93 @@ func (rs *RequestSpec) Validate() error {
 		rs.Headers = make(map[string]string)
 	}
 
+	if rs.BodyFile != "" && (rs.Body != "" || rs.IsMultipart()) {
+		return fmt.Errorf("%w: body_file cannot be combined with body, form fields or files", ErrInvalidRequest)
+	}
+
+	if rs.IsMultipart() {
+		if rs.Body != "" {
+			return fmt.Errorf("%w: body cannot be combined with form fields or files", ErrInvalidRequest)
+		}
+		for i, part := range rs.Files {
+			if part.Field == "" || part.Path == "" {
+				return fmt.Errorf("%w: file part %d needs both a field and a path", ErrInvalidRequest, i+1)
+			}
+		}
+	}
+
 	return nil
 }
 
+// IsMultipart reports whether the request is sent as a multipart/form-data body
+func (rs *RequestSpec) IsMultipart() bool {
+	return len(rs.Form) > 0 || len(rs.Files) > 0
+}
+
+// executeConfig holds optional settings for executing a request
+type executeConfig struct {
+	jar http.CookieJar
+}
+
+// ExecuteOption is a functional option for configuring request execution
+type ExecuteOption func(*executeConfig)
+
+// WithCookieJar sends and stores cookies using the given jar
+func WithCookieJar(jar http.CookieJar) ExecuteOption {
+	return func(c *executeConfig) {
+		c.jar = jar
+	}
+}
+
 // Execute sends the HTTP request defined by the RequestSpec and returns a Response
-func Execute(spec *RequestSpec) (*Response, error) {
-	return ExecuteWithContext(context.Background(), spec)
+func Execute(spec *RequestSpec, opts ...ExecuteOption) (*Response, error) {
+	return ExecuteWithContext(context.Background(), spec, opts...)
 }
 
 // ExecuteWithContext sends the HTTP request with context and returns a Response
-func ExecuteWithContext(ctx context.Context, spec *RequestSpec) (*Response, error) {
+func ExecuteWithContext(ctx context.Context, spec *RequestSpec, opts ...ExecuteOption) (*Response, error) {
 	if err := spec.Validate(); err != nil {
 		return nil, err
 	}
 
-	reqBody := strings.NewReader(spec.Body)
+	cfg := &executeConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var reqBody io.Reader = strings.NewReader(spec.Body)
+	var contentType string
+	contentLength := int64(-1)
+	switch {
+	case spec.BodyFile != "":
+		file, size, fileErr := openBodyFile(spec.BodyFile)
+		if fileErr != nil {
+			return nil, &RequestError{
+				Err:     fileErr,
+				Message: "failed to open body file",
+				URL:     spec.URL,
+				Method:  spec.Method,
+			}
+		}
+		defer func() { _ = file.Close() }()
+		reqBody = file
+		contentLength = size
+
+	case spec.IsMultipart():
+		body, multipartType, bodyErr := newMultipartBody(spec)
+		if bodyErr != nil {
+			return nil, &RequestError{
+				Err:     bodyErr,
+				Message: "failed to prepare multipart body",
+				URL:     spec.URL,
+				Method:  spec.Method,
+			}
+		}
+		defer func() { _ = body.Close() }()
+		reqBody = body
+		contentType = multipartType
+	}
+
 	req, err := http.NewRequestWithContext(ctx, spec.Method, spec.URL, reqBody)
 	if err != nil {
 		return nil, &RequestError{
@@ -99,13 +184,25 @@ func ExecuteWithContext(ctx context.Context, spec *RequestSpec) (*Response, erro
 		}
 	}
 
+	// Let the transport send a Content-Length instead of a chunked body
+	if contentLength >= 0 {
+		req.ContentLength = contentLength
+	}
+
 	for k, v := range spec.Headers {
 		req.Header.Set(k, v)
 	}
 
+	// Generated bodies carry their own content type (e.g. the multipart boundary)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
 	client := &http.Client{
 		Timeout: 30 * time.Second, // Default client timeout
+		Jar:     cfg.jar,
 	}
+	start := time.Now()
 	resp, doErr := client.Do(req)
 	if doErr != nil {
 		return nil, &RequestError{
@@ -133,7 +230,7 @@ func ExecuteWithContext(ctx context.Context, spec *RequestSpec) (*Response, erro
 		}
 	}
 
-	result := &Response{Response: resp, Body: body}
+	result := &Response{Response: resp, Body: body, Duration: time.Since(start)}
 
 	if closeErr != nil {
 		// Return response but with an error
@@ -149,3 +246,24 @@ func ExecuteWithContext(ctx context.Context, spec *RequestSpec) (*Response, erro
 
 	return result, nil
 }
+
+// openBodyFile opens a file to stream as the request body and returns its size
+func openBodyFile(path string) (*os.File, int64, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, 0, err
+	}
+
+	if !info.Mode().IsRegular() {
+		// Pipes and devices have no known length
+		return file, -1, nil
+	}
+
+	return file, info.Size(), nil
+}
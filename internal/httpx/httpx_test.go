@@ -3,8 +3,13 @@ package httpx_test
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -224,3 +229,219 @@ func TestRequestErrorsUnwrap(t *testing.T) {
 		t.Errorf("Unwrap() = %v, want %v", unwrappedErr, originalErr)
 	}
 }
+
+func TestExecuteWithCookieJar(t *testing.T) {
+	// Setup test server that sets a cookie on login and requires it afterwards
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc123", Path: "/"})
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		cookie, err := r.Cookie("sid")
+		if err != nil || cookie.Value != "abc123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("Failed to create cookie jar: %v", err)
+	}
+
+	_, err = httpx.Execute(&httpx.RequestSpec{URL: server.URL + "/login"}, httpx.WithCookieJar(jar))
+	if err != nil {
+		t.Fatalf("Login request failed: %v", err)
+	}
+
+	resp, err := httpx.Execute(&httpx.RequestSpec{URL: server.URL + "/me"}, httpx.WithCookieJar(jar))
+	if err != nil {
+		t.Fatalf("Authenticated request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected session cookie to be sent, got status %d", resp.StatusCode)
+	}
+
+	// Without a jar the cookie is not sent
+	resp, err = httpx.Execute(&httpx.RequestSpec{URL: server.URL + "/me"})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a cookie jar, got %d", resp.StatusCode)
+	}
+}
+
+func TestExecuteMultipart(t *testing.T) {
+	tempDir := t.TempDir()
+	reportPath := filepath.Join(tempDir, "report.pdf")
+	if err := os.WriteFile(reportPath, []byte("%PDF-1.4 test"), 0o600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	notesPath := filepath.Join(tempDir, "notes")
+	if err := os.WriteFile(notesPath, []byte("plain text notes"), 0o600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	// Setup test server that parses the multipart body
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("Failed to parse multipart form: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if got := r.FormValue("title"); got != "Q3 report" {
+			t.Errorf("Expected form field title to be 'Q3 report', got %q", got)
+		}
+
+		report := r.MultipartForm.File["document"]
+		if len(report) != 1 {
+			t.Errorf("Expected one document part, got %d", len(report))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if report[0].Filename != "report.pdf" {
+			t.Errorf("Expected filename report.pdf, got %s", report[0].Filename)
+		}
+		if ct := report[0].Header.Get("Content-Type"); ct != "application/pdf" {
+			t.Errorf("Expected application/pdf part, got %s", ct)
+		}
+
+		notes := r.MultipartForm.File["notes"]
+		if len(notes) != 1 {
+			t.Errorf("Expected one notes part, got %d", len(notes))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if ct := notes[0].Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("Expected sniffed text/plain part, got %s", ct)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	spec := &httpx.RequestSpec{
+		Method: http.MethodPost,
+		URL:    server.URL,
+		Headers: map[string]string{
+			// Models often emit this without a boundary; it must be replaced
+			"Content-Type": "multipart/form-data",
+		},
+		Form: map[string]string{"title": "Q3 report"},
+		Files: []httpx.FilePart{
+			{Field: "document", Path: reportPath},
+			{Field: "notes", Path: notesPath},
+		},
+	}
+
+	resp, err := httpx.Execute(spec)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status code 201, got %d", resp.StatusCode)
+	}
+
+	// Missing files are reported before anything is sent
+	spec.Files = []httpx.FilePart{{Field: "document", Path: filepath.Join(tempDir, "missing.pdf")}}
+	if _, err = httpx.Execute(spec); err == nil {
+		t.Error("Expected error for missing upload file, got nil")
+	}
+
+	// A raw body cannot be combined with multipart parts
+	spec.Body = "raw"
+	if err = spec.Validate(); !errors.Is(err, httpx.ErrInvalidRequest) {
+		t.Errorf("Expected ErrInvalidRequest for body with files, got %v", err)
+	}
+}
+
+func TestExecuteBodyFile(t *testing.T) {
+	bodyPath := filepath.Join(t.TempDir(), "payload.json")
+	if err := os.WriteFile(bodyPath, []byte(`{"order": 42}`), 0o600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	// Setup test server that echoes the request body
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != int64(len(`{"order": 42}`)) {
+			t.Errorf("Expected Content-Length to match the file size, got %d", r.ContentLength)
+		}
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+
+	spec := &httpx.RequestSpec{
+		Method:   http.MethodPost,
+		URL:      server.URL,
+		BodyFile: bodyPath,
+	}
+
+	resp, err := httpx.Execute(spec)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if string(resp.Body) != `{"order": 42}` {
+		t.Errorf("Expected file contents to be sent, got %s", resp.Body)
+	}
+
+	// body_file cannot be combined with an inline body
+	spec.Body = "inline"
+	if err = spec.Validate(); !errors.Is(err, httpx.ErrInvalidRequest) {
+		t.Errorf("Expected ErrInvalidRequest for body with body_file, got %v", err)
+	}
+}
+
+func TestCurl(t *testing.T) {
+	testCases := []struct {
+		name string
+		spec httpx.RequestSpec
+		want string
+	}{
+		{
+			name: "Simple GET",
+			spec: httpx.RequestSpec{Method: "GET", URL: "https://api.test/users?limit=10"},
+			want: `curl 'https://api.test/users?limit=10'`,
+		},
+		{
+			name: "POST with headers and body",
+			spec: httpx.RequestSpec{
+				Method:  "POST",
+				URL:     "https://api.test/users",
+				Headers: map[string]string{"Content-Type": "application/json", "Authorization": "Bearer t"},
+				Body:    `{"name": "O'Brien"}`,
+			},
+			want: `curl -X POST -H 'Authorization: Bearer t' -H 'Content-Type: application/json' ` +
+				`--data-raw '{"name": "O'\''Brien"}' https://api.test/users`,
+		},
+		{
+			name: "Body file",
+			spec: httpx.RequestSpec{Method: "PUT", URL: "https://api.test/doc", BodyFile: "./doc.json"},
+			want: `curl -X PUT --data-binary @./doc.json https://api.test/doc`,
+		},
+		{
+			name: "Multipart",
+			spec: httpx.RequestSpec{
+				Method: "POST",
+				URL:    "https://api.test/upload",
+				Form:   map[string]string{"title": "Q3 report"},
+				Files:  []httpx.FilePart{{Field: "file", Path: "./report.pdf", ContentType: "application/pdf"}},
+			},
+			want: `curl -X POST --form-string 'title=Q3 report' -F 'file=@./report.pdf;type=application/pdf' ` +
+				`https://api.test/upload`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.spec.Curl(); got != tc.want {
+				t.Errorf("Expected\n  %s\ngot\n  %s", tc.want, got)
+			}
+		})
+	}
+}
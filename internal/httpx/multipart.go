@@ -0,0 +1,116 @@
+package httpx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// quoteEscaper escapes characters that are special in Content-Disposition parameters
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// newMultipartBody streams the form fields and files of a spec as a
+// multipart/form-data body. Files are opened up front so that a missing
+// file is reported before the request is sent. It returns the body and
+// the Content-Type header value including the boundary.
+func newMultipartBody(spec *RequestSpec) (io.ReadCloser, string, error) {
+	files := make([]*os.File, 0, len(spec.Files))
+	closeAll := func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}
+
+	for _, part := range spec.Files {
+		f, err := os.Open(filepath.Clean(part.Path))
+		if err != nil {
+			closeAll()
+			return nil, "", fmt.Errorf("failed to open upload file: %w", err)
+		}
+		files = append(files, f)
+	}
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	go func() {
+		defer closeAll()
+		pw.CloseWithError(writeMultipart(writer, spec, files))
+	}()
+
+	return pr, writer.FormDataContentType(), nil
+}
+
+// writeMultipart writes all parts and the closing boundary
+func writeMultipart(writer *multipart.Writer, spec *RequestSpec, files []*os.File) error {
+	// Write fields in a stable order
+	keys := make([]string, 0, len(spec.Form))
+	for k := range spec.Form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := writer.WriteField(k, spec.Form[k]); err != nil {
+			return fmt.Errorf("failed to write form field %s: %w", k, err)
+		}
+	}
+
+	for i, part := range spec.Files {
+		if err := writeFilePart(writer, part, files[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish multipart body: %w", err)
+	}
+
+	return nil
+}
+
+// writeFilePart writes a single file part with its own Content-Type
+func writeFilePart(writer *multipart.Writer, part FilePart, file *os.File) error {
+	filename := part.Filename
+	if filename == "" {
+		filename = filepath.Base(part.Path)
+	}
+
+	reader := bufio.NewReaderSize(file, sniffLen)
+	contentType := part.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType == "" {
+		// Peek returns what it could read along with io.EOF for small files
+		head, _ := reader.Peek(sniffLen)
+		contentType = http.DetectContentType(head)
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(part.Field), quoteEscaper.Replace(filename)))
+	header.Set("Content-Type", contentType)
+
+	w, err := writer.CreatePart(header)
+	if err != nil {
+		return fmt.Errorf("failed to create part for %s: %w", part.Path, err)
+	}
+
+	if _, copyErr := io.Copy(w, reader); copyErr != nil {
+		return fmt.Errorf("failed to stream %s: %w", part.Path, copyErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/stephenbyrne99/ncurl/internal/assert"
+)
+
+// GenerateAssertions prompts the LLM to compile a natural language
+// expectation into concrete assertions. The schema describes the response
+// body, if it is JSON, so the model can pick the right paths.
+func (c *Client) GenerateAssertions(ctx context.Context, expectation, schema string) ([]*assert.Assertion, error) {
+	if expectation == "" {
+		return nil, &ModelError{
+			Err:     ErrInvalidRequest,
+			Message: "empty expectation",
+			Model:   c.Model,
+		}
+	}
+
+	systemPrompt := `
+You are a tool that turns expectations about an HTTP response into concrete checks.
+Output ONLY a JSON object with this shape (no markdown, no code blocks, just raw JSON):
+{
+  "assertions": ["<assertion>", ...]
+}
+
+Each assertion uses one of these forms:
+  status=200  status!=500  status<400  status=2xx
+  json:<query> == <JSON literal>   (also != < <= > >=, or ~ <regex>, or just json:<query> to test it is truthy)
+  header:<Name>~<regex>  header:<Name>=<value>  header:<Name>   (the last form checks the header is present)
+  time<500ms  time<=2s             (total response time)
+  body~<regex>  body!~<regex>
+
+<query> is a jq subset: .field.nested, .[0], .[], pipes (|), select(.a == "x"), length, keys, first, last.
+
+Rules:
+1. Write the fewest assertions that fully capture the expectation
+2. Use field names exactly as they appear in the schema
+3. "Healthy", "works" or "succeeds" without more detail means status=2xx
+4. "Fast" or "quick" without a number means time<1s
+`
+
+	prompt := fmt.Sprintf("Response schema:\n%s\n\nExpectation: %s", schema, expectation)
+
+	rawJSON, err := c.complete(ctx, PurposeAssert, systemPrompt, prompt, defaultMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	var compiled struct {
+		Assertions []string `json:"assertions"`
+	}
+	if unmarshalErr := json.Unmarshal([]byte(CleanJSONResponse(rawJSON)), &compiled); unmarshalErr != nil {
+		return nil, &ModelError{
+			Err:     fmt.Errorf("%w: %w", ErrInvalidJSON, unmarshalErr),
+			Message: "failed to parse model response as JSON",
+			Model:   c.Model,
+			Prompt:  expectation,
+			RawJSON: rawJSON,
+		}
+	}
+	if len(compiled.Assertions) == 0 {
+		return nil, &ModelError{
+			Err:     ErrEmptyResponse,
+			Message: "model generated no assertions",
+			Model:   c.Model,
+			Prompt:  expectation,
+			RawJSON: rawJSON,
+		}
+	}
+
+	assertions := make([]*assert.Assertion, 0, len(compiled.Assertions))
+	for _, text := range compiled.Assertions {
+		a, parseErr := assert.Parse(text)
+		if parseErr != nil {
+			return nil, &ModelError{
+				Err:     parseErr,
+				Message: "model generated an invalid assertion",
+				Model:   c.Model,
+				Prompt:  expectation,
+				RawJSON: rawJSON,
+			}
+		}
+		assertions = append(assertions, a)
+	}
+
+	return assertions, nil
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/stephenbyrne99/ncurl/internal/chain"
+)
+
+// chainMaxTokens leaves room for plans with several steps
+const chainMaxTokens = 4096
+
+// GeneratePlan prompts the LLM to plan a multi-step workflow as an ordered
+// list of requests, where later requests use values captured from earlier
+// responses
+func (c *Client) GeneratePlan(ctx context.Context, naturalLanguage string) (*chain.Plan, error) {
+	if naturalLanguage == "" {
+		return nil, &ModelError{
+			Err:     ErrInvalidRequest,
+			Message: "empty natural language prompt",
+			Model:   c.Model,
+		}
+	}
+
+	systemPrompt := `
+You are a planner that converts a natural-language description of an HTTP workflow into a sequence of
+requests. Output ONLY a JSON object with this shape (no markdown, no code blocks, just raw JSON):
+{
+  "steps": [
+    {
+      "name": "login",                       // short unique identifier
+      "depends_on": ["<step name>", ...],    // optional, steps that must run first
+      "request": {
+        "method":  "GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS",
+        "url":     "http://localhost:3000/path",
+        "headers": {"Header-Name": "value"}, // optional
+        "body":    "raw body as string"       // optional
+      },
+      "captures": [                          // optional, values later steps need
+        {"name": "token", "from": "json", "path": ".data.token"}
+      ]
+    }
+  ]
+}
+
+Rules:
+1. Use one step per HTTP request, in the order the user describes them
+2. To use a value from an earlier response, capture it and write {{name}} wherever it is needed in a later
+   request's url, headers or body. Never invent values that should come from a response
+3. "from" is "json" (path is a jq-style path such as .id, .data.token or .items[0].id), "header" (path is the
+   header name, e.g. Location) or "cookie" (path is the cookie name)
+4. A step that uses {{name}} must list the step that captures it, directly or indirectly, in "depends_on"
+5. Cookies set by earlier responses are sent automatically; only capture a cookie when its value is needed
+   somewhere other than the Cookie header
+6. When a domain or port is given, use it exactly; for localhost without a port use localhost:3000
+7. Include credentials exactly as given; use "Authorization": "Bearer {{token}}" for captured tokens
+8. Set a Content-Type header for requests with a body
+`
+
+	rawJSON, err := c.complete(ctx, PurposePlan, systemPrompt, naturalLanguage, chainMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan chain.Plan
+	if unmarshalErr := json.Unmarshal([]byte(CleanJSONResponse(rawJSON)), &plan); unmarshalErr != nil {
+		return nil, &ModelError{
+			Err:     fmt.Errorf("%w: %w", ErrInvalidJSON, unmarshalErr),
+			Message: "failed to parse model response as JSON",
+			Model:   c.Model,
+			Prompt:  naturalLanguage,
+			RawJSON: rawJSON,
+		}
+	}
+
+	if validateErr := plan.Validate(); validateErr != nil {
+		return nil, &ModelError{
+			Err:     validateErr,
+			Message: "model generated an invalid plan",
+			Model:   c.Model,
+			Prompt:  naturalLanguage,
+			RawJSON: rawJSON,
+		}
+	}
+
+	// Steps must also be valid requests once variables are filled in
+	for i := range plan.Steps {
+		if validateErr := plan.Steps[i].Request.Validate(); validateErr != nil {
+			return nil, &ModelError{
+				Err:     fmt.Errorf("step %q: %w", plan.Steps[i].Name, validateErr),
+				Message: "model generated an invalid plan",
+				Model:   c.Model,
+				Prompt:  naturalLanguage,
+				RawJSON: rawJSON,
+			}
+		}
+	}
+
+	return &plan, nil
+}
@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+// maxConversationTurns bounds how many earlier exchanges are resent with
+// each message, so a long session doesn't grow its token cost without limit
+const maxConversationTurns = 8
+
+// conversationRules extend the request prompt for multi-turn sessions
+const conversationRules = `
+Interactive session:
+25. Each message builds on the earlier ones. A follow-up such as "now do the same for user 7" or "add a limit of
+    10" changes the previous request and keeps everything it does not mention
+26. A message may list session variables. Write {{name}} exactly where a variable's value belongs instead of
+    asking for or inventing the value; it is filled in before the request is sent
+27. A message may describe the last response. Use it to resolve references such as "the first id" or "that user"
+28. A message may show the current request after the user edited it by hand; treat it as the previous request
+`
+
+// Conversation translates a series of related prompts, where each prompt
+// can refer to earlier ones. It is not safe for concurrent use.
+type Conversation struct {
+	client   *Client
+	messages []anthropic.MessageParam
+}
+
+// NewConversation starts a conversation with no earlier turns
+func (c *Client) NewConversation() *Conversation {
+	return &Conversation{client: c}
+}
+
+// Reset forgets all earlier turns
+func (conv *Conversation) Reset() {
+	conv.messages = nil
+}
+
+// Turns returns the number of exchanges the conversation remembers
+func (conv *Conversation) Turns() int {
+	return len(conv.messages) / 2
+}
+
+// GenerateRequestSpec translates a prompt in the context of the earlier
+// turns. A turn that fails is not remembered.
+func (conv *Conversation) GenerateRequestSpec(ctx context.Context, naturalLanguage string) (*httpx.RequestSpec, error) {
+	c := conv.client
+	if naturalLanguage == "" {
+		return nil, &ModelError{
+			Err:     ErrInvalidRequest,
+			Message: "empty natural language prompt",
+			Model:   c.Model,
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	messages := make([]anthropic.MessageParam, 0, len(conv.messages)+2)
+	messages = append(messages, conv.messages...)
+	messages = append(messages, anthropic.NewUserMessage(anthropic.NewTextBlock(naturalLanguage)))
+	systemPrompt := requestSystemPrompt + conversationRules
+	rawJSON, err := c.send(ctx, PurposeTranslate, systemPrompt, messages, naturalLanguage, defaultMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := c.parseRequestSpec(rawJSON, naturalLanguage)
+	if err != nil {
+		return nil, err
+	}
+
+	messages = append(messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(CleanJSONResponse(rawJSON))))
+	if excess := len(messages) - 2*maxConversationTurns; excess > 0 {
+		messages = messages[excess:]
+	}
+	conv.messages = messages
+
+	return spec, nil
+}
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/stephenbyrne99/ncurl/internal/query"
+)
+
+// Extraction is a query expression generated from a natural language
+// description of the data to keep
+type Extraction struct {
+	Expression string `json:"expression"`
+	Format     string `json:"format"`
+}
+
+// GenerateExtraction prompts the LLM to translate an extraction instruction
+// into a query expression. The schema describes the shape of the response
+// so the model never needs to see the data itself.
+func (c *Client) GenerateExtraction(ctx context.Context, instruction, schema string) (*Extraction, error) {
+	if instruction == "" {
+		return nil, &ModelError{
+			Err:     ErrInvalidRequest,
+			Message: "empty extraction instruction",
+			Model:   c.Model,
+		}
+	}
+
+	systemPrompt := `
+You are a tool that writes queries to extract data from JSON responses.
+Output ONLY a JSON object with this shape (no markdown, no code blocks, just raw JSON):
+{
+  "expression": "<query>",
+  "format": "json" | "table" | "lines"
+}
+
+The query language is a subset of jq:
+  .                               the whole document
+  .foo.bar  ."key with spaces"    object fields
+  .[0]  .items[-1]                array indexes
+  .[]  .items[]                   iterate over array elements
+  a | b                           pipe results of a into b
+  a, b                            results of a followed by results of b
+  {title, author: .user.login}    build an object ({title} is short for {title: .title})
+  [ .items[].id ]                 collect results into an array
+  select(.state == "open" and .comments > 2)   keep values matching a condition
+                                  (operators: == != < <= > >=, combined with and / or)
+  length  keys  first  last       built-in functions
+
+Rules:
+1. Only use the syntax listed above; no other jq functions are available
+2. Use field names exactly as they appear in the schema
+3. Use "table" when the user asks for a table or for several fields per item; build one object per row
+4. Use "lines" when each result is a single value, such as a list of names or IDs
+5. Use "json" otherwise
+`
+
+	prompt := fmt.Sprintf("Response schema:\n%s\n\nExtract: %s", schema, instruction)
+
+	rawJSON, err := c.complete(ctx, PurposeExtract, systemPrompt, prompt, defaultMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	var extraction Extraction
+	if unmarshalErr := json.Unmarshal([]byte(CleanJSONResponse(rawJSON)), &extraction); unmarshalErr != nil {
+		return nil, &ModelError{
+			Err:     fmt.Errorf("%w: %w", ErrInvalidJSON, unmarshalErr),
+			Message: "failed to parse model response as JSON",
+			Model:   c.Model,
+			Prompt:  instruction,
+			RawJSON: rawJSON,
+		}
+	}
+
+	if _, parseErr := query.Parse(extraction.Expression); parseErr != nil {
+		return nil, &ModelError{
+			Err:     parseErr,
+			Message: "model generated invalid query",
+			Model:   c.Model,
+			Prompt:  instruction,
+			RawJSON: rawJSON,
+		}
+	}
+
+	switch extraction.Format {
+	case query.FormatJSON, query.FormatTable, query.FormatLines:
+	default:
+		extraction.Format = query.FormatJSON
+	}
+
+	return &extraction, nil
+}
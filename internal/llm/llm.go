@@ -3,16 +3,36 @@ package llm
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/usage"
 )
 
+// Purposes of model calls, as recorded in usage
+const (
+	PurposeTranslate = "translate"
+	PurposeExtract   = "extract"
+	PurposeAssert    = "assert"
+	PurposePlan      = "plan"
+	PurposeJudge     = "judge"
+)
+
+// BaseURLEnv names the environment variable that points model clients at
+// another Messages API server, such as ncurl-mockllm
+const BaseURLEnv = "ANTHROPIC_BASE_URL"
+
 // Common errors that can be returned by this package
 var (
 	ErrEmptyResponse  = errors.New("empty response from model")
@@ -74,10 +94,94 @@ func CleanJSONResponse(input string) string {
 	return strings.TrimSpace(input)
 }
 
+// requestSystemPrompt instructs the model to translate a description into a
+// RequestSpec
+const requestSystemPrompt = `
+You are a translator that converts natural‑language descriptions of HTTP
+requests into a JSON object with the following shape:
+{
+  "method":   "GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS",
+  "url":      "https://example.com/path",
+  "headers":  {"Header-Name": "value", ...}, // optional
+  "body":     "raw body as string",           // optional
+  "body_file": "@payload.json",                // optional, attached payload sent as the body
+  "form":     {"field": "value", ...},         // optional, multipart form fields
+  "files":    [{"field": "file", "path": "./local/file.pdf"}] // optional, multipart file uploads
+}
+Return **only** valid JSON with those exact keys (lower‑case) and no
+explanation or additional text.
+
+Guidelines:
+1. Never use example.com for the url, always point to a real API
+2. For cryptocurrency requests, use appropriate public APIs (e.g., coindesk, binance, etc.)
+3. For weather data, use appropriate weather APIs (e.g., openweathermap, weatherapi, etc.)
+4. When authentication credentials are provided, include them as appropriate headers or URL parameters
+5. When specific IDs or query parameters are mentioned, include them in the URL or query string
+6. When the user provides explicit JSON in the prompt, use it exactly as provided
+7. Convert ambiguous natural language into the most likely intended HTTP request
+
+Authentication and Headers:
+8. For JWT tokens, use the entire token in the Authorization header (Bearer [token])
+9. For API keys, follow the exact format mentioned in the input (key=xyz, appid=xyz, etc.)
+10. For Basic auth, include basic auth in the Authorization header (Basic [base64])
+11. For If-Modified-Since dates, format correctly as HTTP date (e.g., Sun, 01 Jan 2023 00:00:00 GMT)
+
+URL and Endpoint Guidelines:
+12. When a domain is explicitly provided (like api.example.com), always use it exactly as given
+13. When API versioning is mentioned (like "v2"), include it in the path (/v2/endpoint)
+14. For profile requests, use appropriate endpoint (/profile or /user/profile)
+15. For uploads of local files, put each file in "files" with its form field name and the local path exactly as
+    given, and other form values in "form". Never inline file contents in "body" and do not set a Content-Type
+    header for uploads; the multipart/form-data boundary is added automatically
+
+Local development guidelines:
+16. For localhost requests without a port, use port 3000 by default (localhost:3000)
+17. Always use the specific port if mentioned (e.g., localhost:8080 or localhost:5000)
+18. For Next.js API routes, use localhost:3000/api/[route] unless another port is specified
+19. For regular API endpoints on localhost, do NOT add /api unless specifically mentioned
+20. For GraphQL queries to localhost, use POST to localhost:[port]/graphql with appropriate Content-Type
+21. Include authentication tokens when mentioned for localhost requests
+22. For other local frameworks (Express, Flask, Rails, etc.), use appropriate port conventions
+
+Attached payloads:
+23. When the input lists attached payloads, never copy their content into "body". Set "body_file" to the
+    payload reference exactly as listed (e.g. "@stdin" or "@payload.json"), or use the reference as the "path"
+    of a file part for uploads, and set a Content-Type header matching the payload
+24. Use the payload summary or schema only to choose the right endpoint, method and headers
+
+Your goal is to accurately translate what the user wants into a proper HTTP request, including correctly handling local development scenarios.
+`
+
+// Provider names the service that runs the models
+const Provider = "anthropic"
+
+// PromptVersion identifies the request system prompt, so translations
+// cached with an older prompt are not reused
+func PromptVersion() string {
+	sum := sha256.Sum256([]byte(requestSystemPrompt))
+	return hex.EncodeToString(sum[:6])
+}
+
 // Client provides methods for translating natural language to HTTP requests
 type Client struct {
 	anthropicClient *anthropic.Client
 	Model           string // Exported for testing
+
+	recorder Recorder
+	replayer Replayer
+
+	mu    sync.Mutex
+	calls []usage.Record
+}
+
+// Recorder saves the raw output of each model call
+type Recorder interface {
+	Record(model, systemPrompt, prompt, output string)
+}
+
+// Replayer answers model calls from earlier recordings instead of the model
+type Replayer interface {
+	Replay(model, systemPrompt, prompt string) (string, error)
 }
 
 // ClientOption is a functional option for configuring the Client
@@ -90,6 +194,40 @@ func WithAnthropicClient(client *anthropic.Client) ClientOption {
 	}
 }
 
+// WithRecorder passes every successful model call to r
+func WithRecorder(r Recorder) ClientOption {
+	return func(c *Client) {
+		c.recorder = r
+	}
+}
+
+// WithReplayer answers model calls from r, never contacting the model. A
+// call r can't answer fails with ErrModelFailure.
+func WithReplayer(r Replayer) ClientOption {
+	return func(c *Client) {
+		c.replayer = r
+	}
+}
+
+// WithBaseURL sends requests to another Messages API server, overriding
+// $ANTHROPIC_BASE_URL
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		client := anthropic.NewClient(option.WithBaseURL(baseURL))
+		c.anthropicClient = &client
+	}
+}
+
+// NewAnthropicClient creates an Anthropic client configured from the
+// environment: the API key from $ANTHROPIC_API_KEY and, when set, the
+// server from $ANTHROPIC_BASE_URL
+func NewAnthropicClient(opts ...option.RequestOption) anthropic.Client {
+	if baseURL := os.Getenv(BaseURLEnv); baseURL != "" {
+		opts = append([]option.RequestOption{option.WithBaseURL(baseURL)}, opts...)
+	}
+	return anthropic.NewClient(opts...)
+}
+
 // NewClient creates a new LLM client with the specified model
 func NewClient(model string, opts ...ClientOption) *Client {
 	if model == "" {
@@ -97,7 +235,7 @@ func NewClient(model string, opts ...ClientOption) *Client {
 	}
 
 	// Create default client
-	client := anthropic.NewClient() // reads $ANTHROPIC_API_KEY
+	client := NewAnthropicClient()
 	c := &Client{
 		anthropicClient: &client,
 		Model:           model,
@@ -127,114 +265,145 @@ func (c *Client) GenerateRequestSpec(ctx context.Context, naturalLanguage string
 		return nil, ctx.Err()
 	}
 
-	systemPrompt := `
-You are a translator that converts natural‑language descriptions of HTTP
-requests into a JSON object with the following shape:
-{
-  "method":   "GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS",
-  "url":      "https://example.com/path",
-  "headers":  {"Header-Name": "value", ...}, // optional
-  "body":     "raw body as string"            // optional
+	rawJSON, err := c.complete(ctx, PurposeTranslate, requestSystemPrompt, naturalLanguage, defaultMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseRequestSpec(rawJSON, naturalLanguage)
 }
-Return **only** valid JSON with those exact keys (lower‑case) and no
-explanation or additional text.
 
-Guidelines:
-1. Never use example.com for the url, always point to a real API
-2. For cryptocurrency requests, use appropriate public APIs (e.g., coindesk, binance, etc.)
-3. For weather data, use appropriate weather APIs (e.g., openweathermap, weatherapi, etc.)
-4. When authentication credentials are provided, include them as appropriate headers or URL parameters
-5. When specific IDs or query parameters are mentioned, include them in the URL or query string
-6. When the user provides explicit JSON in the prompt, use it exactly as provided
-7. Convert ambiguous natural language into the most likely intended HTTP request
+// parseRequestSpec decodes and validates a request spec from a model response
+func (c *Client) parseRequestSpec(rawJSON, naturalLanguage string) (*httpx.RequestSpec, error) {
+	// Clean up the response - sometimes Claude returns markdown-formatted JSON
+	cleanJSON := CleanJSONResponse(rawJSON)
 
-Authentication and Headers:
-8. For JWT tokens, use the entire token in the Authorization header (Bearer [token])
-9. For API keys, follow the exact format mentioned in the input (key=xyz, appid=xyz, etc.)
-10. For Basic auth, include basic auth in the Authorization header (Basic [base64])
-11. For If-Modified-Since dates, format correctly as HTTP date (e.g., Sun, 01 Jan 2023 00:00:00 GMT)
+	var spec httpx.RequestSpec
+	if unmarshalErr := json.Unmarshal([]byte(cleanJSON), &spec); unmarshalErr != nil {
+		return nil, &ModelError{
+			Err:     fmt.Errorf("%w: %w", ErrInvalidJSON, unmarshalErr),
+			Message: "failed to parse model response as JSON",
+			Model:   c.Model,
+			Prompt:  naturalLanguage,
+			RawJSON: rawJSON,
+		}
+	}
 
-URL and Endpoint Guidelines:
-12. When a domain is explicitly provided (like api.example.com), always use it exactly as given
-13. When API versioning is mentioned (like "v2"), include it in the path (/v2/endpoint)
-14. For profile requests, use appropriate endpoint (/profile or /user/profile)
-15. For uploads, use appropriate content type (multipart/form-data) and boundary
+	// Validate the RequestSpec
+	if validateErr := spec.Validate(); validateErr != nil {
+		return nil, &ModelError{
+			Err:     validateErr,
+			Message: "model generated invalid request specification",
+			Model:   c.Model,
+			Prompt:  naturalLanguage,
+			RawJSON: rawJSON,
+		}
+	}
 
-Local development guidelines:
-16. For localhost requests without a port, use port 3000 by default (localhost:3000)
-17. Always use the specific port if mentioned (e.g., localhost:8080 or localhost:5000)
-18. For Next.js API routes, use localhost:3000/api/[route] unless another port is specified
-19. For regular API endpoints on localhost, do NOT add /api unless specifically mentioned
-20. For GraphQL queries to localhost, use POST to localhost:[port]/graphql with appropriate Content-Type
-21. Include authentication tokens when mentioned for localhost requests
-22. For other local frameworks (Express, Flask, Rails, etc.), use appropriate port conventions
+	return &spec, nil
+}
 
-Your goal is to accurately translate what the user wants into a proper HTTP request, including correctly handling local development scenarios.
-`
+// defaultMaxTokens is a standard token limit for single requests
+const defaultMaxTokens = 1024
+
+// complete sends a single-turn message and returns the text of the first
+// content block
+func (c *Client) complete(ctx context.Context, purpose, systemPrompt, prompt string, maxTokens int64) (string, error) {
+	messages := []anthropic.MessageParam{
+		anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
+	}
+	return c.send(ctx, purpose, systemPrompt, messages, prompt, maxTokens)
+}
+
+// Complete sends a single-turn message with the caller's own prompts, such
+// as an evaluation prompt, and returns the text of the reply. Like the
+// client's other calls, it is recorded, replayed and counted in Usage.
+func (c *Client) Complete(ctx context.Context, purpose, systemPrompt, prompt string) (string, error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	return c.complete(ctx, purpose, systemPrompt, prompt, defaultMaxTokens)
+}
+
+// Usage returns the tokens used by each model call the client has made
+func (c *Client) Usage() []usage.Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]usage.Record(nil), c.calls...)
+}
+
+// recordUsage notes the tokens used by a model call
+func (c *Client) recordUsage(purpose string, u anthropic.Usage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, usage.Record{
+		Time:    time.Now(),
+		Purpose: purpose,
+		Tokens:  usage.Tokens{Model: c.Model, Input: u.InputTokens, Output: u.OutputTokens},
+	})
+}
+
+// send sends a conversation and returns the text of the first content block
+// of the reply. prompt is the latest user message, used in errors.
+func (c *Client) send(
+	ctx context.Context,
+	purpose string,
+	systemPrompt string,
+	messages []anthropic.MessageParam,
+	prompt string,
+	maxTokens int64,
+) (string, error) {
+	if c.replayer != nil {
+		output, err := c.replayer.Replay(c.Model, systemPrompt, prompt)
+		if err != nil {
+			return "", &ModelError{
+				Err:     fmt.Errorf("%w: %w", ErrModelFailure, err),
+				Message: "failed to replay model request",
+				Model:   c.Model,
+				Prompt:  prompt,
+			}
+		}
+		return output, nil
+	}
 
 	msg, err := c.anthropicClient.Messages.New(ctx, anthropic.MessageNewParams{
 		Model:     c.Model,
-		MaxTokens: 1024, // A standard token limit for this type of request
+		MaxTokens: maxTokens,
 		System: []anthropic.TextBlockParam{
 			{Text: systemPrompt},
 		},
-		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(naturalLanguage)),
-		},
+		Messages: messages,
 	})
 
 	// Handle context cancellation
 	if ctx.Err() != nil {
-		return nil, ctx.Err()
+		return "", ctx.Err()
 	}
 
 	// Handle API errors
 	if err != nil {
-		return nil, &ModelError{
+		return "", &ModelError{
 			Err:     fmt.Errorf("%w: %w", ErrModelFailure, err),
 			Message: "failed to execute model request",
 			Model:   c.Model,
-			Prompt:  naturalLanguage,
+			Prompt:  prompt,
 		}
 	}
+	c.recordUsage(purpose, msg.Usage)
 
 	// Check for empty responses
 	if len(msg.Content) == 0 {
-		return nil, &ModelError{
+		return "", &ModelError{
 			Err:     ErrEmptyResponse,
 			Message: "model returned empty content",
 			Model:   c.Model,
-			Prompt:  naturalLanguage,
+			Prompt:  prompt,
 		}
 	}
 
 	// Claude streams content blocks; we expect the first to be the JSON text.
-	rawJSON := msg.Content[0].Text
-
-	// Clean up the response - sometimes Claude returns markdown-formatted JSON
-	cleanJSON := CleanJSONResponse(rawJSON)
-
-	var spec httpx.RequestSpec
-	if unmarshalErr := json.Unmarshal([]byte(cleanJSON), &spec); unmarshalErr != nil {
-		return nil, &ModelError{
-			Err:     fmt.Errorf("%w: %w", ErrInvalidJSON, unmarshalErr),
-			Message: "failed to parse model response as JSON",
-			Model:   c.Model,
-			Prompt:  naturalLanguage,
-			RawJSON: rawJSON,
-		}
-	}
-
-	// Validate the RequestSpec
-	if validateErr := spec.Validate(); validateErr != nil {
-		return nil, &ModelError{
-			Err:     validateErr,
-			Message: "model generated invalid request specification",
-			Model:   c.Model,
-			Prompt:  naturalLanguage,
-			RawJSON: rawJSON,
-		}
+	if c.recorder != nil {
+		c.recorder.Record(c.Model, systemPrompt, prompt, msg.Content[0].Text)
 	}
-
-	return &spec, nil
+	return msg.Content[0].Text, nil
 }
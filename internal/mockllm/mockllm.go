@@ -0,0 +1,278 @@
+// Package mockllm is a stand-in for the Anthropic Messages API that replies
+// with scripted responses, so ncurl and ncurl-eval can be tested offline
+package mockllm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrInvalidFixtures = errors.New("invalid fixtures")
+)
+
+// messagesPath is the Messages API endpoint, relative to the base URL
+const messagesPath = "/v1/messages"
+
+// charsPerToken is a rough ratio used to report token usage
+const charsPerToken = 4
+
+// Fixture is a scripted reply. A request uses the first fixture whose
+// conditions all match; a fixture without conditions matches anything,
+// which makes it a useful last entry.
+type Fixture struct {
+	Name     string `json:"name,omitempty"`
+	Match    string `json:"match,omitempty"`  // Text the prompt must contain, ignoring case
+	Regex    string `json:"regex,omitempty"`  // Regular expression the prompt must match
+	System   string `json:"system,omitempty"` // Text the system prompt must contain, ignoring case
+	Model    string `json:"model,omitempty"`  // Model the request must ask for
+	Response string `json:"response"`         // Text of the reply, or the error message with Status
+	Status   int    `json:"status,omitempty"` // Reply with this HTTP error status instead of a message
+	Times    int    `json:"times,omitempty"`  // Match at most this many times; 0 means no limit
+
+	regex *regexp.Regexp
+}
+
+// Request is a request the server received
+type Request struct {
+	Model   string
+	System  string
+	Prompt  string // Text of the last user message
+	Fixture string // Name of the fixture that answered, empty on a miss
+}
+
+// Server serves the Messages API from fixtures
+type Server struct {
+	fixtures []*Fixture
+	logger   *log.Logger
+
+	mu       sync.Mutex
+	used     []int
+	requests []Request
+}
+
+// Option is a functional option for configuring the Server
+type Option func(*Server)
+
+// WithLogger logs every request and the fixture that answered it
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// LoadFixtures reads fixtures from a JSON array
+func LoadFixtures(path string) ([]Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	var fixtures []Fixture
+	if unmarshalErr := json.Unmarshal(data, &fixtures); unmarshalErr != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidFixtures, path, unmarshalErr)
+	}
+	return fixtures, nil
+}
+
+// New creates a server that answers from fixtures, in order
+func New(fixtures []Fixture, opts ...Option) (*Server, error) {
+	s := &Server{used: make([]int, len(fixtures))}
+	for i := range fixtures {
+		f := fixtures[i]
+		if f.Regex != "" {
+			re, err := regexp.Compile(f.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("%w: fixture %d: %w", ErrInvalidFixtures, i+1, err)
+			}
+			f.regex = re
+		}
+		if f.Status != 0 && (f.Status < 400 || f.Status > 599) {
+			return nil, fmt.Errorf("%w: fixture %d: status %d is not an error status", ErrInvalidFixtures, i+1, f.Status)
+		}
+		if f.Times < 0 {
+			return nil, fmt.Errorf("%w: fixture %d: times must not be negative", ErrInvalidFixtures, i+1)
+		}
+		s.fixtures = append(s.fixtures, &f)
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// messagesRequest is the part of a Messages API request the server reads
+type messagesRequest struct {
+	Model    string          `json:"model"`
+	System   json.RawMessage `json:"system"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+}
+
+// ServeHTTP answers POST /v1/messages
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, messagesPath) {
+		writeError(w, http.StatusNotFound, "mockllm only serves POST "+messagesPath)
+		return
+	}
+
+	var body messagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	req := Request{Model: body.Model, System: text(body.System)}
+	for _, m := range body.Messages {
+		if m.Role == "user" {
+			req.Prompt = text(m.Content)
+		}
+	}
+
+	fixture := s.match(&req)
+	if s.logger != nil {
+		name := req.Fixture
+		if fixture == nil {
+			name = "no match"
+		}
+		s.logger.Printf("%s %q -> %s\n", req.Model, shorten(req.Prompt), name)
+	}
+
+	switch {
+	case fixture == nil:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("mockllm: no fixture matches prompt %q", req.Prompt))
+	case fixture.Status != 0:
+		writeError(w, fixture.Status, fixture.Response)
+	default:
+		writeMessage(w, &req, fixture.Response)
+	}
+}
+
+// match finds the fixture for a request and records the request
+func (s *Server) match(req *Request) *Fixture {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found *Fixture
+	for i, f := range s.fixtures {
+		if f.matches(req) && (f.Times == 0 || s.used[i] < f.Times) {
+			s.used[i]++
+			found = f
+			req.Fixture = f.Name
+			if req.Fixture == "" {
+				req.Fixture = fmt.Sprintf("fixture %d", i+1)
+			}
+			break
+		}
+	}
+	s.requests = append(s.requests, *req)
+	return found
+}
+
+// matches reports whether all of a fixture's conditions hold for a request
+func (f *Fixture) matches(req *Request) bool {
+	return containsFold(req.Prompt, f.Match) &&
+		containsFold(req.System, f.System) &&
+		(f.regex == nil || f.regex.MatchString(req.Prompt)) &&
+		(f.Model == "" || f.Model == req.Model)
+}
+
+// containsFold reports whether s contains substr, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// text returns the text of a system prompt or message content, which is
+// either a string or a list of content blocks
+func text(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &blocks) != nil {
+		return ""
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// writeMessage replies with an assistant message
+func writeMessage(w http.ResponseWriter, req *Request, reply string) {
+	message := map[string]interface{}{
+		"id":            "msg_mock",
+		"type":          "message",
+		"role":          "assistant",
+		"model":         req.Model,
+		"content":       []map[string]string{{"type": "text", "text": reply}},
+		"stop_reason":   "end_turn",
+		"stop_sequence": nil,
+		"usage": map[string]int{
+			"input_tokens":  (len(req.System) + len(req.Prompt) + charsPerToken - 1) / charsPerToken,
+			"output_tokens": (len(reply) + charsPerToken - 1) / charsPerToken,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(message)
+}
+
+// errorTypes maps HTTP statuses to Anthropic error types
+var errorTypes = map[int]string{
+	http.StatusBadRequest:      "invalid_request_error",
+	http.StatusUnauthorized:    "authentication_error",
+	http.StatusForbidden:       "permission_error",
+	http.StatusNotFound:        "not_found_error",
+	http.StatusTooManyRequests: "rate_limit_error",
+	529:                        "overloaded_error",
+}
+
+// writeError replies with an Anthropic error
+func writeError(w http.ResponseWriter, status int, message string) {
+	errorType, ok := errorTypes[status]
+	if !ok {
+		errorType = "api_error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":  "error",
+		"error": map[string]string{"type": errorType, "message": message},
+	})
+}
+
+// shorten trims long prompts for logging
+func shorten(s string) string {
+	const maxLength = 60
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxLength {
+		return s[:maxLength-3] + "..."
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+package mockllm_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stephenbyrne99/ncurl/internal/llm"
+	"github.com/stephenbyrne99/ncurl/internal/mockllm"
+)
+
+// startServer serves fixtures and returns a client pointed at them
+func startServer(t *testing.T, fixtures []mockllm.Fixture) (*mockllm.Server, *llm.Client) {
+	t.Helper()
+	server, err := mockllm.New(fixtures)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return server, llm.NewClient("claude-3-5-haiku-latest", llm.WithBaseURL(ts.URL))
+}
+
+func TestGenerateRequestSpec(t *testing.T) {
+	server, client := startServer(t, []mockllm.Fixture{
+		{
+			Name:     "users",
+			Match:    "LIST USERS",
+			Response: "```json\n" + `{"method": "GET", "url": "http://localhost:8080/users"}` + "\n```",
+		},
+		{
+			Name:     "health",
+			Regex:    `^check \w+ health$`,
+			System:   "HTTP request",
+			Response: `{"url": "http://localhost:8080/health"}`,
+		},
+	})
+
+	spec, err := client.GenerateRequestSpec(context.Background(), "list users on localhost:8080")
+	if err != nil {
+		t.Fatalf("GenerateRequestSpec failed: %v", err)
+	}
+	if spec.Method != "GET" || spec.URL != "http://localhost:8080/users" {
+		t.Errorf("Unexpected request: %+v", spec)
+	}
+
+	if _, err = client.GenerateRequestSpec(context.Background(), "check billing health"); err != nil {
+		t.Fatalf("GenerateRequestSpec failed: %v", err)
+	}
+
+	_, err = client.GenerateRequestSpec(context.Background(), "delete everything")
+	if !errors.Is(err, llm.ErrModelFailure) {
+		t.Errorf("Expected ErrModelFailure for a prompt without a fixture, got %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 3 ||
+		requests[0].Fixture != "users" || requests[1].Fixture != "health" || requests[2].Fixture != "" {
+		t.Errorf("Unexpected requests: %+v", requests)
+	}
+	if requests[0].Model != "claude-3-5-haiku-latest" || requests[0].Prompt != "list users on localhost:8080" {
+		t.Errorf("Unexpected request: %+v", requests[0])
+	}
+
+	calls := client.Usage()
+	if len(calls) != 2 || calls[0].Input == 0 || calls[0].Output == 0 {
+		t.Errorf("Expected token usage for both answered calls, got %+v", calls)
+	}
+}
+
+func TestErrorFixtures(t *testing.T) {
+	_, client := startServer(t, []mockllm.Fixture{
+		{Status: 401, Response: "invalid x-api-key", Times: 1},
+		{Response: `{"url": "https://example.com"}`},
+	})
+
+	ctx := context.Background()
+	if _, err := client.GenerateRequestSpec(ctx, "get example.com"); !errors.Is(err, llm.ErrModelFailure) {
+		t.Errorf("Expected ErrModelFailure from the error fixture, got %v", err)
+	}
+	if _, err := client.GenerateRequestSpec(ctx, "get example.com"); err != nil {
+		t.Errorf("Expected the error fixture to be used up, got %v", err)
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fixtures.json")
+	if err := os.WriteFile(path, []byte(`[{"match": "users", "response": "{}"}]`), 0o600); err != nil {
+		t.Fatalf("Failed to write fixtures: %v", err)
+	}
+	fixtures, err := mockllm.LoadFixtures(path)
+	if err != nil || len(fixtures) != 1 || fixtures[0].Match != "users" {
+		t.Errorf("Unexpected fixtures: %+v, %v", fixtures, err)
+	}
+
+	if writeErr := os.WriteFile(path, []byte(`{"match": "users"}`), 0o600); writeErr != nil {
+		t.Fatalf("Failed to write fixtures: %v", writeErr)
+	}
+	if _, err = mockllm.LoadFixtures(path); !errors.Is(err, mockllm.ErrInvalidFixtures) {
+		t.Errorf("Expected ErrInvalidFixtures, got %v", err)
+	}
+
+	invalid := [][]mockllm.Fixture{
+		{{Regex: "("}},
+		{{Status: 200}},
+		{{Times: -1}},
+	}
+	for _, fixtures := range invalid {
+		if _, newErr := mockllm.New(fixtures); !errors.Is(newErr, mockllm.ErrInvalidFixtures) {
+			t.Errorf("Expected ErrInvalidFixtures for %+v, got %v", fixtures, newErr)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+// Package payload handles request bodies supplied from files and stdin.
+// The model only sees a short summary of each payload while the bytes
+// themselves are streamed by httpx.
+package payload
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+// StdinRef is the reference used for a payload read from standard input
+const StdinRef = "@stdin"
+
+// Limits that keep payload summaries small enough for a prompt
+const (
+	inlineLimit  = 1024     // Payloads up to this size are shown verbatim
+	parseLimit   = 10 << 20 // Larger JSON payloads are not parsed for a schema
+	summaryLimit = 2000     // Maximum length of a schema or preview
+	previewLines = 5        // Lines shown for large text payloads
+	schemaDepth  = 6        // Maximum nesting shown in a JSON schema
+	sniffLen     = 512      // Bytes used to detect the content type
+	jsonType     = "application/json"
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrEmptyPayload     = errors.New("empty payload")
+	ErrUnknownReference = errors.New("unknown payload reference")
+)
+
+// Payload is a request body, or part of one, backed by a local file
+type Payload struct {
+	Ref         string // How the prompt and spec refer to it, e.g. @payload.json
+	Path        string // Local file holding the bytes
+	Size        int64
+	ContentType string
+	Summary     string // What the model sees instead of the content
+	temp        bool   // Path is a temporary file owned by the payload
+}
+
+// FromFile creates a payload for a local file
+func FromFile(ref, path string) (*Payload, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload %s: %w", ref, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("payload %s is not a regular file", ref)
+	}
+
+	p := &Payload{Ref: ref, Path: path, Size: info.Size()}
+	if summarizeErr := p.summarize(); summarizeErr != nil {
+		return nil, summarizeErr
+	}
+
+	return p, nil
+}
+
+// FromReader copies r to a temporary file so it can be summarized and
+// later streamed. It returns ErrEmptyPayload if r has no data.
+func FromReader(ref string, r io.Reader) (*Payload, error) {
+	tmp, err := os.CreateTemp("", "ncurl-payload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer payload %s: %w", ref, err)
+	}
+
+	size, copyErr := io.Copy(tmp, r)
+	closeErr := tmp.Close()
+	if copyErr != nil || closeErr != nil || size == 0 {
+		_ = os.Remove(tmp.Name())
+		if copyErr != nil {
+			return nil, fmt.Errorf("failed to buffer payload %s: %w", ref, copyErr)
+		}
+		if closeErr != nil {
+			return nil, fmt.Errorf("failed to buffer payload %s: %w", ref, closeErr)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrEmptyPayload, ref)
+	}
+
+	p := &Payload{Ref: ref, Path: tmp.Name(), Size: size, temp: true}
+	if summarizeErr := p.summarize(); summarizeErr != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, summarizeErr
+	}
+
+	return p, nil
+}
+
+// Close removes any temporary file backing the payload
+func (p *Payload) Close() error {
+	if !p.temp {
+		return nil
+	}
+	return os.Remove(p.Path)
+}
+
+// Describe returns the text the model sees for this payload
+func (p *Payload) Describe() string {
+	return fmt.Sprintf("%s (%s, %d bytes)\n%s", p.Ref, p.ContentType, p.Size, p.Summary)
+}
+
+// summarize detects the content type and builds the model-facing summary
+func (p *Payload) summarize() error {
+	file, err := os.Open(filepath.Clean(p.Path))
+	if err != nil {
+		return fmt.Errorf("failed to read payload %s: %w", p.Ref, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+	head, _ := reader.Peek(sniffLen)
+	p.ContentType = detectContentType(p.Ref, head)
+
+	// Small text payloads are cheap enough to show as-is
+	if p.Size <= inlineLimit && utf8.Valid(head) {
+		content, readErr := io.ReadAll(reader)
+		if readErr != nil {
+			return fmt.Errorf("failed to read payload %s: %w", p.Ref, readErr)
+		}
+		p.Summary = "Content:\n" + string(content)
+		return nil
+	}
+
+	switch {
+	case p.ContentType == jsonType && p.Size <= parseLimit:
+		var v interface{}
+		if decodeErr := json.NewDecoder(reader).Decode(&v); decodeErr != nil {
+			return fmt.Errorf("failed to parse JSON payload %s: %w", p.Ref, decodeErr)
+		}
+		p.Summary = "Schema: " + truncate(Schema(v))
+
+	case strings.HasPrefix(p.ContentType, "text/") || p.ContentType == jsonType || utf8.Valid(head):
+		p.Summary = "Preview:\n" + preview(reader)
+
+	default:
+		p.Summary = "Binary content (not shown)"
+	}
+
+	return nil
+}
+
+// detectContentType guesses a content type from the reference and first bytes
+func detectContentType(ref string, head []byte) string {
+	if ct := mime.TypeByExtension(filepath.Ext(ref)); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err == nil {
+			return mediaType
+		}
+		return ct
+	}
+
+	trimmed := strings.TrimSpace(string(head))
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return jsonType
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// preview returns the first few lines of a text payload
+func preview(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, summaryLimit), summaryLimit)
+
+	var lines []string
+	for len(lines) < previewLines && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return truncate(strings.Join(lines, "\n")) + "\n..."
+}
+
+// truncate shortens a summary to summaryLimit bytes
+func truncate(s string) string {
+	if len(s) <= summaryLimit {
+		return s
+	}
+	return s[:summaryLimit] + "..."
+}
+
+// Schema describes the shape of a decoded JSON value without its data
+func Schema(v interface{}) string {
+	var sb strings.Builder
+	writeSchema(&sb, v, 0)
+	return sb.String()
+}
+
+// writeSchema writes a compact type outline of a JSON value
+func writeSchema(sb *strings.Builder, v interface{}, depth int) {
+	if depth >= schemaDepth {
+		sb.WriteString("...")
+		return
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		sb.WriteString("{")
+		for i, k := range keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(sb, "%q: ", k)
+			writeSchema(sb, val[k], depth+1)
+		}
+		sb.WriteString("}")
+
+	case []interface{}:
+		if len(val) == 0 {
+			sb.WriteString("[]")
+			return
+		}
+		sb.WriteString("[")
+		writeSchema(sb, val[0], depth+1)
+		fmt.Fprintf(sb, "] (%d items)", len(val))
+
+	case string:
+		sb.WriteString("string")
+	case float64:
+		sb.WriteString("number")
+	case bool:
+		sb.WriteString("boolean")
+	default:
+		sb.WriteString("null")
+	}
+}
+
+// stdinWords are the words that make a prompt refer to piped stdin. "this"
+// only counts as what's sent, not in prompts like "get this user's repos".
+var stdinWords = []string{
+	"@-", StdinRef, "stdin", "piped", "standard input",
+	"post this", "put this", "patch this", "send this", "upload this",
+}
+
+// ReferencesStdin reports whether a prompt refers to a body on stdin, as in
+// "post this to ..." or "send @- to ...". Stdin is only read when it does, so
+// ncurl doesn't consume the input of a script it runs in.
+func ReferencesStdin(prompt string) bool {
+	words := " " + strings.Join(strings.Fields(strings.ToLower(prompt)), " ") + " "
+	words = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(",.;:!?()'\"", r) {
+			return ' '
+		}
+		return r
+	}, words)
+	for _, w := range stdinWords {
+		if strings.Contains(words, " "+w+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// FindReferences returns the @file references in a prompt that name existing
+// files, in order of appearance and without duplicates.
+func FindReferences(prompt string) []string {
+	var refs []string
+	seen := make(map[string]bool)
+
+	for _, word := range strings.Fields(prompt) {
+		ref := strings.TrimRight(word, ",.;:!?)'\"")
+		if len(ref) < 2 || ref[0] != '@' || seen[ref] {
+			continue
+		}
+
+		info, err := os.Stat(ref[1:])
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+
+	return refs
+}
+
+// AppendToPrompt adds payload descriptions to a natural language prompt
+func AppendToPrompt(prompt string, payloads []*Payload) string {
+	if len(payloads) == 0 {
+		return prompt
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\nAttached payloads (ncurl sends their bytes; refer to them by reference, never inline them):")
+	for _, p := range payloads {
+		sb.WriteString("\n\n")
+		sb.WriteString(p.Describe())
+	}
+
+	return sb.String()
+}
+
+// Resolve replaces payload references in a spec with the backing file paths.
+// If the model ignored a single attached payload on a request that can carry
+// a body, the payload is used as the body. Plain paths are only allowed if
+// they appear in the prompt, so the model can't upload files the user never
+// named; anything else is an ErrUnknownReference.
+func Resolve(spec *httpx.RequestSpec, prompt string, payloads []*Payload) error {
+	byRef := make(map[string]*Payload, len(payloads))
+	for _, p := range payloads {
+		byRef[p.Ref] = p
+	}
+
+	lookup := func(ref string) (*Payload, bool, error) {
+		if p, ok := byRef[ref]; ok {
+			return p, true, nil
+		}
+		if strings.HasPrefix(ref, "@") || !mentions(ref, prompt) {
+			return nil, false, fmt.Errorf("%w: %s", ErrUnknownReference, ref)
+		}
+		return nil, false, nil
+	}
+
+	usesPayload := false
+	if spec.BodyFile != "" {
+		p, ok, err := lookup(spec.BodyFile)
+		if err != nil {
+			return err
+		}
+		if ok {
+			spec.BodyFile = p.Path
+			setDefaultContentType(spec, p.ContentType)
+			usesPayload = true
+		}
+	}
+
+	for i := range spec.Files {
+		p, ok, err := lookup(spec.Files[i].Path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			spec.Files[i].Path = p.Path
+			if spec.Files[i].Filename == "" && !p.temp {
+				spec.Files[i].Filename = filepath.Base(p.Path)
+			}
+			if spec.Files[i].ContentType == "" {
+				spec.Files[i].ContentType = p.ContentType
+			}
+			usesPayload = true
+		}
+	}
+
+	if !usesPayload && len(payloads) == 1 && spec.Body == "" && !spec.IsMultipart() && methodAllowsBody(spec.Method) {
+		spec.BodyFile = payloads[0].Path
+		setDefaultContentType(spec, payloads[0].ContentType)
+	}
+
+	return nil
+}
+
+// CheckPaths returns ErrUnknownReference if spec reads a local file, as its
+// body_file or a file part, whose path doesn't appear in any of prompts. In
+// interactive and chain mode the model also sees response bodies, which
+// could otherwise steer it into uploading arbitrary files.
+func CheckPaths(spec *httpx.RequestSpec, prompts ...string) error {
+	paths := make([]string, 0, len(spec.Files)+1)
+	if spec.BodyFile != "" {
+		paths = append(paths, spec.BodyFile)
+	}
+	for _, f := range spec.Files {
+		paths = append(paths, f.Path)
+	}
+
+	for _, path := range paths {
+		allowed := false
+		for _, prompt := range prompts {
+			if mentions(path, prompt) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s", ErrUnknownReference, path)
+		}
+	}
+	return nil
+}
+
+// mentions reports whether prompt names path verbatim, as a word of its own
+// or in quotes
+func mentions(path, prompt string) bool {
+	if path == "" {
+		return false
+	}
+	for _, word := range strings.Fields(prompt) {
+		word = strings.TrimLeft(word, "('\"`")
+		if word == path || strings.TrimRight(word, ",.;:!?)'\"`") == path {
+			return true
+		}
+	}
+	return strings.Contains(prompt, `"`+path+`"`) || strings.Contains(prompt, "'"+path+"'")
+}
+
+// setDefaultContentType sets Content-Type unless the spec already has one
+func setDefaultContentType(spec *httpx.RequestSpec, contentType string) {
+	for k := range spec.Headers {
+		if strings.EqualFold(k, "Content-Type") {
+			return
+		}
+	}
+	if spec.Headers == nil {
+		spec.Headers = make(map[string]string)
+	}
+	spec.Headers["Content-Type"] = contentType
+}
+
+// methodAllowsBody reports whether a method conventionally carries a body
+func methodAllowsBody(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	default:
+		return false
+	}
+}
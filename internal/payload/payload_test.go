@@ -0,0 +1,232 @@
+package payload_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/payload"
+)
+
+func TestSchema(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:     "Scalars",
+			value:    map[string]interface{}{"id": 1.0, "name": "x", "active": true, "note": nil},
+			expected: `{"active": boolean, "id": number, "name": string, "note": null}`,
+		},
+		{
+			name: "Nested array",
+			value: map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"sku": "a", "qty": 2.0},
+					map[string]interface{}{"sku": "b", "qty": 1.0},
+				},
+			},
+			expected: `{"items": [{"qty": number, "sku": string}] (2 items)}`,
+		},
+		{
+			name:     "Empty array",
+			value:    []interface{}{},
+			expected: `[]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := payload.Schema(tc.value); got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFromFileSummaries(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Small payloads are shown verbatim
+	smallPath := filepath.Join(tempDir, "small.json")
+	if err := os.WriteFile(smallPath, []byte(`{"name": "John"}`), 0o600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	small, err := payload.FromFile("@small.json", smallPath)
+	if err != nil {
+		t.Fatalf("FromFile failed: %v", err)
+	}
+	if small.ContentType != "application/json" {
+		t.Errorf("Expected application/json, got %s", small.ContentType)
+	}
+	if !strings.Contains(small.Summary, `"John"`) {
+		t.Errorf("Expected small payload content in summary, got %s", small.Summary)
+	}
+
+	// Large JSON payloads only expose their schema
+	var sb strings.Builder
+	sb.WriteString(`{"orders": [`)
+	for i := range 200 {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `{"id": %d, "customer": "secret-customer-%d"}`, i, i)
+	}
+	sb.WriteString(`]}`)
+
+	largePath := filepath.Join(tempDir, "large.json")
+	if writeErr := os.WriteFile(largePath, []byte(sb.String()), 0o600); writeErr != nil {
+		t.Fatalf("Failed to write test file: %v", writeErr)
+	}
+	large, err := payload.FromFile("@large.json", largePath)
+	if err != nil {
+		t.Fatalf("FromFile failed: %v", err)
+	}
+	if strings.Contains(large.Summary, "secret-customer") {
+		t.Errorf("Expected large payload data to be hidden, got %s", large.Summary)
+	}
+	if !strings.Contains(large.Summary, "(200 items)") {
+		t.Errorf("Expected schema with item count, got %s", large.Summary)
+	}
+}
+
+func TestFromReader(t *testing.T) {
+	p, err := payload.FromReader(payload.StdinRef, strings.NewReader(`{"order": 1}`))
+	if err != nil {
+		t.Fatalf("FromReader failed: %v", err)
+	}
+
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		t.Fatalf("Failed to read buffered payload: %v", err)
+	}
+	if string(data) != `{"order": 1}` {
+		t.Errorf("Expected buffered payload to match input, got %s", data)
+	}
+
+	if closeErr := p.Close(); closeErr != nil {
+		t.Errorf("Close failed: %v", closeErr)
+	}
+	if _, statErr := os.Stat(p.Path); !os.IsNotExist(statErr) {
+		t.Error("Expected temporary payload file to be removed")
+	}
+
+	_, err = payload.FromReader(payload.StdinRef, strings.NewReader(""))
+	if !errors.Is(err, payload.ErrEmptyPayload) {
+		t.Errorf("Expected ErrEmptyPayload, got %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	p := &payload.Payload{Ref: "@data.json", Path: "/tmp/data.json", ContentType: "application/json"}
+
+	// Explicit body_file reference
+	spec := &httpx.RequestSpec{Method: "POST", URL: "https://api.test/orders", BodyFile: "@data.json"}
+	if err := payload.Resolve(spec, "send @data.json", []*payload.Payload{p}); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if spec.BodyFile != "/tmp/data.json" {
+		t.Errorf("Expected body file to be resolved, got %s", spec.BodyFile)
+	}
+	if spec.Headers["Content-Type"] != "application/json" {
+		t.Errorf("Expected Content-Type to default to the payload type, got %v", spec.Headers)
+	}
+
+	// A single ignored payload becomes the body
+	spec = &httpx.RequestSpec{Method: "PUT", URL: "https://api.test/orders/1"}
+	if err := payload.Resolve(spec, "send @data.json", []*payload.Payload{p}); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if spec.BodyFile != "/tmp/data.json" {
+		t.Errorf("Expected payload to be used as the body, got %q", spec.BodyFile)
+	}
+
+	// File parts can reference payloads too
+	spec = &httpx.RequestSpec{
+		Method: "POST",
+		URL:    "https://api.test/upload",
+		Files:  []httpx.FilePart{{Field: "metadata", Path: "@data.json"}},
+	}
+	if err := payload.Resolve(spec, "send @data.json", []*payload.Payload{p}); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if spec.Files[0].Path != "/tmp/data.json" || spec.Files[0].ContentType != "application/json" {
+		t.Errorf("Expected file part to be resolved, got %+v", spec.Files[0])
+	}
+
+	// Unknown references are rejected
+	spec = &httpx.RequestSpec{Method: "POST", URL: "https://api.test/orders", BodyFile: "@other.json"}
+	err := payload.Resolve(spec, "send @data.json", []*payload.Payload{p})
+	if !errors.Is(err, payload.ErrUnknownReference) {
+		t.Errorf("Expected ErrUnknownReference, got %v", err)
+	}
+
+	// Plain paths must be named in the prompt
+	prompt := "upload ./report.pdf to https://api.test/upload"
+	spec = &httpx.RequestSpec{Method: "POST", URL: "https://api.test/upload",
+		Files: []httpx.FilePart{{Field: "file", Path: "./report.pdf"}}}
+	if err := payload.Resolve(spec, prompt, nil); err != nil {
+		t.Errorf("Expected a path from the prompt to be allowed, got %v", err)
+	}
+	spec.Files = append(spec.Files, httpx.FilePart{Field: "key", Path: "/home/user/.ssh/id_rsa"})
+	if err := payload.Resolve(spec, prompt, nil); !errors.Is(err, payload.ErrUnknownReference) {
+		t.Errorf("Expected ErrUnknownReference for a path the prompt doesn't name, got %v", err)
+	}
+	spec = &httpx.RequestSpec{Method: "POST", URL: "https://api.test/upload", BodyFile: "report"}
+	if err := payload.Resolve(spec, prompt, nil); !errors.Is(err, payload.ErrUnknownReference) {
+		t.Errorf("Expected ErrUnknownReference for part of a word in the prompt, got %v", err)
+	}
+}
+
+func TestCheckPaths(t *testing.T) {
+	spec := &httpx.RequestSpec{Method: "POST", URL: "https://api.test/upload", BodyFile: "data.json"}
+	if err := payload.CheckPaths(spec, "get the upload url", `now post "data.json" to it`); err != nil {
+		t.Errorf("Expected a path from an earlier prompt to be allowed, got %v", err)
+	}
+	spec.BodyFile = "/etc/passwd"
+	err := payload.CheckPaths(spec, "post the file the last response mentions")
+	if !errors.Is(err, payload.ErrUnknownReference) {
+		t.Errorf("Expected ErrUnknownReference, got %v", err)
+	}
+	if err := payload.CheckPaths(&httpx.RequestSpec{Method: "GET", URL: "https://api.test/"}); err != nil {
+		t.Errorf("Expected a request without files to pass, got %v", err)
+	}
+}
+
+func TestReferencesStdin(t *testing.T) {
+	testCases := map[string]bool{
+		"post this to the orders API":               true,
+		"send @- to https://api.test/import":        true,
+		"upload the piped file to localhost:8080":   true,
+		"post @stdin, then show the response":       true,
+		"get users from https://api.test":           false,
+		"get https://api.test/thistle":              false,
+		"get this user's repos from GitHub":         false,
+		"send this as JSON to /import":              true,
+		"post the JSON from standard input to /new": true,
+	}
+	for prompt, want := range testCases {
+		if got := payload.ReferencesStdin(prompt); got != want {
+			t.Errorf("ReferencesStdin(%q) = %v, want %v", prompt, got, want)
+		}
+	}
+}
+
+func TestFindReferences(t *testing.T) {
+	tempDir := t.TempDir()
+	payloadPath := filepath.Join(tempDir, "payload.json")
+	if err := os.WriteFile(payloadPath, []byte("{}"), 0o600); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	prompt := fmt.Sprintf("post @%s, then mention @someone and @%s", payloadPath, filepath.Join(tempDir, "missing.json"))
+	refs := payload.FindReferences(prompt)
+	if len(refs) != 1 || refs[0] != "@"+payloadPath {
+		t.Errorf("Expected only @%s, got %v", payloadPath, refs)
+	}
+}
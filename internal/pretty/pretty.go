@@ -0,0 +1,406 @@
+// Package pretty formats HTTP responses for the terminal with optional
+// ANSI syntax highlighting
+package pretty
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Color modes accepted by ColorEnabled
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// ANSI escape sequences used for highlighting
+const (
+	ansiReset   = "\033[0m"
+	ansiBold    = "\033[1m"
+	ansiRed     = "\033[31m"
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+	ansiBlue    = "\033[34m"
+	ansiMagenta = "\033[35m"
+	ansiCyan    = "\033[36m"
+	ansiGray    = "\033[90m"
+)
+
+// indent is the indentation unit used for all formats
+const indent = "  "
+
+// ErrInvalidColorMode is returned for an unknown -color value
+var ErrInvalidColorMode = errors.New("invalid color mode")
+
+// ColorEnabled decides whether output to f should be colored. In auto mode
+// color is used only for terminals and is disabled by a non-empty NO_COLOR
+// or TERM=dumb.
+func ColorEnabled(mode string, f *os.File) (bool, error) {
+	switch mode {
+	case ColorAlways:
+		return true, nil
+	case ColorNever:
+		return false, nil
+	case ColorAuto, "":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		if os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		return isTerminal(f), nil
+	default:
+		return false, fmt.Errorf("%w: %q (use auto, always or never)", ErrInvalidColorMode, mode)
+	}
+}
+
+// isTerminal reports whether f is a character device such as a TTY
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Printer formats response bodies and status lines
+type Printer struct {
+	Color bool
+}
+
+// NewPrinter creates a printer that highlights output when color is true
+func NewPrinter(color bool) *Printer {
+	return &Printer{Color: color}
+}
+
+// paint wraps s in an ANSI style when color is enabled
+func (p *Printer) paint(style, s string) string {
+	if !p.Color || s == "" {
+		return s
+	}
+	return style + s + ansiReset
+}
+
+// Status formats a status line colored by its status class
+func (p *Printer) Status(status string, code int) string {
+	switch {
+	case code >= 500:
+		return p.paint(ansiBold+ansiRed, status)
+	case code >= 400:
+		return p.paint(ansiBold+ansiYellow, status)
+	case code >= 300:
+		return p.paint(ansiBold+ansiCyan, status)
+	case code >= 200:
+		return p.paint(ansiBold+ansiGreen, status)
+	default:
+		return p.paint(ansiBold, status)
+	}
+}
+
+// HeaderName formats a header name
+func (p *Printer) HeaderName(name string) string {
+	return p.paint(ansiCyan, name)
+}
+
+// Body pretty-prints a JSON, XML or HTML body. Bodies of other types, or
+// bodies that fail to parse, are returned unchanged.
+func (p *Printer) Body(body []byte, contentType string) string {
+	switch Kind(body, contentType) {
+	case KindJSON:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, bytes.TrimSpace(body), "", indent); err != nil {
+			return string(body)
+		}
+		return p.highlightJSON(buf.String())
+
+	case KindXML:
+		if out, err := p.formatMarkup(body, false); err == nil {
+			return out
+		}
+		return string(body)
+
+	case KindHTML:
+		if out, err := p.formatMarkup(body, true); err == nil {
+			return out
+		}
+		return string(body)
+
+	default:
+		return string(body)
+	}
+}
+
+// Body kinds detected by Kind
+const (
+	KindOther = "other"
+	KindJSON  = "json"
+	KindXML   = "xml"
+	KindHTML  = "html"
+)
+
+// Kind classifies a body by its content type, falling back to sniffing
+// JSON when no content type is given
+func Kind(body []byte, contentType string) string {
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "json"):
+		return KindJSON
+	case strings.Contains(ct, "html"):
+		return KindHTML
+	case strings.Contains(ct, "xml"):
+		return KindXML
+	case ct == "" || strings.HasPrefix(ct, "text/plain"):
+		trimmed := bytes.TrimSpace(body)
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+			return KindJSON
+		}
+	}
+	return KindOther
+}
+
+// highlightJSON colors keys, strings, numbers and literals in indented JSON
+func (p *Printer) highlightJSON(s string) string {
+	if !p.Color {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			end := scanString(s, i)
+			token := s[i:end]
+
+			// A string followed by a colon is an object key
+			rest := strings.TrimLeft(s[end:], " ")
+			if strings.HasPrefix(rest, ":") {
+				sb.WriteString(p.paint(ansiBold+ansiBlue, token))
+			} else {
+				sb.WriteString(p.paint(ansiGreen, token))
+			}
+			i = end
+
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(s) && strings.IndexByte("0123456789.eE+-", s[end]) >= 0 {
+				end++
+			}
+			sb.WriteString(p.paint(ansiCyan, s[i:end]))
+			i = end
+
+		case strings.HasPrefix(s[i:], "true"), strings.HasPrefix(s[i:], "null"):
+			sb.WriteString(p.paint(ansiMagenta, s[i:i+4]))
+			i += 4
+
+		case strings.HasPrefix(s[i:], "false"):
+			sb.WriteString(p.paint(ansiMagenta, s[i:i+5]))
+			i += 5
+
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+
+	return sb.String()
+}
+
+// scanString returns the index just past the JSON string starting at s[start]
+func scanString(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// formatMarkup re-indents XML or HTML, one element per line. Elements that
+// only contain text are kept on a single line.
+func (p *Printer) formatMarkup(body []byte, html bool) (string, error) {
+	// Script and style bodies are not markup; set them aside so the
+	// decoder doesn't trip over characters like '<' in JavaScript
+	var rawBlocks []string
+	if html {
+		body = rawTextElement.ReplaceAllFunc(body, func(m []byte) []byte {
+			parts := rawTextElement.FindSubmatch(m)
+			rawBlocks = append(rawBlocks, string(parts[2]))
+			return []byte(fmt.Sprintf("%s%s%d%s", parts[1], rawPlaceholder, len(rawBlocks)-1, parts[3]))
+		})
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	if html {
+		decoder.Strict = false
+		decoder.AutoClose = xml.HTMLAutoClose
+		decoder.Entity = xml.HTMLEntity
+	}
+
+	var tokens []xml.Token
+	for {
+		tok, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse markup: %w", err)
+		}
+
+		// Drop whitespace between elements; indentation is regenerated
+		if data, ok := tok.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		tokens = append(tokens, xml.CopyToken(tok))
+	}
+
+	var sb strings.Builder
+	depth := 0
+	writeLine := func(s string) {
+		sb.WriteString(strings.Repeat(indent, depth))
+		sb.WriteString(s)
+		sb.WriteString("\n")
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		switch tok := tokens[i].(type) {
+		case xml.StartElement:
+			open := p.startTag(tok)
+
+			// Empty elements and elements holding only text stay on one line
+			if next, ok := tokenAt(tokens, i+1).(xml.EndElement); ok && next.Name == tok.Name {
+				if html {
+					writeLine(open + p.endTag(next))
+				} else {
+					writeLine(strings.TrimSuffix(open, p.paint(ansiBlue, ">")) + p.paint(ansiBlue, "/>"))
+				}
+				i++
+				continue
+			}
+			if text, ok := tokenAt(tokens, i+1).(xml.CharData); ok && rawIndex(text) < 0 {
+				if end, isEnd := tokenAt(tokens, i+2).(xml.EndElement); isEnd && end.Name == tok.Name {
+					writeLine(open + escapeText(text) + p.endTag(end))
+					i += 2
+					continue
+				}
+			}
+
+			writeLine(open)
+			if !html || !isVoidElement(tok.Name.Local) {
+				depth++
+			}
+
+		case xml.EndElement:
+			if depth > 0 {
+				depth--
+			}
+			writeLine(p.endTag(tok))
+
+		case xml.CharData:
+			if idx := rawIndex(tok); idx >= 0 && idx < len(rawBlocks) {
+				for _, line := range strings.Split(strings.TrimSpace(rawBlocks[idx]), "\n") {
+					writeLine(strings.TrimRight(line, " \t\r"))
+				}
+				continue
+			}
+			writeLine(escapeText(tok))
+
+		case xml.Comment:
+			writeLine(p.paint(ansiGray, "<!--"+string(tok)+"-->"))
+
+		case xml.ProcInst:
+			writeLine(p.paint(ansiGray, fmt.Sprintf("<?%s %s?>", tok.Target, tok.Inst)))
+
+		case xml.Directive:
+			writeLine(p.paint(ansiGray, "<!"+string(tok)+">"))
+		}
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// rawTextElement matches HTML elements whose content is not parsed as markup
+var rawTextElement = regexp.MustCompile(`(?is)(<(?:script|style)\b[^>]*>)(.*?)(</(?:script|style)\s*>)`)
+
+// rawPlaceholder stands in for a set-aside script or style body
+const rawPlaceholder = "ncurl-raw-text-"
+
+// rawIndex returns the raw block index for placeholder text, or -1
+func rawIndex(data []byte) int {
+	text := string(bytes.TrimSpace(data))
+	if !strings.HasPrefix(text, rawPlaceholder) {
+		return -1
+	}
+	idx, err := strconv.Atoi(strings.TrimPrefix(text, rawPlaceholder))
+	if err != nil {
+		return -1
+	}
+	return idx
+}
+
+// tokenAt returns tokens[i] or nil when out of range
+func tokenAt(tokens []xml.Token, i int) xml.Token {
+	if i < len(tokens) {
+		return tokens[i]
+	}
+	return nil
+}
+
+// startTag renders an opening tag with its attributes
+func (p *Printer) startTag(el xml.StartElement) string {
+	var sb strings.Builder
+	sb.WriteString(p.paint(ansiBlue, "<"+qualifiedName(el.Name)))
+	for _, attr := range el.Attr {
+		sb.WriteString(" ")
+		sb.WriteString(p.paint(ansiYellow, qualifiedName(attr.Name)))
+		sb.WriteString("=")
+
+		value := strings.ReplaceAll(textEscaper.Replace(attr.Value), `"`, "&quot;")
+		sb.WriteString(p.paint(ansiGreen, `"`+value+`"`))
+	}
+	sb.WriteString(p.paint(ansiBlue, ">"))
+	return sb.String()
+}
+
+// endTag renders a closing tag
+func (p *Printer) endTag(el xml.EndElement) string {
+	return p.paint(ansiBlue, "</"+qualifiedName(el.Name)+">")
+}
+
+// qualifiedName joins a raw token name with its namespace prefix
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// textEscaper escapes the characters that are significant in markup text
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeText trims and escapes character data for output
+func escapeText(data []byte) string {
+	return textEscaper.Replace(string(bytes.TrimSpace(data)))
+}
+
+// isVoidElement reports whether an HTML element never has a closing tag
+func isVoidElement(name string) bool {
+	for _, void := range xml.HTMLAutoClose {
+		if strings.EqualFold(name, void) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package pretty_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stephenbyrne99/ncurl/internal/pretty"
+)
+
+func TestBodyFormatting(t *testing.T) {
+	printer := pretty.NewPrinter(false)
+
+	testCases := []struct {
+		name        string
+		body        string
+		contentType string
+		expected    string
+	}{
+		{
+			name:        "Minified JSON",
+			body:        `{"id":1,"tags":["a","b"]}`,
+			contentType: "application/json; charset=utf-8",
+			expected:    "{\n  \"id\": 1,\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}",
+		},
+		{
+			name:        "JSON without content type",
+			body:        `[1,2]`,
+			contentType: "",
+			expected:    "[\n  1,\n  2\n]",
+		},
+		{
+			name:        "XML",
+			body:        `<?xml version="1.0"?><users><user id="1"><name>Jane &amp; co</name></user><empty></empty></users>`,
+			contentType: "application/xml",
+			expected: strings.Join([]string{
+				`<?xml version="1.0"?>`,
+				`<users>`,
+				`  <user id="1">`,
+				`    <name>Jane &amp; co</name>`,
+				`  </user>`,
+				`  <empty/>`,
+				`</users>`,
+			}, "\n"),
+		},
+		{
+			name:        "HTML with void elements and scripts",
+			body:        `<html><body><p>Hi</p><br><script>if (a < b) {}</script></body></html>`,
+			contentType: "text/html",
+			expected: strings.Join([]string{
+				`<html>`,
+				`  <body>`,
+				`    <p>Hi</p>`,
+				`    <br>`,
+				`    <script>`,
+				`      if (a < b) {}`,
+				`    </script>`,
+				`  </body>`,
+				`</html>`,
+			}, "\n"),
+		},
+		{
+			name:        "Invalid JSON is left alone",
+			body:        `{"id":`,
+			contentType: "application/json",
+			expected:    `{"id":`,
+		},
+		{
+			name:        "Plain text is left alone",
+			body:        "hello world",
+			contentType: "text/plain",
+			expected:    "hello world",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := printer.Body([]byte(tc.body), tc.contentType)
+			if got != tc.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestHighlighting(t *testing.T) {
+	printer := pretty.NewPrinter(true)
+
+	out := printer.Body([]byte(`{"ok":true,"count":3,"name":"x"}`), "application/json")
+	if !strings.Contains(out, "\033[") {
+		t.Fatalf("Expected ANSI escapes in highlighted output, got %q", out)
+	}
+
+	// Removing the escapes must give back the plain formatting
+	plain := pretty.NewPrinter(false).Body([]byte(`{"ok":true,"count":3,"name":"x"}`), "application/json")
+	if stripANSI(out) != plain {
+		t.Errorf("Expected highlighted output to match plain output, got %q", stripANSI(out))
+	}
+
+	if got := pretty.NewPrinter(false).Status("404 Not Found", 404); got != "404 Not Found" {
+		t.Errorf("Expected uncolored status, got %q", got)
+	}
+	if got := printer.Status("500 Internal Server Error", 500); !strings.Contains(got, "\033[31m") {
+		t.Errorf("Expected red 5xx status, got %q", got)
+	}
+	if got := printer.Status("200 OK", 200); !strings.Contains(got, "\033[32m") {
+		t.Errorf("Expected green 2xx status, got %q", got)
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	// Test output is never a terminal, so auto mode is off
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+	}
+	defer func() { _ = devNull.Close() }()
+
+	pipeR, pipeW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer func() { _ = pipeR.Close() }()
+	defer func() { _ = pipeW.Close() }()
+
+	if on, _ := pretty.ColorEnabled(pretty.ColorAuto, pipeW); on {
+		t.Error("Expected no color for a pipe in auto mode")
+	}
+	if on, _ := pretty.ColorEnabled(pretty.ColorAlways, pipeW); !on {
+		t.Error("Expected color in always mode")
+	}
+
+	// An empty NO_COLOR doesn't count, per no-color.org
+	t.Setenv("TERM", "xterm")
+	t.Setenv("NO_COLOR", "")
+	if on, _ := pretty.ColorEnabled(pretty.ColorAuto, devNull); !on {
+		t.Error("Expected an empty NO_COLOR to leave color on for a character device")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if on, _ := pretty.ColorEnabled(pretty.ColorAuto, devNull); on {
+		t.Error("Expected NO_COLOR to disable color in auto mode")
+	}
+	if on, _ := pretty.ColorEnabled(pretty.ColorNever, devNull); on {
+		t.Error("Expected no color in never mode")
+	}
+
+	if _, modeErr := pretty.ColorEnabled("sometimes", devNull); !errors.Is(modeErr, pretty.ErrInvalidColorMode) {
+		t.Errorf("Expected ErrInvalidColorMode, got %v", modeErr)
+	}
+}
+
+// stripANSI removes ANSI escape sequences from s
+func stripANSI(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\033' {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,167 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats accepted by Format
+const (
+	FormatAuto  = "auto"
+	FormatJSON  = "json"
+	FormatTable = "table"
+	FormatLines = "lines"
+)
+
+// ErrInvalidFormat is returned for an unknown output format
+var ErrInvalidFormat = errors.New("invalid output format")
+
+// Format writes query results to w. Auto picks a table for objects with
+// scalar fields, lines for scalars and JSON for anything else.
+func Format(w io.Writer, results []interface{}, format string) error {
+	if format == FormatAuto || format == "" {
+		format = detectFormat(results)
+	}
+
+	switch format {
+	case FormatJSON:
+		for _, v := range results {
+			out, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode result: %w", err)
+			}
+			if _, err = fmt.Fprintln(w, string(out)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatLines:
+		for _, v := range results {
+			if _, err := fmt.Fprintln(w, cell(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatTable:
+		return writeTable(w, results)
+
+	default:
+		return ValidateFormat(format)
+	}
+}
+
+// ValidateFormat returns ErrInvalidFormat for an unknown output format
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatAuto, FormatJSON, FormatTable, FormatLines, "":
+		return nil
+	default:
+		return fmt.Errorf("%w: %q (use auto, json, table or lines)", ErrInvalidFormat, format)
+	}
+}
+
+// detectFormat chooses an output format that suits the results
+func detectFormat(results []interface{}) string {
+	if len(results) == 0 {
+		return FormatJSON
+	}
+
+	allScalars, allFlatObjects := true, true
+	for _, v := range results {
+		obj, isObject := v.(map[string]interface{})
+		if isObject {
+			allScalars = false
+			for _, field := range obj {
+				if !isScalar(field) {
+					allFlatObjects = false
+				}
+			}
+			continue
+		}
+		allFlatObjects = false
+		if !isScalar(v) {
+			allScalars = false
+		}
+	}
+
+	switch {
+	case allScalars:
+		return FormatLines
+	case allFlatObjects:
+		return FormatTable
+	default:
+		return FormatJSON
+	}
+}
+
+// writeTable writes object results as aligned columns, one column per key
+// found in any row
+func writeTable(w io.Writer, results []interface{}) error {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, v := range results {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: table output needs objects, got %s", ErrInvalidFormat, typeName(v))
+		}
+		for _, k := range sortedKeys(obj) {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = strings.ToUpper(c)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, v := range results {
+		obj, _ := v.(map[string]interface{})
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			row[i] = cell(obj[c])
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// cell renders a value for line or table output
+func cell(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.ReplaceAll(val, "\n", " ")
+	case float64, bool:
+		out, _ := json.Marshal(val)
+		return string(out)
+	default:
+		out, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(out)
+	}
+}
+
+// isScalar reports whether v is a string, number, boolean or null
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,812 @@
+// Package query implements a small jq-style language for extracting data
+// from JSON responses.
+//
+// Supported syntax:
+//
+//	.                      identity
+//	.foo.bar  ."a key"     object fields
+//	.[0]  .items[-1]       array indexes
+//	.[]  .items[]          iterate arrays and object values
+//	a | b                  pipe the results of a into b
+//	a, b                   concatenate the results of a and b
+//	{title, author: .user.login}   build objects
+//	[ .items[].id ]        collect results into an array
+//	select(.state == "open" and .comments > 2)
+//	length  keys  first  last
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrSyntax  = errors.New("query syntax error")
+	ErrRuntime = errors.New("query evaluation error")
+)
+
+// Query is a compiled expression
+type Query struct {
+	source string
+	root   node
+}
+
+// node evaluates an expression against a single input value and returns
+// the stream of results
+type node func(input interface{}) ([]interface{}, error)
+
+// Parse compiles a query expression
+func Parse(expression string) (*Query, error) {
+	p := &parser{lex: newLexer(expression)}
+	if err := p.lex.run(); err != nil {
+		return nil, err
+	}
+
+	root, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrSyntax, tok.text, tok.pos)
+	}
+
+	return &Query{source: expression, root: root}, nil
+}
+
+// String returns the source expression
+func (q *Query) String() string {
+	return q.source
+}
+
+// Run evaluates the query against decoded JSON data
+func (q *Query) Run(data interface{}) ([]interface{}, error) {
+	return q.root(data)
+}
+
+// RunJSON decodes a JSON document and evaluates the query against it
+func (q *Query) RunJSON(body []byte) ([]interface{}, error) {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("%w: response is not valid JSON: %w", ErrRuntime, err)
+	}
+	return q.Run(data)
+}
+
+// Token kinds produced by the lexer
+const (
+	tokEOF = iota
+	tokDot
+	tokIdent
+	tokString
+	tokNumber
+	tokPunct // one of | , : [ ] { } ( )
+	tokOp    // comparison operator
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+type lexer struct {
+	input  string
+	tokens []token
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input}
+}
+
+// run splits the input into tokens
+func (l *lexer) run() error {
+	s := l.input
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '.':
+			l.tokens = append(l.tokens, token{kind: tokDot, text: ".", pos: i})
+			i++
+
+		case strings.ContainsRune("|,:[]{}()", c):
+			l.tokens = append(l.tokens, token{kind: tokPunct, text: string(c), pos: i})
+			i++
+
+		case strings.ContainsRune("=!<>", c):
+			end := i + 1
+			if end < len(s) && s[end] == '=' {
+				end++
+			}
+			op := s[i:end]
+			if op == "=" || op == "!" {
+				return fmt.Errorf("%w: unexpected %q at position %d (use == or !=)", ErrSyntax, op, i)
+			}
+			l.tokens = append(l.tokens, token{kind: tokOp, text: op, pos: i})
+			i = end
+
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return fmt.Errorf("%w: unterminated string at position %d", ErrSyntax, i)
+			}
+			text, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return fmt.Errorf("%w: invalid string at position %d", ErrSyntax, i)
+			}
+			l.tokens = append(l.tokens, token{kind: tokString, text: text, pos: i})
+			i = end + 1
+
+		case c == '-' || unicode.IsDigit(c):
+			end := i + 1
+			for end < len(s) && (unicode.IsDigit(rune(s[end])) || s[end] == '.') {
+				end++
+			}
+			l.tokens = append(l.tokens, token{kind: tokNumber, text: s[i:end], pos: i})
+			i = end
+
+		case c == '_' || unicode.IsLetter(c):
+			end := i + 1
+			for end < len(s) && (s[end] == '_' || s[end] == '-' ||
+				unicode.IsLetter(rune(s[end])) || unicode.IsDigit(rune(s[end]))) {
+				end++
+			}
+			l.tokens = append(l.tokens, token{kind: tokIdent, text: s[i:end], pos: i})
+			i = end
+
+		default:
+			return fmt.Errorf("%w: unexpected %q at position %d", ErrSyntax, string(c), i)
+		}
+	}
+
+	l.tokens = append(l.tokens, token{kind: tokEOF, pos: len(s)})
+	return nil
+}
+
+type parser struct {
+	lex *lexer
+	pos int
+}
+
+func (p *parser) peek() token {
+	return p.lex.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.lex.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isPunct reports whether the next token is the given punctuation
+func (p *parser) isPunct(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokPunct && tok.text == text
+}
+
+// expect consumes the given punctuation or fails
+func (p *parser) expect(text string) error {
+	tok := p.next()
+	if tok.kind != tokPunct || tok.text != text {
+		return fmt.Errorf("%w: expected %q at position %d", ErrSyntax, text, tok.pos)
+	}
+	return nil
+}
+
+// parsePipe parses: comma ('|' comma)*
+func (p *parser) parsePipe() (node, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isPunct("|") {
+		p.next()
+		right, rightErr := p.parseComma()
+		if rightErr != nil {
+			return nil, rightErr
+		}
+		left = pipe(left, right)
+	}
+
+	return left, nil
+}
+
+// parseComma parses: term (',' term)*
+func (p *parser) parseComma() (node, error) {
+	first, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := []node{first}
+	for p.isPunct(",") {
+		p.next()
+		term, termErr := p.parseTerm()
+		if termErr != nil {
+			return nil, termErr
+		}
+		terms = append(terms, term)
+	}
+
+	if len(terms) == 1 {
+		return first, nil
+	}
+
+	return func(input interface{}) ([]interface{}, error) {
+		var out []interface{}
+		for _, term := range terms {
+			results, termErr := term(input)
+			if termErr != nil {
+				return nil, termErr
+			}
+			out = append(out, results...)
+		}
+		return out, nil
+	}, nil
+}
+
+// parseTerm parses a path, literal, constructor or function call
+func (p *parser) parseTerm() (node, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokDot:
+		return p.parsePath()
+
+	case tok.kind == tokPunct && tok.text == "{":
+		return p.parseObject()
+
+	case tok.kind == tokPunct && tok.text == "[":
+		p.next()
+		if p.isPunct("]") {
+			p.next()
+			return constant([]interface{}{}), nil
+		}
+		inner, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if expectErr := p.expect("]"); expectErr != nil {
+			return nil, expectErr
+		}
+		return collect(inner), nil
+
+	case tok.kind == tokPunct && tok.text == "(":
+		p.next()
+		inner, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if expectErr := p.expect(")"); expectErr != nil {
+			return nil, expectErr
+		}
+		return inner, nil
+
+	case tok.kind == tokString, tok.kind == tokNumber:
+		return p.parseLiteral()
+
+	case tok.kind == tokIdent:
+		return p.parseFunction()
+
+	default:
+		return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrSyntax, tok.text, tok.pos)
+	}
+}
+
+// parsePath parses '.' followed by any number of field and index steps
+func (p *parser) parsePath() (node, error) {
+	p.next() // leading dot
+	var steps []node
+
+	// A bare field may follow the leading dot directly, e.g. .foo or ."foo"
+	if tok := p.peek(); tok.kind == tokIdent || tok.kind == tokString {
+		p.next()
+		steps = append(steps, field(tok.text))
+	}
+
+	for {
+		switch {
+		case p.peek().kind == tokDot:
+			p.next()
+			tok := p.next()
+			if tok.kind != tokIdent && tok.kind != tokString {
+				return nil, fmt.Errorf("%w: expected field name at position %d", ErrSyntax, tok.pos)
+			}
+			steps = append(steps, field(tok.text))
+
+		case p.isPunct("["):
+			p.next()
+			if p.isPunct("]") {
+				p.next()
+				steps = append(steps, iterate)
+				continue
+			}
+
+			tok := p.next()
+			switch tok.kind {
+			case tokNumber:
+				idx, err := strconv.Atoi(tok.text)
+				if err != nil {
+					return nil, fmt.Errorf("%w: invalid index %q at position %d", ErrSyntax, tok.text, tok.pos)
+				}
+				steps = append(steps, index(idx))
+			case tokString:
+				steps = append(steps, field(tok.text))
+			default:
+				return nil, fmt.Errorf("%w: expected index at position %d", ErrSyntax, tok.pos)
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+
+		default:
+			if len(steps) == 0 {
+				return identity, nil
+			}
+			result := steps[0]
+			for _, step := range steps[1:] {
+				result = pipe(result, step)
+			}
+			return result, nil
+		}
+	}
+}
+
+// parseObject parses {key: expr, key, "quoted key": expr}
+func (p *parser) parseObject() (node, error) {
+	p.next() // {
+
+	type entry struct {
+		key   string
+		value node
+	}
+	var entries []entry
+
+	for !p.isPunct("}") {
+		tok := p.next()
+		if tok.kind != tokIdent && tok.kind != tokString {
+			return nil, fmt.Errorf("%w: expected object key at position %d", ErrSyntax, tok.pos)
+		}
+
+		value := field(tok.text)
+		if p.isPunct(":") {
+			p.next()
+			parsed, err := p.parseObjectValue()
+			if err != nil {
+				return nil, err
+			}
+			value = parsed
+		}
+		entries = append(entries, entry{key: tok.text, value: value})
+
+		if p.isPunct(",") {
+			p.next()
+			continue
+		}
+		if !p.isPunct("}") {
+			return nil, fmt.Errorf("%w: expected ',' or '}' at position %d", ErrSyntax, p.peek().pos)
+		}
+	}
+	p.next() // }
+
+	return func(input interface{}) ([]interface{}, error) {
+		obj := make(map[string]interface{}, len(entries))
+		for _, e := range entries {
+			results, err := e.value(input)
+			if err != nil {
+				return nil, err
+			}
+			switch len(results) {
+			case 0:
+				obj[e.key] = nil
+			case 1:
+				obj[e.key] = results[0]
+			default:
+				obj[e.key] = results
+			}
+		}
+		return []interface{}{obj}, nil
+	}, nil
+}
+
+// parseObjectValue parses a value inside an object constructor, where a
+// bare comma separates entries rather than concatenating results
+func (p *parser) parseObjectValue() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("|") {
+		p.next()
+		right, rightErr := p.parseTerm()
+		if rightErr != nil {
+			return nil, rightErr
+		}
+		left = pipe(left, right)
+	}
+	return left, nil
+}
+
+// parseLiteral parses a string or number literal
+func (p *parser) parseLiteral() (node, error) {
+	tok := p.next()
+	if tok.kind == tokString {
+		return constant(tok.text), nil
+	}
+
+	n, err := strconv.ParseFloat(tok.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid number %q at position %d", ErrSyntax, tok.text, tok.pos)
+	}
+	return constant(n), nil
+}
+
+// parseFunction parses literals and built-in functions
+func (p *parser) parseFunction() (node, error) {
+	tok := p.next()
+	switch tok.text {
+	case "true":
+		return constant(true), nil
+	case "false":
+		return constant(false), nil
+	case "null":
+		return constant(nil), nil
+	case "length":
+		return length, nil
+	case "keys":
+		return keys, nil
+	case "first":
+		return index(0), nil
+	case "last":
+		return index(-1), nil
+	case "select":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		if expectErr := p.expect(")"); expectErr != nil {
+			return nil, expectErr
+		}
+		return selectNode(cond), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown function %q at position %d", ErrSyntax, tok.text, tok.pos)
+	}
+}
+
+// condition tests a single input value
+type condition func(input interface{}) (bool, error)
+
+// parseCondition parses comparisons joined with and/or (and binds tighter)
+func (p *parser) parseCondition() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.kind == tokIdent && tok.text == "or"; tok = p.peek() {
+		p.next()
+		right, rightErr := p.parseAnd()
+		if rightErr != nil {
+			return nil, rightErr
+		}
+		l, r := left, right
+		left = func(input interface{}) (bool, error) {
+			ok, lErr := l(input)
+			if lErr != nil || ok {
+				return ok, lErr
+			}
+			return r(input)
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.kind == tokIdent && tok.text == "and"; tok = p.peek() {
+		p.next()
+		right, rightErr := p.parseComparison()
+		if rightErr != nil {
+			return nil, rightErr
+		}
+		l, r := left, right
+		left = func(input interface{}) (bool, error) {
+			ok, lErr := l(input)
+			if lErr != nil || !ok {
+				return ok, lErr
+			}
+			return r(input)
+		}
+	}
+	return left, nil
+}
+
+// parseComparison parses "term op term", or a lone term tested for truthiness
+func (p *parser) parseComparison() (condition, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokOp {
+		return func(input interface{}) (bool, error) {
+			results, evalErr := left(input)
+			if evalErr != nil {
+				return false, evalErr
+			}
+			return len(results) > 0 && truthy(results[0]), nil
+		}, nil
+	}
+
+	op := p.next().text
+	right, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(input interface{}) (bool, error) {
+		lv, lErr := single(left, input)
+		if lErr != nil {
+			return false, lErr
+		}
+		rv, rErr := single(right, input)
+		if rErr != nil {
+			return false, rErr
+		}
+		return Compare(lv, op, rv)
+	}, nil
+}
+
+// single evaluates n and returns its first result, or nil
+func single(n node, input interface{}) (interface{}, error) {
+	results, err := n(input)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// Compare applies a comparison operator to two JSON values. Numbers and
+// strings are ordered; other values only support == and !=.
+func Compare(left interface{}, op string, right interface{}) (bool, error) {
+	switch op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	}
+
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false, nil
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false, nil
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return false, nil
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	default:
+		return false, fmt.Errorf("%w: unknown operator %q", ErrSyntax, op)
+	}
+}
+
+// equal compares two JSON values by their canonical encoding
+func equal(a, b interface{}) bool {
+	aj, aErr := json.Marshal(a)
+	bj, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aj) == string(bj)
+}
+
+// truthy follows jq: everything except false and null is true
+func truthy(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return true
+}
+
+func identity(input interface{}) ([]interface{}, error) {
+	return []interface{}{input}, nil
+}
+
+func constant(v interface{}) node {
+	return func(interface{}) ([]interface{}, error) {
+		return []interface{}{v}, nil
+	}
+}
+
+func pipe(left, right node) node {
+	return func(input interface{}) ([]interface{}, error) {
+		leftResults, err := left(input)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, v := range leftResults {
+			results, rightErr := right(v)
+			if rightErr != nil {
+				return nil, rightErr
+			}
+			out = append(out, results...)
+		}
+		return out, nil
+	}
+}
+
+func collect(inner node) node {
+	return func(input interface{}) ([]interface{}, error) {
+		results, err := inner(input)
+		if err != nil {
+			return nil, err
+		}
+		if results == nil {
+			results = []interface{}{}
+		}
+		return []interface{}{results}, nil
+	}
+}
+
+func field(name string) node {
+	return func(input interface{}) ([]interface{}, error) {
+		switch v := input.(type) {
+		case map[string]interface{}:
+			return []interface{}{v[name]}, nil
+		case nil:
+			return []interface{}{nil}, nil
+		default:
+			return nil, fmt.Errorf("%w: cannot get field %q of %s", ErrRuntime, name, typeName(input))
+		}
+	}
+}
+
+func index(i int) node {
+	return func(input interface{}) ([]interface{}, error) {
+		switch v := input.(type) {
+		case []interface{}:
+			idx := i
+			if idx < 0 {
+				idx += len(v)
+			}
+			if idx < 0 || idx >= len(v) {
+				return []interface{}{nil}, nil
+			}
+			return []interface{}{v[idx]}, nil
+		case nil:
+			return []interface{}{nil}, nil
+		default:
+			return nil, fmt.Errorf("%w: cannot index %s with a number", ErrRuntime, typeName(input))
+		}
+	}
+}
+
+func iterate(input interface{}) ([]interface{}, error) {
+	switch v := input.(type) {
+	case []interface{}:
+		return v, nil
+	case map[string]interface{}:
+		names := sortedKeys(v)
+		out := make([]interface{}, 0, len(names))
+		for _, k := range names {
+			out = append(out, v[k])
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%w: cannot iterate over %s", ErrRuntime, typeName(input))
+	}
+}
+
+func length(input interface{}) ([]interface{}, error) {
+	switch v := input.(type) {
+	case []interface{}:
+		return []interface{}{float64(len(v))}, nil
+	case map[string]interface{}:
+		return []interface{}{float64(len(v))}, nil
+	case string:
+		return []interface{}{float64(len([]rune(v)))}, nil
+	case nil:
+		return []interface{}{float64(0)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s has no length", ErrRuntime, typeName(input))
+	}
+}
+
+func keys(input interface{}) ([]interface{}, error) {
+	obj, ok := input.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s has no keys", ErrRuntime, typeName(input))
+	}
+	names := sortedKeys(obj)
+	out := make([]interface{}, 0, len(names))
+	for _, k := range names {
+		out = append(out, k)
+	}
+	return []interface{}{out}, nil
+}
+
+func selectNode(cond condition) node {
+	return func(input interface{}) ([]interface{}, error) {
+		ok, err := cond(input)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, nil
+		}
+		return []interface{}{input}, nil
+	}
+}
+
+// sortedKeys returns the keys of an object in sorted order
+func sortedKeys(obj map[string]interface{}) []string {
+	names := make([]string, 0, len(obj))
+	for k := range obj {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// typeName returns the JSON type name of a decoded value
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
@@ -0,0 +1,127 @@
+package query_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stephenbyrne99/ncurl/internal/query"
+)
+
+const issues = `{
+  "total": 3,
+  "items": [
+    {"number": 1, "title": "Crash on start", "state": "open", "comments": 4, "user": {"login": "ana"}},
+    {"number": 2, "title": "Typo in docs", "state": "closed", "comments": 0, "user": {"login": "ben"}},
+    {"number": 3, "title": "Slow search", "state": "open", "comments": 1, "user": {"login": "cy"}}
+  ]
+}`
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name       string
+		expression string
+		expected   string // JSON encoding of the result stream
+	}{
+		{name: "Identity field", expression: ".total", expected: `[3]`},
+		{name: "Index", expression: ".items[0].title", expected: `["Crash on start"]`},
+		{name: "Negative index", expression: ".items[-1].number", expected: `[3]`},
+		{name: "Iterate", expression: ".items[].user.login", expected: `["ana","ben","cy"]`},
+		{name: "Pipe", expression: ".items[] | .number", expected: `[1,2,3]`},
+		{name: "Comma", expression: ".total, .items[0].number", expected: `[3,1]`},
+		{name: "Collect", expression: "[.items[].number]", expected: `[[1,2,3]]`},
+		{name: "Length", expression: ".items | length", expected: `[3]`},
+		{name: "Keys", expression: ".items[0].user | keys", expected: `[["login"]]`},
+		{name: "Missing field", expression: ".nope.deeper", expected: `[null]`},
+		{
+			name:       "Object construction",
+			expression: ".items[0] | {title, author: .user.login}",
+			expected:   `[{"author":"ana","title":"Crash on start"}]`,
+		},
+		{
+			name:       "Select",
+			expression: `.items[] | select(.state == "open" and .comments > 2) | .number`,
+			expected:   `[1]`,
+		},
+		{
+			name:       "Select with or",
+			expression: `[.items[] | select(.comments == 0 or .user.login == "cy") | .number]`,
+			expected:   `[[2,3]]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := query.Parse(tc.expression)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			results, err := q.RunJSON([]byte(issues))
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			got, err := json.Marshal(results)
+			if err != nil {
+				t.Fatalf("Failed to encode results: %v", err)
+			}
+			if string(got) != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, expression := range []string{"", ".items[", "{title", ".a = 1", "unknown", `select(.a == "x"`} {
+		if _, err := query.Parse(expression); !errors.Is(err, query.ErrSyntax) {
+			t.Errorf("Expected ErrSyntax for %q, got %v", expression, err)
+		}
+	}
+
+	q, err := query.Parse(".total[]")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, runErr := q.RunJSON([]byte(issues)); !errors.Is(runErr, query.ErrRuntime) {
+		t.Errorf("Expected ErrRuntime iterating a number, got %v", runErr)
+	}
+	if _, runErr := q.RunJSON([]byte("not json")); !errors.Is(runErr, query.ErrRuntime) {
+		t.Errorf("Expected ErrRuntime for invalid JSON, got %v", runErr)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	rows := []interface{}{
+		map[string]interface{}{"title": "Crash on start", "number": 1.0},
+		map[string]interface{}{"title": "Slow search", "number": 3.0},
+	}
+
+	var buf bytes.Buffer
+	if err := query.Format(&buf, rows, query.FormatAuto); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	expected := strings.Join([]string{
+		"NUMBER  TITLE",
+		"1       Crash on start",
+		"3       Slow search",
+		"",
+	}, "\n")
+	if buf.String() != expected {
+		t.Errorf("Expected table:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := query.Format(&buf, []interface{}{"ana", "ben"}, query.FormatAuto); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if buf.String() != "ana\nben\n" {
+		t.Errorf("Expected one value per line, got %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := query.Format(&buf, []interface{}{"ana"}, query.FormatTable); !errors.Is(err, query.ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for a table of strings, got %v", err)
+	}
+}
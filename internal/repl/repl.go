@@ -0,0 +1,403 @@
+// Package repl runs an interactive session where each prompt can build on
+// the earlier requests, their responses and session variables
+package repl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/chain"
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+	ErrNoRequest      = errors.New("no request yet")
+	ErrNoResponse     = errors.New("no response yet")
+	ErrUsage          = errors.New("usage")
+)
+
+// maxResponseContext limits how much of the last response body is sent
+// to the model with the next prompt
+const maxResponseContext = 2048
+
+// Prompt is shown before each line of input
+const Prompt = "ncurl> "
+
+// TranslateFunc turns a prompt, with the session context appended, into a
+// request. It is expected to remember earlier turns.
+type TranslateFunc func(ctx context.Context, turn string) (*httpx.RequestSpec, error)
+
+// ExecuteFunc sends a request
+type ExecuteFunc func(ctx context.Context, spec *httpx.RequestSpec) (*httpx.Response, error)
+
+// Options configures a REPL
+type Options struct {
+	Translate TranslateFunc
+	Execute   ExecuteFunc
+	Print     func(resp *httpx.Response)                                // Shows a response
+	Edit      func(spec *httpx.RequestSpec) (*httpx.RequestSpec, error) // Optional, enables :edit
+	Reset     func()                                                    // Optional, forgets earlier turns
+	Prompted  func(prompt string)                                       // Optional, sees each prompt as typed
+	Timeout   time.Duration                                             // Per prompt, including the request
+}
+
+// REPL holds the state of an interactive session
+type REPL struct {
+	opts   Options
+	out    io.Writer
+	vars   map[string]string
+	spec   *httpx.RequestSpec // The current request, before variables are filled in
+	edited bool               // Whether spec was edited since the model last saw it
+	last   *httpx.Response
+}
+
+// New creates a REPL that writes to out
+func New(out io.Writer, opts Options) *REPL {
+	return &REPL{
+		opts: opts,
+		out:  out,
+		vars: make(map[string]string),
+	}
+}
+
+// Run reads prompts and commands from in until it ends or :quit is entered.
+// Errors are reported to the output and the session carries on.
+func (r *REPL) Run(ctx context.Context, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.out, Prompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, ":") {
+			r.report(r.ask(ctx, line))
+			continue
+		}
+
+		quit, err := r.command(ctx, line)
+		r.report(err)
+		if quit {
+			return nil
+		}
+	}
+}
+
+// report prints an error, if any
+func (r *REPL) report(err error) {
+	if err != nil {
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+	}
+}
+
+// ask translates a prompt in the context of the session and sends it
+func (r *REPL) ask(ctx context.Context, prompt string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// Only what the user typed, not the context added to the turn
+	if r.opts.Prompted != nil {
+		r.opts.Prompted(prompt)
+	}
+
+	spec, err := r.opts.Translate(ctx, r.turn(prompt))
+	if err != nil {
+		return err
+	}
+	r.spec, r.edited = spec, false
+
+	return r.send(ctx)
+}
+
+// turn appends what the model needs to know about the session to a prompt
+func (r *REPL) turn(prompt string) string {
+	var sb strings.Builder
+	sb.WriteString(prompt)
+
+	if len(r.vars) > 0 {
+		fmt.Fprintf(&sb, "\n\nSession variables: %s", strings.Join(r.varNames(), ", "))
+	}
+
+	if r.edited && r.spec != nil {
+		if encoded, err := json.Marshal(r.spec); err == nil {
+			fmt.Fprintf(&sb, "\n\nThe current request, edited by hand: %s", encoded)
+		}
+	}
+
+	if r.last != nil {
+		fmt.Fprintf(&sb, "\n\nLast response: %s", r.last.Status)
+		if contentType := r.last.Header.Get("Content-Type"); contentType != "" {
+			fmt.Fprintf(&sb, " (%s)", contentType)
+		}
+		if isText(r.last.Header.Get("Content-Type")) && len(r.last.Body) > 0 {
+			body := string(r.last.Body)
+			if len(body) > maxResponseContext {
+				body = body[:maxResponseContext] + "\n[truncated]"
+			}
+			fmt.Fprintf(&sb, "\nLast response body:\n%s", body)
+		}
+	}
+
+	return sb.String()
+}
+
+// send fills in session variables and sends the current request
+func (r *REPL) send(ctx context.Context) error {
+	if r.spec == nil {
+		return ErrNoRequest
+	}
+
+	spec, err := chain.Substitute(*r.spec, r.vars)
+	if err != nil {
+		return fmt.Errorf("%w (set it with :set <name> <value>)", err)
+	}
+
+	fmt.Fprintf(r.out, "%s %s\n", spec.Method, spec.URL)
+	resp, err := r.opts.Execute(ctx, spec)
+	if err != nil {
+		return err
+	}
+
+	r.last = resp
+	r.opts.Print(resp)
+	return nil
+}
+
+// command runs a REPL command and reports whether the session should end
+func (r *REPL) command(ctx context.Context, line string) (bool, error) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":quit", ":q", ":exit":
+		return true, nil
+	case ":help", ":h":
+		r.help()
+		return false, nil
+	case ":spec":
+		return false, r.showSpec()
+	case ":edit":
+		return false, r.edit(ctx)
+	case ":rerun", ":r":
+		sendCtx, cancel := r.withTimeout(ctx)
+		defer cancel()
+		return false, r.send(sendCtx)
+	case ":save":
+		return false, r.save(arg)
+	case ":export":
+		return false, r.export(arg)
+	case ":set":
+		return false, r.set(arg)
+	case ":unset":
+		return false, r.unset(arg)
+	case ":capture":
+		return false, r.capture(arg)
+	case ":reset":
+		if r.opts.Reset != nil {
+			r.opts.Reset()
+		}
+		r.spec, r.edited, r.last = nil, false, nil
+		fmt.Fprintln(r.out, "Conversation cleared; variables and cookies are kept")
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w %s (type :help for a list)", ErrUnknownCommand, name)
+	}
+}
+
+// help lists the REPL commands
+func (r *REPL) help() {
+	fmt.Fprint(r.out, `Type a request in plain English. Follow-ups such as "now for user 7" build on
+the previous request, and can refer to the last response.
+
+Commands:
+  :spec                   Show the current request
+  :edit                   Edit the current request, then send it
+  :rerun, :r              Send the current request again
+  :save <file>            Save the current request as JSON
+  :export [file]          Print the current request as a curl command, or write it to a file
+  :set [name value]       Set a session variable, used as {{name}}; without arguments, list them
+  :unset <name>           Remove a session variable
+  :capture <name> <query> Set a variable from the last JSON response, e.g. :capture id .data.id
+  :reset                  Forget earlier prompts, keeping variables and cookies
+  :help, :h               Show this help
+  :quit, :q               Leave the session
+`)
+}
+
+// showSpec prints the current request as JSON
+func (r *REPL) showSpec() error {
+	if r.spec == nil {
+		return ErrNoRequest
+	}
+	encoded, err := json.MarshalIndent(r.spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, string(encoded))
+	return nil
+}
+
+// edit lets the user change the current request and sends the result
+func (r *REPL) edit(ctx context.Context) error {
+	if r.opts.Edit == nil {
+		return fmt.Errorf("%w :edit (editing is not available)", ErrUnknownCommand)
+	}
+	if r.spec == nil {
+		return ErrNoRequest
+	}
+
+	spec, err := r.opts.Edit(r.spec)
+	if err != nil {
+		return err
+	}
+	r.spec, r.edited = spec, true
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return r.send(ctx)
+}
+
+// save writes the current request to a file as JSON
+func (r *REPL) save(path string) error {
+	if path == "" {
+		return fmt.Errorf("%w: :save <file>", ErrUsage)
+	}
+	if r.spec == nil {
+		return ErrNoRequest
+	}
+
+	encoded, err := json.MarshalIndent(r.spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Requests often carry credentials, so keep the file private
+	if writeErr := os.WriteFile(path, append(encoded, '\n'), 0o600); writeErr != nil {
+		return writeErr
+	}
+	fmt.Fprintf(r.out, "Saved request to %s\n", path)
+	return nil
+}
+
+// export prints the current request as a curl command, or writes it to a
+// file. Session variables are filled in so the command runs as is.
+func (r *REPL) export(path string) error {
+	if r.spec == nil {
+		return ErrNoRequest
+	}
+	spec, err := chain.Substitute(*r.spec, r.vars)
+	if err != nil {
+		return err
+	}
+
+	command := spec.Curl()
+	if path == "" {
+		fmt.Fprintln(r.out, command)
+		return nil
+	}
+	if writeErr := os.WriteFile(path, []byte(command+"\n"), 0o600); writeErr != nil {
+		return writeErr
+	}
+	fmt.Fprintf(r.out, "Exported curl command to %s\n", path)
+	return nil
+}
+
+// set sets a session variable, or lists them without an argument
+func (r *REPL) set(arg string) error {
+	if arg == "" {
+		for _, name := range r.varNames() {
+			fmt.Fprintf(r.out, "%s = %s\n", name, r.vars[name])
+		}
+		return nil
+	}
+
+	name, value, ok := strings.Cut(arg, " ")
+	if !ok {
+		return fmt.Errorf("%w: :set <name> <value>", ErrUsage)
+	}
+	if err := checkName(name); err != nil {
+		return err
+	}
+	r.vars[name] = strings.TrimSpace(value)
+	return nil
+}
+
+// unset removes a session variable
+func (r *REPL) unset(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: :unset <name>", ErrUsage)
+	}
+	delete(r.vars, name)
+	return nil
+}
+
+// capture sets a session variable from the last response
+func (r *REPL) capture(arg string) error {
+	name, path, ok := strings.Cut(arg, " ")
+	if !ok {
+		return fmt.Errorf("%w: :capture <name> <query>", ErrUsage)
+	}
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if r.last == nil {
+		return ErrNoResponse
+	}
+
+	c := chain.Capture{Name: name, From: chain.FromJSON, Path: strings.TrimSpace(path)}
+	value, err := c.Extract(r.last)
+	if err != nil {
+		return err
+	}
+	r.vars[name] = value
+	fmt.Fprintf(r.out, "%s = %s\n", name, value)
+	return nil
+}
+
+// checkName checks that a variable name works as a {{name}} placeholder
+func checkName(name string) error {
+	if !chain.IsValidName(name) {
+		return fmt.Errorf("%w: invalid variable name %q (use letters, digits and _)", ErrUsage, name)
+	}
+	return nil
+}
+
+// varNames returns the session variable names in sorted order
+func (r *REPL) varNames() []string {
+	names := make([]string, 0, len(r.vars))
+	for name := range r.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withTimeout applies the per-prompt timeout, if one is set
+func (r *REPL) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.opts.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.opts.Timeout)
+}
+
+// isText reports whether a content type is worth showing to the model
+func isText(contentType string) bool {
+	return strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "text/") ||
+		strings.Contains(contentType, "xml")
+}
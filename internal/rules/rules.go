@@ -0,0 +1,306 @@
+// Package rules translates simple, fully specified prompts such as
+// "get https://httpbin.org/get" into requests without calling a model
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+)
+
+// Translation modes
+const (
+	ModeAuto  = "auto"  // Translate simple prompts locally and ask the model otherwise
+	ModeModel = "model" // Always ask the model
+	ModeLocal = "local" // Never ask the model
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrInvalidMode = errors.New("invalid translation mode")
+	ErrNoMatch     = errors.New("prompt is not simple enough to translate without a model")
+)
+
+// methods are the words accepted as the request method
+var methods = map[string]string{
+	"get":     http.MethodGet,
+	"post":    http.MethodPost,
+	"put":     http.MethodPut,
+	"patch":   http.MethodPatch,
+	"delete":  http.MethodDelete,
+	"head":    http.MethodHead,
+	"options": http.MethodOptions,
+}
+
+// fillers are words that may join the parts of a prompt without changing
+// its meaning
+var fillers = map[string]bool{
+	"a": true, "an": true, "the": true, "request": true, "send": true,
+	"to": true, "at": true, "from": true, "on": true, "with": true, "and": true,
+	"header": true, "headers": true, "body": true, "json": true, "data": true, "payload": true,
+}
+
+// valueEnds are words that end a header value
+var valueEnds = map[string]bool{"and": true, "with": true, "to": true}
+
+// headerName matches a header name followed by a colon, e.g. "X-Token:"
+var headerName = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_-]*):$`)
+
+// hostPattern matches a URL without a scheme, e.g. localhost:8080/users
+var hostPattern = regexp.MustCompile(`^([A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*)(:\d+)?(?:[/?#].*)?$`)
+
+// ValidateMode checks that a translation mode name is supported
+func ValidateMode(mode string) error {
+	switch mode {
+	case ModeAuto, ModeModel, ModeLocal:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q (use auto, model or local)", ErrInvalidMode, mode)
+	}
+}
+
+// token is one word, quoted string or JSON value of a prompt
+type token struct {
+	text   string
+	quoted bool // Text was in quotes, which have been removed
+	json   bool // Text is a JSON object or array
+}
+
+// Parse translates a prompt made only of a method, one URL, headers
+// written as Name: value and a JSON or quoted body, joined by filler words
+// like "to" and "with". Anything else returns ErrNoMatch, so the prompt can
+// go to the model instead.
+//
+// The method defaults to GET, or POST when there is a body. URLs without a
+// scheme use https, except for localhost and IP addresses, which use http.
+// A JSON body gets a Content-Type of application/json unless one is given.
+func Parse(prompt string) (*httpx.RequestSpec, error) {
+	tokens, err := tokenize(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &httpx.RequestSpec{Headers: make(map[string]string)}
+	hasBody, jsonBody := false, false
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		lower := strings.ToLower(tok.text)
+
+		switch {
+		case tok.json:
+			if hasBody {
+				return nil, fmt.Errorf("%w: more than one body", ErrNoMatch)
+			}
+			spec.Body, hasBody, jsonBody = tok.text, true, true
+
+		case tok.quoted:
+			if name, value, ok := splitHeader(tok.text); ok {
+				spec.Headers[name] = value
+				continue
+			}
+			if hasBody {
+				return nil, fmt.Errorf("%w: more than one body", ErrNoMatch)
+			}
+			spec.Body, hasBody, jsonBody = tok.text, true, isJSON(tok.text)
+
+		case headerName.MatchString(tok.text):
+			// The value runs until a joining word or the next part of the request
+			var value []string
+			for i+1 < len(tokens) && !endsValue(tokens[i+1]) {
+				i++
+				value = append(value, tokens[i].text)
+			}
+			if len(value) == 0 {
+				return nil, fmt.Errorf("%w: header %s has no value", ErrNoMatch, tok.text)
+			}
+			name := strings.TrimSuffix(tok.text, ":")
+			spec.Headers[name] = strings.TrimSuffix(strings.Join(value, " "), ",")
+
+		case methods[lower] != "" && spec.Method == "" && spec.URL == "":
+			spec.Method = methods[lower]
+
+		case isURL(tok.text):
+			if spec.URL != "" {
+				return nil, fmt.Errorf("%w: more than one URL", ErrNoMatch)
+			}
+			spec.URL = normalizeURL(tok.text)
+
+		case fillers[lower]:
+			// Joins the parts of the request
+
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q", ErrNoMatch, tok.text)
+		}
+	}
+
+	if spec.URL == "" {
+		return nil, fmt.Errorf("%w: no URL", ErrNoMatch)
+	}
+	if spec.Method == "" {
+		spec.Method = http.MethodGet
+		if hasBody {
+			spec.Method = http.MethodPost
+		}
+	}
+	if jsonBody && !hasHeader(spec.Headers, "Content-Type") {
+		spec.Headers["Content-Type"] = "application/json"
+	}
+
+	if validateErr := spec.Validate(); validateErr != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoMatch, validateErr)
+	}
+	return spec, nil
+}
+
+// tokenize splits a prompt into words, quoted strings and JSON values
+func tokenize(prompt string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(prompt); {
+		switch c := prompt[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '{' || c == '[':
+			end := matchBracket(prompt, i)
+			if end < 0 || !isJSON(prompt[i:end]) {
+				return nil, fmt.Errorf("%w: body is not valid JSON", ErrNoMatch)
+			}
+			tokens = append(tokens, token{text: prompt[i:end], json: true})
+			i = end
+
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(prompt[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrNoMatch)
+			}
+			tokens = append(tokens, token{text: prompt[i+1 : i+1+end], quoted: true})
+			i += end + 2
+
+		default:
+			end := strings.IndexAny(prompt[i:], " \t\r\n")
+			if end < 0 {
+				end = len(prompt) - i
+			}
+			word := strings.TrimRight(prompt[i:i+end], ",;")
+			if word != "" {
+				tokens = append(tokens, token{text: word})
+			}
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// isJSON reports whether text is a JSON object or array
+func isJSON(text string) bool {
+	text = strings.TrimSpace(text)
+	return (strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[")) && json.Valid([]byte(text))
+}
+
+// matchBracket returns the index just past the bracket that closes the one
+// at start, skipping brackets inside JSON strings, or -1 if there is none
+func matchBracket(s string, start int) int {
+	depth, inString, escaped := 0, false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// endsValue reports whether a token ends a header value
+func endsValue(tok token) bool {
+	if tok.quoted || tok.json {
+		return true
+	}
+	return valueEnds[strings.ToLower(tok.text)] || headerName.MatchString(tok.text) || isURL(tok.text)
+}
+
+// splitHeader splits quoted text like "X-Token: abc" into a header
+func splitHeader(text string) (string, string, bool) {
+	name, value, ok := strings.Cut(text, ":")
+	if !ok || !headerName.MatchString(name+":") {
+		return "", "", false
+	}
+	value = strings.TrimSpace(value)
+	return name, value, value != ""
+}
+
+// hasHeader reports whether headers contain name, ignoring case
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isURL reports whether a word is an http(s) URL or a host with an optional
+// port and path
+func isURL(word string) bool {
+	lower := strings.ToLower(word)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		u, err := url.Parse(word)
+		return err == nil && u.Host != ""
+	}
+
+	m := hostPattern.FindStringSubmatch(word)
+	if m == nil {
+		return false
+	}
+	host := m[1]
+	if strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil {
+		return true
+	}
+
+	// Require a dotted name ending in a letter, so "1.5" and "v2" aren't hosts
+	labels := strings.Split(host, ".")
+	tld := labels[len(labels)-1]
+	return len(labels) > 1 && tld != "" && strings.ContainsAny(strings.ToLower(tld), "abcdefghijklmnopqrstuvwxyz")
+}
+
+// defaultLocalPort is the port the model uses for localhost without one
+const defaultLocalPort = ":3000"
+
+// normalizeURL adds a scheme to a URL without one. Like the model, it
+// assumes port 3000 for localhost without a port.
+func normalizeURL(word string) string {
+	lower := strings.ToLower(word)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		return word
+	}
+	m := hostPattern.FindStringSubmatch(word)
+	host, port := m[1], m[2]
+	switch {
+	case strings.EqualFold(host, "localhost") && port == "":
+		return "http://" + host + defaultLocalPort + word[len(host):]
+	case strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil:
+		return "http://" + word
+	default:
+		return "https://" + word
+	}
+}
@@ -0,0 +1,117 @@
+package rules_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stephenbyrne99/ncurl/internal/httpx"
+	"github.com/stephenbyrne99/ncurl/internal/rules"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name   string
+		prompt string
+		want   httpx.RequestSpec
+	}{
+		{
+			name:   "Method and URL",
+			prompt: "get https://httpbin.org/get",
+			want:   httpx.RequestSpec{Method: "GET", URL: "https://httpbin.org/get", Headers: map[string]string{}},
+		},
+		{
+			name:   "URL only",
+			prompt: "httpbin.org/get?page=2",
+			want:   httpx.RequestSpec{Method: "GET", URL: "https://httpbin.org/get?page=2", Headers: map[string]string{}},
+		},
+		{
+			name:   "Local host without scheme",
+			prompt: "DELETE localhost:8080/users/7",
+			want:   httpx.RequestSpec{Method: "DELETE", URL: "http://localhost:8080/users/7", Headers: map[string]string{}},
+		},
+		{
+			name:   "Local host without a port",
+			prompt: "get data from localhost",
+			want:   httpx.RequestSpec{Method: "GET", URL: "http://localhost:3000", Headers: map[string]string{}},
+		},
+		{
+			name:   "Local host path without a port",
+			prompt: "delete localhost/users/7",
+			want:   httpx.RequestSpec{Method: "DELETE", URL: "http://localhost:3000/users/7", Headers: map[string]string{}},
+		},
+		{
+			name:   "JSON body and header",
+			prompt: `post {"name": "Ada", "tags": ["a}"]} to https://api.test/users with header X-Token: abc 123`,
+			want: httpx.RequestSpec{
+				Method:  "POST",
+				URL:     "https://api.test/users",
+				Headers: map[string]string{"Content-Type": "application/json", "X-Token": "abc 123"},
+				Body:    `{"name": "Ada", "tags": ["a}"]}`,
+			},
+		},
+		{
+			name: "Several headers and a quoted body",
+			prompt: "send a put request to 127.0.0.1:9000/notes " +
+				`with headers 'Content-Type: text/plain' and Accept: */* and body "hi there"`,
+			want: httpx.RequestSpec{
+				Method:  "PUT",
+				URL:     "http://127.0.0.1:9000/notes",
+				Headers: map[string]string{"Content-Type": "text/plain", "Accept": "*/*"},
+				Body:    "hi there",
+			},
+		},
+		{
+			name:   "Body without method",
+			prompt: `{"ok": true} to https://api.test/events`,
+			want: httpx.RequestSpec{
+				Method:  "POST",
+				URL:     "https://api.test/events",
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    `{"ok": true}`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := rules.Parse(tc.prompt)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("Unexpected request:\nwant %+v\ngot  %+v", tc.want, *got)
+			}
+		})
+	}
+}
+
+func TestParseNoMatch(t *testing.T) {
+	prompts := []string{
+		"get the latest weather for London",
+		"list users on localhost:8080",
+		"get https://a.test/x and https://b.test/y",
+		`post {"a": 1 to https://api.test`,
+		"post @orders.json to localhost:8080/orders",
+		"get https://api.test with header X-Token:",
+		"get version 1.5",
+		"",
+	}
+
+	for _, prompt := range prompts {
+		if spec, err := rules.Parse(prompt); !errors.Is(err, rules.ErrNoMatch) {
+			t.Errorf("Expected ErrNoMatch for %q, got %+v, %v", prompt, spec, err)
+		}
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+	for _, mode := range []string{rules.ModeAuto, rules.ModeModel, rules.ModeLocal} {
+		if err := rules.ValidateMode(mode); err != nil {
+			t.Errorf("Expected %q to be valid: %v", mode, err)
+		}
+	}
+	if err := rules.ValidateMode("regex"); !errors.Is(err, rules.ErrInvalidMode) {
+		t.Errorf("Expected ErrInvalidMode, got %v", err)
+	}
+}
@@ -0,0 +1,524 @@
+// Package session provides persistent cookie jars for named ncurl sessions
+package session
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Common errors that can be returned by this package
+var (
+	ErrInvalidName   = errors.New("invalid session name")
+	ErrInvalidCookie = errors.New("invalid cookie file entry")
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in the Netscape cookie file format
+const httpOnlyPrefix = "#HttpOnly_"
+
+// netscapeFields is the number of tab separated fields in a cookie file line
+const netscapeFields = 7
+
+// validName restricts session names to characters that are safe in file names
+var validName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// entry is a single cookie stored in a Jar
+type entry struct {
+	Name     string
+	Value    string
+	Domain   string // Without a leading dot
+	HostOnly bool   // Only sent to Domain itself, not its subdomains
+	Path     string
+	Secure   bool
+	HTTPOnly bool
+	Expires  time.Time // Zero for session cookies
+}
+
+// key identifies a cookie within the jar
+func (e *entry) key() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+// expired reports whether the cookie has expired at the given time
+func (e *entry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !e.Expires.After(now)
+}
+
+// Jar is an http.CookieJar whose contents can be saved and restored.
+// It is safe for concurrent use.
+type Jar struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// NewJar creates an empty cookie jar
+func NewJar() *Jar {
+	return &Jar{entries: make(map[string]*entry)}
+}
+
+// SetCookies implements http.CookieJar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := canonicalHost(u.Hostname())
+	if host == "" {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, c := range cookies {
+		e := &entry{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HttpOnly,
+		}
+
+		var ok bool
+		if e.Domain, e.HostOnly, ok = cookieDomain(host, c.Domain); !ok {
+			// Servers may not set cookies for unrelated domains
+			continue
+		}
+
+		if e.Path == "" || !strings.HasPrefix(e.Path, "/") {
+			e.Path = defaultPath(u.Path)
+		}
+
+		switch {
+		case c.MaxAge < 0:
+			delete(j.entries, e.key())
+			continue
+		case c.MaxAge > 0:
+			e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			e.Expires = c.Expires
+		}
+
+		if e.expired(now) {
+			delete(j.entries, e.key())
+			continue
+		}
+
+		j.entries[e.key()] = e
+	}
+}
+
+// Cookies implements http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	host := canonicalHost(u.Hostname())
+	if host == "" {
+		return nil
+	}
+
+	requestPath := u.Path
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	secure := u.Scheme == "https"
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	var matched []*entry
+	for k, e := range j.entries {
+		if e.expired(now) {
+			delete(j.entries, k)
+			continue
+		}
+		if e.HostOnly && host != e.Domain {
+			continue
+		}
+		if !e.HostOnly && !domainMatch(host, e.Domain) {
+			continue
+		}
+		if !pathMatch(requestPath, e.Path) {
+			continue
+		}
+		if e.Secure && !secure {
+			continue
+		}
+		matched = append(matched, e)
+	}
+
+	// More specific paths are sent first, as recommended by RFC 6265
+	sort.Slice(matched, func(a, b int) bool {
+		if len(matched[a].Path) != len(matched[b].Path) {
+			return len(matched[a].Path) > len(matched[b].Path)
+		}
+		return matched[a].Name < matched[b].Name
+	})
+
+	cookies := make([]*http.Cookie, 0, len(matched))
+	for _, e := range matched {
+		cookies = append(cookies, &http.Cookie{Name: e.Name, Value: e.Value})
+	}
+	return cookies
+}
+
+// Len returns the number of unexpired cookies in the jar
+func (j *Jar) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	count := 0
+	for _, e := range j.entries {
+		if !e.expired(now) {
+			count++
+		}
+	}
+	return count
+}
+
+// sorted returns a stable snapshot of the unexpired jar entries
+func (j *Jar) sorted() []entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]entry, 0, len(j.entries))
+	for _, e := range j.entries {
+		if !e.expired(now) {
+			entries = append(entries, *e)
+		}
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].key() < entries[b].key()
+	})
+	return entries
+}
+
+// ReadFrom loads cookies in Netscape cookie file format into the jar
+func (j *Jar) ReadFrom(r io.Reader) (int64, error) {
+	read, _, err := j.readCookies(r)
+	return read, err
+}
+
+// readCookies loads cookies in Netscape cookie file format into the jar,
+// returning the bytes read and the number of cookies stored, including
+// those that replaced a cookie already in the jar
+func (j *Jar) readCookies(r io.Reader) (int64, int, error) {
+	scanner := bufio.NewScanner(r)
+	var read int64
+	stored := 0
+	lineNum := 0
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for scanner.Scan() {
+		line := scanner.Text()
+		read += int64(len(line)) + 1
+		lineNum++
+
+		e, ok, parseErr := parseNetscapeLine(line)
+		if parseErr != nil {
+			return read, stored, fmt.Errorf("line %d: %w", lineNum, parseErr)
+		}
+		if !ok || e.expired(now) {
+			continue
+		}
+		j.entries[e.key()] = e
+		stored++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return read, stored, fmt.Errorf("failed to read cookie file: %w", err)
+	}
+
+	return read, stored, nil
+}
+
+// WriteTo writes the jar contents in Netscape cookie file format
+func (j *Jar) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	sb.WriteString("# Netscape HTTP Cookie File\n")
+	sb.WriteString("# Written by ncurl. Edit at your own risk.\n\n")
+
+	for _, e := range j.sorted() {
+		domain := e.Domain
+		if e.HTTPOnly {
+			domain = httpOnlyPrefix + domain
+		}
+
+		includeSubdomains := "FALSE"
+		if !e.HostOnly {
+			includeSubdomains = "TRUE"
+		}
+
+		secure := "FALSE"
+		if e.Secure {
+			secure = "TRUE"
+		}
+
+		var expires int64
+		if !e.Expires.IsZero() {
+			expires = e.Expires.Unix()
+		}
+
+		sb.WriteString(strings.Join([]string{
+			domain,
+			includeSubdomains,
+			e.Path,
+			secure,
+			strconv.FormatInt(expires, 10),
+			e.Name,
+			e.Value,
+		}, "\t"))
+		sb.WriteString("\n")
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// parseNetscapeLine parses a single Netscape cookie file line.
+// It returns ok=false for blank lines and comments.
+func parseNetscapeLine(line string) (*entry, bool, error) {
+	line = strings.TrimRight(line, "\r")
+	httpOnly := false
+	if strings.HasPrefix(line, httpOnlyPrefix) {
+		httpOnly = true
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+	}
+
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+		return nil, false, nil
+	}
+
+	fields := strings.Split(line, "\t")
+	if len(fields) != netscapeFields {
+		return nil, false, fmt.Errorf("%w: expected %d fields, got %d", ErrInvalidCookie, netscapeFields, len(fields))
+	}
+
+	expires, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: bad expiry %q", ErrInvalidCookie, fields[4])
+	}
+
+	domain := fields[0]
+	e := &entry{
+		Name:     fields[5],
+		Value:    fields[6],
+		Domain:   canonicalHost(strings.TrimPrefix(domain, ".")),
+		HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+		Path:     fields[2],
+		Secure:   strings.EqualFold(fields[3], "TRUE"),
+		HTTPOnly: httpOnly,
+	}
+	if expires > 0 {
+		e.Expires = time.Unix(expires, 0)
+	}
+	if e.Path == "" {
+		e.Path = "/"
+	}
+
+	return e, true, nil
+}
+
+// canonicalHost lower-cases a host name and strips any trailing dot
+func canonicalHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// cookieDomain returns the domain a cookie set by host applies to, and
+// whether it is only sent to that host. ok is false when host may not set
+// cookies for domain. Like net/http/cookiejar, a Domain attribute that is
+// a public suffix such as "com" or "co.uk", or that names an IP address,
+// only yields a host-only cookie for that exact host.
+func cookieDomain(host, domain string) (cookie string, hostOnly, ok bool) {
+	if domain == "" {
+		return host, true, true
+	}
+	domain = canonicalHost(strings.TrimPrefix(domain, "."))
+
+	if net.ParseIP(host) != nil {
+		return host, true, domain == host
+	}
+	if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+		return host, true, domain == host
+	}
+	if !domainMatch(host, domain) {
+		return "", false, false
+	}
+	return domain, false, true
+}
+
+// domainMatch reports whether host is domain or one of its subdomains
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch implements the RFC 6265 path-match algorithm
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultPath computes the default cookie path for a request path
+func defaultPath(requestPath string) string {
+	if requestPath == "" || requestPath[0] != '/' {
+		return "/"
+	}
+	dir := path.Dir(requestPath)
+	if dir == "." {
+		return "/"
+	}
+	return dir
+}
+
+// Manager handles loading and saving named sessions
+type Manager struct {
+	dir string
+}
+
+// NewTestManager creates a manager for testing purposes
+func NewTestManager(dir string) *Manager {
+	return &Manager{dir: dir}
+}
+
+// NewManager creates a session manager rooted in ~/.ncurl/sessions
+func NewManager() (*Manager, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	return &Manager{dir: filepath.Join(home, ".ncurl", "sessions")}, nil
+}
+
+// path returns the cookie file path for a session name
+func (m *Manager) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("%w: %q (use letters, digits, '.', '_' or '-')", ErrInvalidName, name)
+	}
+	return filepath.Join(m.dir, name+".cookies"), nil
+}
+
+// Load returns the cookie jar for a session, or an empty jar if the session is new
+func (m *Manager) Load(name string) (*Jar, error) {
+	sessionPath, err := m.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	jar := NewJar()
+	if _, statErr := os.Stat(sessionPath); os.IsNotExist(statErr) {
+		return jar, nil
+	}
+
+	if _, importErr := importFile(jar, sessionPath); importErr != nil {
+		return nil, fmt.Errorf("failed to load session %s: %w", name, importErr)
+	}
+
+	return jar, nil
+}
+
+// Save persists a session's cookie jar
+func (m *Manager) Save(name string, jar *Jar) error {
+	sessionPath, err := m.path(name)
+	if err != nil {
+		return err
+	}
+
+	if mkdirErr := os.MkdirAll(m.dir, 0o700); mkdirErr != nil {
+		return fmt.Errorf("failed to create session directory: %w", mkdirErr)
+	}
+
+	if exportErr := exportFile(jar, sessionPath); exportErr != nil {
+		return fmt.Errorf("failed to save session %s: %w", name, exportErr)
+	}
+
+	return nil
+}
+
+// Import merges cookies from a Netscape cookie file into a session and
+// returns the number of cookies imported, including ones it replaced
+func (m *Manager) Import(name, cookieFile string) (int, error) {
+	jar, err := m.Load(name)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := importFile(jar, cookieFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to import %s: %w", cookieFile, err)
+	}
+
+	if saveErr := m.Save(name, jar); saveErr != nil {
+		return 0, saveErr
+	}
+
+	return count, nil
+}
+
+// Export writes a session's cookies to a Netscape cookie file
+func (m *Manager) Export(name, cookieFile string) (int, error) {
+	jar, err := m.Load(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if exportErr := exportFile(jar, cookieFile); exportErr != nil {
+		return 0, fmt.Errorf("failed to export to %s: %w", cookieFile, exportErr)
+	}
+
+	return jar.Len(), nil
+}
+
+// importFile reads a Netscape cookie file into a jar and returns the
+// number of cookies it stored
+func importFile(jar *Jar, cookieFile string) (int, error) {
+	file, err := os.Open(filepath.Clean(cookieFile))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open cookie file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	_, stored, err := jar.readCookies(file)
+	return stored, err
+}
+
+// exportFile writes a jar to a Netscape cookie file with private permissions
+func exportFile(jar *Jar, cookieFile string) error {
+	file, err := os.OpenFile(filepath.Clean(cookieFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create cookie file: %w", err)
+	}
+
+	if _, writeErr := jar.WriteTo(file); writeErr != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write cookie file: %w", writeErr)
+	}
+
+	if closeErr := file.Close(); closeErr != nil {
+		return fmt.Errorf("failed to close cookie file: %w", closeErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package session_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stephenbyrne99/ncurl/internal/session"
+)
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse URL %s: %v", raw, err)
+	}
+	return u
+}
+
+func TestJarDomainAndPathMatching(t *testing.T) {
+	jar := session.NewJar()
+
+	jar.SetCookies(mustParseURL(t, "https://api.internal.test/auth/login"), []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".internal.test", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true},
+		{Name: "foreign", Value: "4", Domain: "other.test"},
+	})
+
+	testCases := []struct {
+		name string
+		url  string
+		want []string
+	}{
+		{
+			name: "Same host and path",
+			url:  "https://api.internal.test/auth/me",
+			want: []string{"host", "domain", "secure"},
+		},
+		{
+			name: "Different path only gets root cookies",
+			url:  "https://api.internal.test/orders",
+			want: []string{"domain", "secure"},
+		},
+		{
+			name: "Sibling subdomain gets domain cookie",
+			url:  "https://admin.internal.test/",
+			want: []string{"domain"},
+		},
+		{
+			name: "Plain HTTP skips secure cookies",
+			url:  "http://api.internal.test/auth/me",
+			want: []string{"host", "domain"},
+		},
+		{
+			name: "Unrelated host",
+			url:  "https://other.test/",
+			want: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cookies := jar.Cookies(mustParseURL(t, tc.url))
+			got := make(map[string]bool)
+			for _, c := range cookies {
+				got[c.Name] = true
+			}
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("Expected cookies %v, got %v", tc.want, cookies)
+			}
+			for _, name := range tc.want {
+				if !got[name] {
+					t.Errorf("Expected cookie %s to be sent to %s", name, tc.url)
+				}
+			}
+		})
+	}
+}
+
+func TestJarRejectsPublicSuffixDomains(t *testing.T) {
+	jar := session.NewJar()
+
+	jar.SetCookies(mustParseURL(t, "https://evil.example.com/"), []*http.Cookie{
+		{Name: "tld", Value: "1", Domain: "com"},
+		{Name: "suffix", Value: "2", Domain: ".co.uk"},
+	})
+	jar.SetCookies(mustParseURL(t, "https://shop.example.co.uk/"), []*http.Cookie{
+		{Name: "suffix", Value: "3", Domain: "co.uk"},
+	})
+	jar.SetCookies(mustParseURL(t, "http://10.0.0.1/"), []*http.Cookie{
+		{Name: "ip", Value: "4", Domain: "0.0.1"},
+		{Name: "same-ip", Value: "5", Domain: "10.0.0.1"},
+	})
+	jar.SetCookies(mustParseURL(t, "http://localhost:3000/"), []*http.Cookie{
+		{Name: "local", Value: "6", Domain: "localhost"},
+	})
+
+	for _, rawURL := range []string{"https://bank.com/", "https://other.co.uk/", "https://shop.example.co.uk/",
+		"http://20.0.0.1/", "http://api.localhost/"} {
+		if cookies := jar.Cookies(mustParseURL(t, rawURL)); len(cookies) != 0 {
+			t.Errorf("Expected no cookies for %s, got %v", rawURL, cookies)
+		}
+	}
+
+	// A Domain naming the host itself still sets a host-only cookie
+	if cookies := jar.Cookies(mustParseURL(t, "http://10.0.0.1/")); len(cookies) != 1 || cookies[0].Name != "same-ip" {
+		t.Errorf("Expected the host-only IP cookie, got %v", cookies)
+	}
+	if cookies := jar.Cookies(mustParseURL(t, "http://localhost:8080/")); len(cookies) != 1 || cookies[0].Name != "local" {
+		t.Errorf("Expected the host-only localhost cookie, got %v", cookies)
+	}
+}
+
+func TestJarDeletesExpiredCookies(t *testing.T) {
+	jar := session.NewJar()
+	u := mustParseURL(t, "https://example.test/")
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "token", Value: "abc"}})
+	if jar.Len() != 1 {
+		t.Fatalf("Expected 1 cookie, got %d", jar.Len())
+	}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "token", MaxAge: -1}})
+	if jar.Len() != 0 {
+		t.Errorf("Expected cookie to be deleted, got %d cookies", jar.Len())
+	}
+}
+
+func TestNetscapeRoundTrip(t *testing.T) {
+	cookieFile := strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"",
+		".internal.test\tTRUE\t/\tTRUE\t4102444800\tsid\tabc123",
+		"#HttpOnly_api.internal.test\tFALSE\t/auth\tFALSE\t0\tcsrf\txyz",
+		"old.test\tFALSE\t/\tFALSE\t1\texpired\tgone",
+	}, "\n")
+
+	jar := session.NewJar()
+	if _, err := jar.ReadFrom(strings.NewReader(cookieFile)); err != nil {
+		t.Fatalf("Failed to read cookie file: %v", err)
+	}
+
+	if jar.Len() != 2 {
+		t.Fatalf("Expected 2 unexpired cookies, got %d", jar.Len())
+	}
+
+	cookies := jar.Cookies(mustParseURL(t, "https://api.internal.test/auth/me"))
+	if len(cookies) != 2 {
+		t.Errorf("Expected both cookies for api.internal.test, got %v", cookies)
+	}
+
+	var buf bytes.Buffer
+	if _, err := jar.WriteTo(&buf); err != nil {
+		t.Fatalf("Failed to write cookie file: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "internal.test\tTRUE\t/\tTRUE\t4102444800\tsid\tabc123") {
+		t.Errorf("Expected domain cookie in output, got:\n%s", out)
+	}
+	if !strings.Contains(out, "#HttpOnly_api.internal.test\tFALSE\t/auth\tFALSE\t0\tcsrf\txyz") {
+		t.Errorf("Expected HttpOnly session cookie in output, got:\n%s", out)
+	}
+
+	// Malformed lines are reported
+	_, err := session.NewJar().ReadFrom(strings.NewReader("bad line"))
+	if !errors.Is(err, session.ErrInvalidCookie) {
+		t.Errorf("Expected ErrInvalidCookie, got %v", err)
+	}
+}
+
+func TestManagerPersistence(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := session.NewTestManager(filepath.Join(tempDir, "sessions"))
+
+	// Loading an unknown session gives an empty jar
+	jar, err := manager.Load("admin")
+	if err != nil {
+		t.Fatalf("Failed to load new session: %v", err)
+	}
+	if jar.Len() != 0 {
+		t.Fatalf("Expected empty jar, got %d cookies", jar.Len())
+	}
+
+	jar.SetCookies(mustParseURL(t, "http://localhost:8080/login"), []*http.Cookie{{Name: "sid", Value: "s3cret"}})
+	if saveErr := manager.Save("admin", jar); saveErr != nil {
+		t.Fatalf("Failed to save session: %v", saveErr)
+	}
+
+	reloaded, err := manager.Load("admin")
+	if err != nil {
+		t.Fatalf("Failed to reload session: %v", err)
+	}
+	cookies := reloaded.Cookies(mustParseURL(t, "http://localhost:8080/users"))
+	if len(cookies) != 1 || cookies[0].Value != "s3cret" {
+		t.Errorf("Expected persisted sid cookie, got %v", cookies)
+	}
+
+	// Export and import into a second session
+	exported := filepath.Join(tempDir, "cookies.txt")
+	if _, exportErr := manager.Export("admin", exported); exportErr != nil {
+		t.Fatalf("Failed to export session: %v", exportErr)
+	}
+	if _, statErr := os.Stat(exported); statErr != nil {
+		t.Fatalf("Expected exported cookie file: %v", statErr)
+	}
+
+	count, err := manager.Import("copy", exported)
+	if err != nil {
+		t.Fatalf("Failed to import session: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 imported cookie, got %d", count)
+	}
+
+	// Cookies that replace ones already in the session still count
+	if count, err = manager.Import("copy", exported); err != nil || count != 1 {
+		t.Errorf("Expected re-importing to report 1 cookie, got %d, %v", count, err)
+	}
+
+	// Names that could escape the session directory are rejected
+	if _, loadErr := manager.Load("../evil"); !errors.Is(loadErr, session.ErrInvalidName) {
+		t.Errorf("Expected ErrInvalidName, got %v", loadErr)
+	}
+}
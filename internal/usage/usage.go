@@ -0,0 +1,256 @@
+// Package usage tracks model token usage and estimates what it costs
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary periods
+const (
+	PeriodDay  = "day"
+	PeriodWeek = "week"
+)
+
+// tokensPerPrice is the number of tokens a Price is quoted for
+const tokensPerPrice = 1_000_000
+
+// Common errors that can be returned by this package
+var (
+	ErrInvalidPrices = errors.New("invalid price table")
+	ErrInvalidPeriod = errors.New("invalid period")
+)
+
+// Tokens counts the tokens used by one or more model calls
+type Tokens struct {
+	Model  string `json:"model"`
+	Input  int64  `json:"input_tokens"`
+	Output int64  `json:"output_tokens"`
+}
+
+// Price is what a model charges, in US dollars per million tokens
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Prices maps model names to prices. A key also matches any model that
+// starts with it, so "claude-3-7-sonnet" covers "claude-3-7-sonnet-latest"
+// and dated versions; the longest matching key wins.
+type Prices map[string]Price
+
+// DefaultPrices are Anthropic's published list prices
+var DefaultPrices = Prices{
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+	"claude-3-opus":     {Input: 15, Output: 75},
+	"claude-3-sonnet":   {Input: 3, Output: 15},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+}
+
+// LoadPrices returns the default prices overridden by those in a JSON file
+// mapping model names to {"input": ..., "output": ...}. A missing file is
+// not an error.
+func LoadPrices(path string) (Prices, error) {
+	prices := make(Prices, len(DefaultPrices))
+	for model, price := range DefaultPrices {
+		prices[model] = price
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return prices, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price table: %w", err)
+	}
+
+	var custom Prices
+	if unmarshalErr := json.Unmarshal(data, &custom); unmarshalErr != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidPrices, path, unmarshalErr)
+	}
+	for model, price := range custom {
+		if price.Input < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("%w: %s: negative price for %s", ErrInvalidPrices, path, model)
+		}
+		prices[model] = price
+	}
+
+	return prices, nil
+}
+
+// Lookup returns the price of a model
+func (p Prices) Lookup(model string) (Price, bool) {
+	best := ""
+	for key := range p {
+		if strings.HasPrefix(model, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return p[best], true
+}
+
+// Cost estimates what tokens cost, reporting false for unknown models
+func (p Prices) Cost(t Tokens) (float64, bool) {
+	price, ok := p.Lookup(t.Model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(t.Input)*price.Input + float64(t.Output)*price.Output) / tokensPerPrice, true
+}
+
+// Record is one model call in the usage log
+type Record struct {
+	Time    time.Time `json:"time"`
+	Purpose string    `json:"purpose,omitempty"` // What the call was for, e.g. translate or extract
+	Tokens
+	Cost *float64 `json:"cost,omitempty"` // Estimated in US dollars; nil for unknown models
+}
+
+// Log appends records to a JSON lines file
+type Log struct {
+	path string
+}
+
+// NewTestLog creates a log at path for testing purposes
+func NewTestLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// NewLog opens the log at ~/.ncurl/usage.jsonl
+func NewLog() (*Log, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	configDir := filepath.Join(home, ".ncurl")
+	if mkdirErr := os.MkdirAll(configDir, 0o750); mkdirErr != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", mkdirErr)
+	}
+
+	return &Log{path: filepath.Join(configDir, "usage.jsonl")}, nil
+}
+
+// Append adds records to the log
+func (l *Log) Append(records ...Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode usage: %w", err)
+		}
+		sb.Write(line)
+		sb.WriteByte('\n')
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage log: %w", err)
+	}
+	_, writeErr := file.WriteString(sb.String())
+	closeErr := file.Close()
+	if writeErr != nil || closeErr != nil {
+		return fmt.Errorf("failed to write usage log: %w", errors.Join(writeErr, closeErr))
+	}
+	return nil
+}
+
+// Records reads every record in the log. Lines that can't be parsed are
+// skipped, so a partly written line never hides the rest of the log.
+func (l *Log) Records() ([]Record, error) {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r Record
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			records = append(records, r)
+		}
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, fmt.Errorf("failed to read usage log: %w", scanErr)
+	}
+	return records, nil
+}
+
+// Total sums the usage of one model over one period
+type Total struct {
+	Period  string // The day (2006-01-02) or ISO week (2006-W01)
+	Model   string
+	Calls   int
+	Input   int64
+	Output  int64
+	Cost    float64
+	Unknown int // Calls whose cost couldn't be estimated
+}
+
+// Summarize totals records by period and model, newest period first
+func Summarize(records []Record, period string) ([]Total, error) {
+	var label func(t time.Time) string
+	switch period {
+	case PeriodDay:
+		label = func(t time.Time) string { return t.Local().Format("2006-01-02") }
+	case PeriodWeek:
+		label = func(t time.Time) string {
+			year, week := t.Local().ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q (use day or week)", ErrInvalidPeriod, period)
+	}
+
+	type group struct{ period, model string }
+	totals := make(map[group]*Total)
+	for _, r := range records {
+		g := group{label(r.Time), r.Model}
+		t, ok := totals[g]
+		if !ok {
+			t = &Total{Period: g.period, Model: g.model}
+			totals[g] = t
+		}
+		t.Calls++
+		t.Input += r.Input
+		t.Output += r.Output
+		if r.Cost != nil {
+			t.Cost += *r.Cost
+		} else {
+			t.Unknown++
+		}
+	}
+
+	out := make([]Total, 0, len(totals))
+	for _, t := range totals {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Period != out[j].Period {
+			return out[i].Period > out[j].Period
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package usage_test
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stephenbyrne99/ncurl/internal/usage"
+)
+
+func TestCost(t *testing.T) {
+	prices := usage.Prices{
+		"claude-3":          {Input: 1, Output: 2},
+		"claude-3-7-sonnet": {Input: 3, Output: 15},
+	}
+
+	cost, ok := prices.Cost(usage.Tokens{Model: "claude-3-7-sonnet-latest", Input: 1000, Output: 200})
+	if !ok || math.Abs(cost-0.006) > 1e-9 {
+		t.Errorf("Expected $0.006 from the longest matching price, got %v, %v", cost, ok)
+	}
+
+	if _, ok := prices.Cost(usage.Tokens{Model: "gpt-4o", Input: 1000}); ok {
+		t.Error("Expected no cost for an unknown model")
+	}
+}
+
+func TestLoadPrices(t *testing.T) {
+	dir := t.TempDir()
+
+	prices, err := usage.LoadPrices(filepath.Join(dir, "missing.json"))
+	if err != nil || len(prices) != len(usage.DefaultPrices) {
+		t.Fatalf("Expected the default prices for a missing file, got %v, %v", prices, err)
+	}
+
+	path := filepath.Join(dir, "prices.json")
+	table := `{"claude-3-7-sonnet": {"input": 2, "output": 10}, "local": {}}`
+	if writeErr := os.WriteFile(path, []byte(table), 0o600); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	prices, err = usage.LoadPrices(path)
+	if err != nil {
+		t.Fatalf("LoadPrices failed: %v", err)
+	}
+	if p, _ := prices.Lookup("claude-3-7-sonnet-20250219"); p.Input != 2 || p.Output != 10 {
+		t.Errorf("Expected the file to override the default, got %+v", p)
+	}
+	if _, ok := prices.Lookup("claude-3-opus-latest"); !ok {
+		t.Error("Expected defaults to be kept for other models")
+	}
+	if cost, ok := prices.Cost(usage.Tokens{Model: "local-llama", Input: 5000}); !ok || cost != 0 {
+		t.Errorf("Expected free local model, got %v, %v", cost, ok)
+	}
+
+	if writeErr := os.WriteFile(path, []byte(`{"x": {"input": -1}}`), 0o600); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	if _, err = usage.LoadPrices(path); !errors.Is(err, usage.ErrInvalidPrices) {
+		t.Errorf("Expected ErrInvalidPrices, got %v", err)
+	}
+}
+
+func TestLogAndSummarize(t *testing.T) {
+	log := usage.NewTestLog(filepath.Join(t.TempDir(), "usage.jsonl"))
+
+	records, err := log.Records()
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Expected an empty log, got %v, %v", records, err)
+	}
+
+	cost := 0.01
+	monday := time.Date(2025, 3, 3, 10, 0, 0, 0, time.Local)
+	err = log.Append(
+		usage.Record{Time: monday, Tokens: usage.Tokens{Model: "a", Input: 100, Output: 10}, Cost: &cost},
+		usage.Record{Time: monday.Add(time.Hour), Tokens: usage.Tokens{Model: "a", Input: 50, Output: 5}, Cost: &cost},
+		usage.Record{Time: monday.Add(24 * time.Hour), Tokens: usage.Tokens{Model: "b", Input: 1, Output: 1}},
+		usage.Record{Time: monday.Add(7 * 24 * time.Hour), Tokens: usage.Tokens{Model: "a", Input: 1}, Cost: &cost},
+	)
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	records, err = log.Records()
+	if err != nil || len(records) != 4 {
+		t.Fatalf("Expected 4 records, got %d, %v", len(records), err)
+	}
+
+	days, err := usage.Summarize(records, usage.PeriodDay)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(days) != 3 || days[0].Period != "2025-03-10" || days[2].Period != "2025-03-03" {
+		t.Fatalf("Expected 3 days, newest first, got %+v", days)
+	}
+	if first := days[2]; first.Calls != 2 || first.Input != 150 || first.Output != 15 || math.Abs(first.Cost-0.02) > 1e-9 {
+		t.Errorf("Unexpected daily total: %+v", first)
+	}
+	if days[1].Unknown != 1 {
+		t.Errorf("Expected the unpriced call to be counted, got %+v", days[1])
+	}
+
+	weeks, err := usage.Summarize(records, usage.PeriodWeek)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(weeks) != 3 || weeks[0].Period != "2025-W11" || weeks[1].Model != "a" || weeks[1].Calls != 2 {
+		t.Errorf("Unexpected weekly totals: %+v", weeks)
+	}
+
+	if _, err = usage.Summarize(records, "month"); !errors.Is(err, usage.ErrInvalidPeriod) {
+		t.Errorf("Expected ErrInvalidPeriod, got %v", err)
+	}
+}